@@ -10,7 +10,6 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 	"containereye/internal/models"
 	"containereye/cmd/cli/client"
 )
@@ -26,26 +25,6 @@ type ContainerStats = models.ContainerStats
 // Alert represents a container alert
 type Alert = models.Alert
 
-var loginCmd = &cobra.Command{
-	Use:   "login",
-	Short: "Login to ContainerEye",
-	Run: func(cmd *cobra.Command, args []string) {
-		username, _ := cmd.Flags().GetString("username")
-		password, _ := cmd.Flags().GetString("password")
-		
-		// TODO: Implement login logic and save token
-		token, err := authenticate(username, password)
-		if err != nil {
-			fmt.Printf("Login failed: %v\n", err)
-			return
-		}
-		
-		viper.Set("token", token)
-		viper.WriteConfig()
-		fmt.Println("Login successful")
-	},
-}
-
 var containerCmd = &cobra.Command{
 	Use:   "containers",
 	Short: "List and manage containers",
@@ -93,11 +72,16 @@ func addRuleCommands(rootCmd *cobra.Command) {
 	}
 
 	// List rules
-	var enabledFlag *bool
+	var enabled bool
 	var listCmd = &cobra.Command{
 		Use:   "list",
 		Short: "List alert rules",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			// Only filter when --enabled was given
+			var enabledFlag *bool
+			if cmd.Flags().Changed("enabled") {
+				enabledFlag = &enabled
+			}
 			rules, err := apiClient.ListRules(enabledFlag)
 			if err != nil {
 				return err
@@ -107,7 +91,7 @@ func addRuleCommands(rootCmd *cobra.Command) {
 			return nil
 		},
 	}
-	listCmd.Flags().BoolVar(enabledFlag, "enabled", false, "Filter by enabled status")
+	listCmd.Flags().BoolVar(&enabled, "enabled", false, "Filter by enabled status")
 
 	// Get rule
 	var getCmd = &cobra.Command{
@@ -393,18 +377,44 @@ func printRule(rule *models.AlertRule) {
 	fmt.Printf("ID:          %d\n", rule.ID)
 	fmt.Printf("Name:        %s\n", rule.Name)
 	fmt.Printf("Description: %s\n", rule.Description)
-	fmt.Printf("Metric:      %s\n", rule.Metric)
-	fmt.Printf("Operator:    %s\n", rule.Operator)
-	fmt.Printf("Threshold:   %.2f\n", rule.Threshold)
+	if rule.Expression != "" {
+		fmt.Printf("Expression:  %s\n", rule.Expression)
+	} else if rule.Event != "" {
+		fmt.Printf("Event:       %s\n", rule.Event)
+		fmt.Printf("Window:      %d seconds\n", rule.EventWindow)
+		fmt.Printf("Operator:    %s\n", rule.Operator)
+		fmt.Printf("Threshold:   %.0f\n", rule.Threshold)
+	} else {
+		fmt.Printf("Metric:      %s\n", rule.Metric)
+		fmt.Printf("Operator:    %s\n", rule.Operator)
+		fmt.Printf("Threshold:   %.2f\n", rule.Threshold)
+	}
 	fmt.Printf("Duration:    %d seconds\n", rule.Duration)
+	fmt.Printf("Cooldown:    %d seconds\n", rule.CooldownPeriod)
+	fmt.Printf("Recovery:    %d seconds\n", rule.RecoveryPeriod)
 	fmt.Printf("Level:       %s\n", rule.Level)
 	fmt.Printf("Enabled:     %v\n", rule.IsEnabled)
 	if rule.ContainerID != "" {
 		fmt.Printf("Container:   %s\n", rule.ContainerID)
 	}
+	if rule.Host != "" {
+		fmt.Printf("Host:        %s\n", rule.Host)
+	}
 	if rule.ContainerName != "" {
 		fmt.Printf("Container:   %s\n", rule.ContainerName)
 	}
+	if rule.Image != "" {
+		fmt.Printf("Image:       %s\n", rule.Image)
+	}
+	if rule.ComposeProject != "" {
+		fmt.Printf("Project:     %s\n", rule.ComposeProject)
+	}
+	if rule.ComposeService != "" {
+		fmt.Printf("Service:     %s\n", rule.ComposeService)
+	}
+	if rule.LabelSelector != "" {
+		fmt.Printf("Labels:      %s\n", rule.LabelSelector)
+	}
 	fmt.Printf("Created At:  %s\n", rule.CreatedAt.Format(time.RFC3339))
 	fmt.Printf("Updated At:  %s\n", rule.UpdatedAt.Format(time.RFC3339))
 }
@@ -420,6 +430,10 @@ func printContainerStats(stats *ContainerStats) {
 	fmt.Fprintf(w, "Network TX\t%s\n", formatBytes(stats.NetworkTx))
 	fmt.Fprintf(w, "Block Read\t%s\n", formatBytes(stats.BlockRead))
 	fmt.Fprintf(w, "Block Write\t%s\n", formatBytes(stats.BlockWrite))
+	fmt.Fprintf(w, "Network RX Rate\t%s/s\n", formatBytes(uint64(stats.NetworkRxRate)))
+	fmt.Fprintf(w, "Network TX Rate\t%s/s\n", formatBytes(uint64(stats.NetworkTxRate)))
+	fmt.Fprintf(w, "Block Read Rate\t%s/s\n", formatBytes(uint64(stats.BlockReadRate)))
+	fmt.Fprintf(w, "Block Write Rate\t%s/s\n", formatBytes(uint64(stats.BlockWriteRate)))
 	fmt.Fprintf(w, "PIDs\t%d\n", stats.PIDs)
 	w.Flush()
 }
@@ -544,9 +558,6 @@ var deleteReportCmd = &cobra.Command{
 }
 
 func init() {
-	loginCmd.Flags().StringP("username", "u", "", "Username")
-	loginCmd.Flags().StringP("password", "p", "", "Password")
-	
 	containerCmd.AddCommand(&cobra.Command{
 		Use:   "stats [container-id]",
 		Short: "Show detailed stats for a container",
@@ -604,11 +615,6 @@ func init() {
 }
 
 // API client functions
-func authenticate(username, password string) (string, error) {
-	// TODO: Implement API call
-	return "", nil
-}
-
 func getContainers() ([]Container, error) {
 	// TODO: Implement API call
 	return nil, nil
@@ -17,9 +17,19 @@ It provides real-time and historical statistics, alerts management, and more.`,
 
 func init() {
 	// Add commands
+	rootCmd.AddCommand(commands.NewLoginCommand())
+	rootCmd.AddCommand(commands.NewLogoutCommand())
 	rootCmd.AddCommand(commands.NewContainerCommand())
 	rootCmd.AddCommand(commands.NewStatsCommand())
 	rootCmd.AddCommand(commands.NewAlertCommand())
+	rootCmd.AddCommand(commands.NewSilenceCommand())
+	rootCmd.AddCommand(commands.NewHostCommand())
+	rootCmd.AddCommand(commands.NewAgentCommand())
+	rootCmd.AddCommand(commands.NewUserCommand())
+	rootCmd.AddCommand(commands.NewAPIKeyCommand())
+	rootCmd.AddCommand(commands.NewRoleCommand())
+	rootCmd.AddCommand(commands.NewWhoamiCommand())
+	rootCmd.AddCommand(commands.NewAuditCommand())
 }
 
 func main() {
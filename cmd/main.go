@@ -1,43 +1,118 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
 	"time"
 
 	"containereye/internal/api"
 	"containereye/internal/monitor"
 	"containereye/internal/alert"
+	"containereye/internal/auth"
 	"containereye/internal/config"
 	"containereye/internal/database"
+	"containereye/internal/logging"
 	"containereye/internal/models"
+	"containereye/internal/notify"
+	"containereye/internal/retention"
+
+	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	configPath := flag.String("config", "", "Config file (default ./config.yaml)")
+	flag.Parse()
+
 	// Initialize configuration
-	cfg := config.LoadConfig()
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	requestLog, err := logging.Setup(cfg.Logging)
+	if err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	gin.DefaultWriter = requestLog
+	gin.DefaultErrorWriter = requestLog
+	if !cfg.Server.Debug {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	// Migrations only need the database, not the auth settings
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		if err := migrate(cfg, args[1:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	if err := auth.Configure(cfg.Auth); err != nil {
+		log.Fatalf("Failed to configure authentication: %v", err)
+	}
+
+	if args := flag.Args(); len(args) > 0 {
+		switch args[0] {
+		case "apikey":
+			if err := createAPIKey(cfg, args[1:]); err != nil {
+				log.Fatalf("Failed to create API key: %v", err)
+			}
+		default:
+			log.Fatalf("Unknown command %q", args[0])
+		}
+		return
+	}
 
 	// Initialize database
-	if err := database.Initialize(cfg.Database.Path); err != nil {
+	if err := database.Initialize(cfg.DatabaseConfig()); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer database.Close()
 
+	// Create the first admin on an empty database
+	if err := auth.Bootstrap(); err != nil {
+		log.Fatalf("Failed to bootstrap users: %v", err)
+	}
+
 	db := database.GetDB()
 
+	// Initialize notification channels
+	notifiers, err := notify.NewRegistry(cfg.AlertHandlers())
+	if err != nil {
+		log.Fatalf("Failed to configure alert handlers: %v", err)
+	}
+
+	// Start delivering queued notifications
+	outbox := notify.NewOutbox(db, notifiers, cfg.Notifications.Outbox)
+	if err := outbox.Start(); err != nil {
+		log.Fatalf("Failed to start notification outbox: %v", err)
+	}
+	defer outbox.Stop()
+
 	// Initialize alert manager
-	alertConfig := &alert.Config{
-		SlackToken:     cfg.Alert.Slack.Token,
-		SlackChannel:   cfg.Alert.Slack.Channel,
-		SMTPHost:       cfg.Alert.Email.SMTPHost,
-		SMTPPort:       cfg.Alert.Email.SMTPPort,
-		EmailFrom:      cfg.Alert.Email.From,
-		EmailPassword:  cfg.Alert.Email.Password,
-		EmailReceivers: cfg.Alert.Email.ToReceivers,
-	}
-	alertManager := alert.NewAlertManager(alertConfig)
+	alertManager := alert.NewAlertManager(outbox)
 	
+	// Escalate unacknowledged alerts
+	if cfg.Alert.EscalationEnabled {
+		policies := cfg.Alert.EscalationPolicies
+		if len(policies) == 0 {
+			policies = alert.DefaultEscalationPolicies()
+		}
+		escalator, err := alert.NewEscalator(alertManager, policies, cfg.Alert.EscalationInterval)
+		if err != nil {
+			log.Fatalf("Failed to configure escalation policies: %v", err)
+		}
+		escalator.Start()
+		defer escalator.Stop()
+	}
+
 	// Initialize rule manager
 	ruleManager := alert.NewRuleManager(alertManager, db)
+	ruleManager.SetDefaultCooldown(cfg.Alert.DefaultCooldown)
 	
 	// Create default rules if none exist
 	var ruleCount int64
@@ -49,21 +124,116 @@ func main() {
 		}
 	}
 
-	// Initialize collector with 30-second interval
-	collector, err := monitor.NewCollector(ruleManager, 30*time.Second)
-	if err != nil {
-		log.Fatalf("Failed to create collector: %v", err)
+	// Resume the expression windows from the stored stats
+	if err := ruleManager.LoadHistory(); err != nil {
+		log.Printf("Warning: Failed to load rule history: %v", err)
 	}
 
-	// Start collector
-	if err := collector.Start(); err != nil {
-		log.Fatalf("Failed to start collector: %v", err)
+	// Roll up and expire stored stats
+	compactor := retention.NewCompactor(db, cfg.Retention)
+	compactor.Start()
+	defer compactor.Stop()
+
+	// Collect from every host
+	hosts := monitor.NewHostManager(ruleManager, cfg.Monitor)
+	if err := hosts.Start(cfg.Hosts); err != nil {
+		log.Fatalf("Failed to start collectors: %v", err)
 	}
-	defer collector.Stop()
+	defer hosts.Stop()
 
 	// Initialize and start API server
-	server := api.NewServer(collector, alertManager, ruleManager)
-	if err := server.Start(cfg.Server.Port); err != nil {
+	server, err := api.NewServer(hosts, alertManager, ruleManager, cfg.Retention, api.Options{
+		TrustedProxies: cfg.Server.TrustedProxies,
+		PublicMetrics:  cfg.Server.PublicMetrics,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
+	if err := server.Start(cfg.Addr()); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// migrate runs "migrate up [version]", "migrate down [steps]" or
+// "migrate status" against the configured database
+func migrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: containereye-server migrate up [version] | down [steps] | status")
+	}
+	if err := database.Open(cfg.DatabaseConfig()); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	number := func(def int) (int, error) {
+		if len(args) < 2 {
+			return def, nil
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid number %q", args[1])
+		}
+		return n, nil
+	}
+
+	switch args[0] {
+	case "up":
+		version, err := number(0)
+		if err != nil {
+			return err
+		}
+		return database.MigrateUp(version)
+	case "down":
+		steps, err := number(1)
+		if err != nil {
+			return err
+		}
+		return database.MigrateDown(steps)
+	case "status":
+		states, err := database.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = state.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", state.Version, state.Name, applied)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+}
+
+// createAPIKey runs "apikey <username> <name> [scope]", which creates an API
+// key with the user's role or the given scope, e.g. to set up the CLI
+// without logging in or to give a push agent an agent key
+func createAPIKey(cfg *config.Config, args []string) error {
+	if len(args) != 2 && len(args) != 3 {
+		return fmt.Errorf("usage: containereye-server apikey <username> <name> [scope]")
+	}
+	if err := database.Initialize(cfg.DatabaseConfig()); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	user, err := auth.GetUser(args[0])
+	if err != nil {
+		return err
+	}
+	input := auth.APIKeyInput{Name: args[1]}
+	if len(args) == 3 {
+		input.Scope = models.Role(args[2])
+	}
+	key, plain, err := auth.CreateAPIKey(user, input, nil)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created API key %s (%s) for %s with scope %s:\n%s\n", key.Name, key.Prefix, user.Username, key.Scope, plain)
+	return nil
+}
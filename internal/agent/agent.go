@@ -0,0 +1,222 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"containereye/internal/models"
+	"containereye/internal/monitor"
+)
+
+const (
+	DefaultInterval    = 30 * time.Second
+	DefaultMaxBuffered = 2880 // A day of batches at the default interval
+	pushTimeout        = 30 * time.Second
+)
+
+// errRejected marks batches the server could not parse
+var errRejected = errors.New("batch rejected")
+
+// Config configures an agent
+type Config struct {
+	ServerURL   string        // Base URL of the ContainerEye server
+	Token       string        // API key or token authenticating the pushes
+	Host        string        // Name the server stores the host's data under
+	BufferDir   string        // Directory batches are kept in until pushed
+	Interval    time.Duration // Time between collections
+	MaxBuffered int           // Oldest batches are dropped beyond this many
+}
+
+// Agent collects the stats of the local Docker daemon and pushes them to a
+// server that cannot reach the host. Every batch is written to the buffer
+// directory first and removed once the server accepted it, so collections
+// made while the server is unreachable are pushed when it is back.
+type Agent struct {
+	config     Config
+	collector  *monitor.Collector
+	httpClient *http.Client
+}
+
+func New(config Config) (*Agent, error) {
+	if config.ServerURL == "" {
+		return nil, fmt.Errorf("server URL is required")
+	}
+	if config.Host == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hostname: %v", err)
+		}
+		config.Host = hostname
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.MaxBuffered <= 0 {
+		config.MaxBuffered = DefaultMaxBuffered
+	}
+	if err := os.MkdirAll(config.BufferDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create buffer directory: %v", err)
+	}
+
+	// Stats are only collected, rules are evaluated by the server
+	collectorConfig := monitor.DefaultConfig()
+	collectorConfig.Interval = config.Interval
+	collector, err := monitor.NewCollector(&models.Host{Name: config.Host}, nil, collectorConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Docker: %v", err)
+	}
+
+	return &Agent{
+		config:     config,
+		collector:  collector,
+		httpClient: &http.Client{Timeout: pushTimeout},
+	}, nil
+}
+
+// Run collects and pushes every interval until the context is done
+func (a *Agent) Run(ctx context.Context) error {
+	ticker := time.NewTicker(a.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.collect(); err != nil {
+			log.Printf("Error collecting stats: %v", err)
+		}
+		if err := a.flush(ctx); err != nil {
+			log.Printf("Error pushing to %s, keeping batches buffered: %v", a.config.ServerURL, err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// collect takes a snapshot and writes it to the buffer
+func (a *Agent) collect() error {
+	batch, err := a.collector.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch: %v", err)
+	}
+
+	// Names sort in collection order
+	name := filepath.Join(a.config.BufferDir, fmt.Sprintf("%020d.json", batch.CollectedAt.UnixNano()))
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to buffer batch: %v", err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return fmt.Errorf("failed to buffer batch: %v", err)
+	}
+
+	return a.trimBuffer()
+}
+
+// buffered returns the paths of the buffered batches, oldest first
+func (a *Agent) buffered() ([]string, error) {
+	entries, err := os.ReadDir(a.config.BufferDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			files = append(files, filepath.Join(a.config.BufferDir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// trimBuffer drops the oldest batches beyond the buffer limit
+func (a *Agent) trimBuffer() error {
+	files, err := a.buffered()
+	if err != nil {
+		return err
+	}
+	if excess := len(files) - a.config.MaxBuffered; excess > 0 {
+		log.Printf("Buffer full, dropping the %d oldest batches", excess)
+		for _, file := range files[:excess] {
+			os.Remove(file)
+		}
+	}
+	return nil
+}
+
+// flush pushes the buffered batches oldest first and stops at the first
+// failure so that batches arrive in order
+func (a *Agent) flush(ctx context.Context) error {
+	files, err := a.buffered()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		err = a.push(ctx, data)
+		if errors.Is(err, errRejected) {
+			// Pushing it again would fail the same way
+			log.Printf("Dropping batch %s: %v", filepath.Base(file), err)
+		} else if err != nil {
+			return err
+		}
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Agent) push(ctx context.Context, data []byte) error {
+	url := strings.TrimSuffix(a.config.ServerURL, "/") + "/api/v1/ingest"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.config.Token)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		if resp.StatusCode == http.StatusBadRequest {
+			return fmt.Errorf("%w: %v", errRejected, err)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,367 @@
+package alert
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"containereye/internal/database"
+	"containereye/internal/models"
+	"gorm.io/gorm"
+)
+
+const defaultEscalationInterval = time.Minute
+
+// EscalationStep notifies additional channels and users once an alert has
+// stayed unacknowledged for After, optionally repeating every Repeat.
+type EscalationStep struct {
+	After       time.Duration `mapstructure:"after"`
+	Channels    []string      `mapstructure:"channels"`
+	Users       []string      `mapstructure:"users"`        // ContainerEye usernames, reached by email
+	UserChannel string        `mapstructure:"user_channel"` // Channel used to reach Users, defaults to "email"
+	Repeat      time.Duration `mapstructure:"repeat"`
+}
+
+// EscalationPolicy is an ordered list of steps applied to the alerts it
+// matches. Empty Levels or Rules match any level or rule.
+type EscalationPolicy struct {
+	Name   string              `mapstructure:"name"`
+	Levels []models.AlertLevel `mapstructure:"levels"`
+	Rules  []string            `mapstructure:"rules"`
+	Steps  []EscalationStep    `mapstructure:"steps"`
+}
+
+// DefaultEscalationPolicies notify all channels again after an alert has
+// gone unacknowledged for a level dependent time.
+func DefaultEscalationPolicies() []EscalationPolicy {
+	return []EscalationPolicy{
+		{Name: "critical", Levels: []models.AlertLevel{models.AlertLevelCritical}, Steps: []EscalationStep{{After: 15 * time.Minute}}},
+		{Name: "warning", Levels: []models.AlertLevel{models.AlertLevelWarning}, Steps: []EscalationStep{{After: 30 * time.Minute}}},
+		{Name: "info", Levels: []models.AlertLevel{models.AlertLevelInfo}, Steps: []EscalationStep{{After: time.Hour}}},
+	}
+}
+
+func (p *EscalationPolicy) matches(alert *models.Alert) bool {
+	if len(p.Levels) > 0 {
+		found := false
+		for _, level := range p.Levels {
+			if strings.EqualFold(string(level), string(alert.Level)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(p.Rules) > 0 {
+		for _, rule := range p.Rules {
+			if rule == alert.RuleName {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// Escalation is a step that is due for an alert
+type Escalation struct {
+	Alert  models.Alert
+	Policy *EscalationPolicy
+	Step   int // 1-based index into Policy.Steps
+	Repeat int
+}
+
+// Escalator periodically checks unacknowledged alerts against the
+// escalation policies and fires the steps that became due. Acknowledging or
+// resolving an alert stops its escalation. When several servers share the
+// database only the one holding the escalator lease runs.
+type Escalator struct {
+	alertManager *AlertManager
+	db           *gorm.DB
+	policies     []EscalationPolicy
+	interval     time.Duration
+	stopChan     chan struct{}
+}
+
+func NewEscalator(alertManager *AlertManager, policies []EscalationPolicy, interval time.Duration) (*Escalator, error) {
+	if interval <= 0 {
+		interval = defaultEscalationInterval
+	}
+
+	for i := range policies {
+		if err := alertManager.validatePolicy(&policies[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Escalator{
+		alertManager: alertManager,
+		db:           alertManager.db,
+		policies:     policies,
+		interval:     interval,
+		stopChan:     make(chan struct{}),
+	}, nil
+}
+
+// validatePolicy checks that a policy only refers to configured channels
+// and sorts its steps by delay.
+func (am *AlertManager) validatePolicy(p *EscalationPolicy) error {
+	if p.Name == "" {
+		return fmt.Errorf("escalation policy name is required")
+	}
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("escalation policy %q has no steps", p.Name)
+	}
+
+	registry := am.outbox.Notifiers()
+	for i := range p.Steps {
+		step := &p.Steps[i]
+		if step.After <= 0 {
+			return fmt.Errorf("escalation policy %q step %d: after must be positive", p.Name, i+1)
+		}
+		for _, channel := range step.Channels {
+			if _, ok := registry.Get(channel); !ok {
+				return fmt.Errorf("escalation policy %q step %d: unknown channel %q", p.Name, i+1, channel)
+			}
+		}
+		if len(step.Users) > 0 {
+			if step.UserChannel == "" {
+				step.UserChannel = "email"
+			}
+			if _, ok := registry.Get(step.UserChannel); !ok {
+				return fmt.Errorf("escalation policy %q step %d: unknown user channel %q", p.Name, i+1, step.UserChannel)
+			}
+		}
+	}
+
+	sort.SliceStable(p.Steps, func(i, j int) bool {
+		return p.Steps[i].After < p.Steps[j].After
+	})
+	return nil
+}
+
+// escalatorLease is held by the one server that runs escalations when
+// several share the database
+const escalatorLease = "escalator"
+
+func (e *Escalator) Start() {
+	go func() {
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				held, err := database.AcquireLease(e.db, escalatorLease, 3*e.interval)
+				if err != nil {
+					log.Printf("Error acquiring the escalator lease: %v", err)
+					continue
+				}
+				if !held {
+					continue
+				}
+				if err := e.Run(); err != nil {
+					log.Printf("Error running escalations: %v", err)
+				}
+			case <-e.stopChan:
+				if err := database.ReleaseLease(e.db, escalatorLease); err != nil {
+					log.Printf("Error releasing the escalator lease: %v", err)
+				}
+				return
+			}
+		}
+	}()
+}
+
+func (e *Escalator) Stop() {
+	close(e.stopChan)
+}
+
+// CheckEscalations returns the escalation steps that are due now, in the
+// order they fire. Every step whose delay has passed since the last one
+// that fired is due, e.g. after the escalator was down. Only ACTIVE alerts
+// escalate, so acknowledged and silenced alerts are skipped.
+func (e *Escalator) CheckEscalations() ([]Escalation, error) {
+	var active []models.Alert
+	if err := e.db.Where("status = ? AND silence_id IS NULL", models.AlertStatusActive).Find(&active).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch active alerts: %v", err)
+	}
+
+	now := time.Now()
+	var due []Escalation
+	for _, alert := range active {
+		policy := e.policyFor(&alert)
+		if policy == nil {
+			continue
+		}
+
+		// Alerts that fired before a silence started are not paged again
+		silence, err := e.alertManager.MatchingSilence(&alert)
+		if err != nil {
+			log.Printf("Error checking silences of alert %d, not escalating it: %v", alert.ID, err)
+			continue
+		}
+		if silence != nil {
+			continue
+		}
+
+		elapsed := now.Sub(alert.StartTime)
+
+		// Latest step whose delay has passed
+		reached := 0
+		for i, step := range policy.Steps {
+			if elapsed >= step.After {
+				reached = i + 1
+			}
+		}
+		if reached == 0 {
+			continue
+		}
+
+		if reached > alert.EscalationStep {
+			for step := alert.EscalationStep + 1; step <= reached; step++ {
+				due = append(due, Escalation{Alert: alert, Policy: policy, Step: step})
+			}
+			continue
+		}
+
+		step := policy.Steps[reached-1]
+		if step.Repeat > 0 && alert.LastEscalatedAt != nil && now.Sub(*alert.LastEscalatedAt) >= step.Repeat {
+			var repeats int64
+			if err := e.db.Model(&models.AlertEscalation{}).
+				Where("alert_id = ? AND step = ?", alert.ID, reached).Count(&repeats).Error; err != nil {
+				log.Printf("Error counting repeats of escalation step %d of alert %d: %v", reached, alert.ID, err)
+				continue
+			}
+			due = append(due, Escalation{Alert: alert, Policy: policy, Step: reached, Repeat: int(repeats)})
+		}
+	}
+
+	return due, nil
+}
+
+// Run fires all due escalation steps
+func (e *Escalator) Run() error {
+	due, err := e.CheckEscalations()
+	if err != nil {
+		return err
+	}
+
+	// Later steps of an alert wait until its earlier ones have fired
+	failed := make(map[uint]bool)
+	for _, esc := range due {
+		if failed[esc.Alert.ID] {
+			continue
+		}
+		if err := e.fire(esc); err != nil {
+			log.Printf("Error escalating alert %d: %v", esc.Alert.ID, err)
+			failed[esc.Alert.ID] = true
+		}
+	}
+	return nil
+}
+
+// fire queues the step's notifications and records that it fired, all in
+// one transaction so a step is never recorded without being sent.
+func (e *Escalator) fire(esc Escalation) error {
+	step := esc.Policy.Steps[esc.Step-1]
+	now := time.Now()
+
+	emails, err := e.userEmails(step.Users)
+	if err != nil {
+		return err
+	}
+
+	alert := esc.Alert
+	alert.EscalationStep = esc.Step
+	alert.LastEscalatedAt = &now
+
+	// The notification carries the escalation, the stored message does not
+	notification := alert
+	notification.Message = fmt.Sprintf("[Escalation %s step %d] %s", esc.Policy.Name, esc.Step, alert.Message)
+
+	return e.db.Transaction(func(tx *gorm.DB) error {
+		// Guard against the alert having been acknowledged in the meantime
+		res := tx.Model(&models.Alert{}).
+			Where("id = ? AND status = ?", alert.ID, models.AlertStatusActive).
+			Updates(map[string]interface{}{
+				"escalation_step":   alert.EscalationStep,
+				"last_escalated_at": alert.LastEscalatedAt,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to update alert: %v", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+
+		record := models.AlertEscalation{
+			AlertID:  alert.ID,
+			Policy:   esc.Policy.Name,
+			Step:     esc.Step,
+			Repeat:   esc.Repeat,
+			Channels: strings.Join(step.Channels, ","),
+			Users:    strings.Join(step.Users, ","),
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return fmt.Errorf("failed to record escalation: %v", err)
+		}
+
+		// A step without channels or reachable users notifies every channel
+		if len(step.Channels) > 0 || len(emails) == 0 {
+			if err := e.alertManager.outbox.Enqueue(tx, &notification, step.Channels...); err != nil {
+				return err
+			}
+		}
+		if len(emails) > 0 {
+			if err := e.alertManager.outbox.EnqueueTo(tx, &notification, step.UserChannel, emails); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// userEmails resolves usernames to the email addresses of active users
+func (e *Escalator) userEmails(usernames []string) ([]string, error) {
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	var users []models.User
+	if err := e.db.Where("username IN ? AND is_active = ?", usernames, true).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to look up escalation users: %v", err)
+	}
+
+	emails := make([]string, 0, len(users))
+	for _, u := range users {
+		if email := u.EmailAddress(); email != "" {
+			emails = append(emails, email)
+		}
+	}
+	return emails, nil
+}
+
+func (e *Escalator) policyFor(alert *models.Alert) *EscalationPolicy {
+	for i := range e.policies {
+		if e.policies[i].matches(alert) {
+			return &e.policies[i]
+		}
+	}
+	return nil
+}
+
+// ListEscalations returns the escalation steps that fired for an alert
+func (am *AlertManager) ListEscalations(alertID uint) ([]models.AlertEscalation, error) {
+	var escalations []models.AlertEscalation
+	if err := am.db.Where("alert_id = ?", alertID).Order("id").Find(&escalations).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch escalations: %v", err)
+	}
+	return escalations, nil
+}
@@ -1,7 +1,9 @@
 package alert
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,13 +14,28 @@ import (
 type RuleEvaluator struct {
 	alertManager *AlertManager
 	db          *gorm.DB
-	stateCache  map[uint]*ruleState
+	stateCache  map[string]*ruleState
+	history     map[string][]*models.ContainerStats // Recent samples per container for expression windows
 	mutex       sync.RWMutex
+	exprCache   map[uint]*compiledExpression
+	exprMutex   sync.Mutex
+	defaultCooldown time.Duration // Applies to rules without a cooldown period
 }
 
+// compiledExpression caches the parsed expression of a rule
+type compiledExpression struct {
+	source string
+	expr   *Expression
+	err    error
+}
+
+// ruleState tracks the condition of one rule on one container, keyed by
+// the alert fingerprint.
 type ruleState struct {
 	ViolationStart time.Time
 	IsViolating    bool
+	ClearSince     time.Time
+	AlertOpen      bool
 	LastValue      float64
 }
 
@@ -26,7 +43,67 @@ func NewRuleEvaluator(alertManager *AlertManager, db *gorm.DB) *RuleEvaluator {
 	return &RuleEvaluator{
 		alertManager: alertManager,
 		db:          db,
-		stateCache:  make(map[uint]*ruleState),
+		stateCache:  make(map[string]*ruleState),
+		history:     make(map[string][]*models.ContainerStats),
+		exprCache:   make(map[uint]*compiledExpression),
+	}
+}
+
+// expression returns the compiled expression of a rule, reparsing it only
+// when the rule's expression changed.
+func (e *RuleEvaluator) expression(rule *models.AlertRule) (*Expression, error) {
+	e.exprMutex.Lock()
+	defer e.exprMutex.Unlock()
+
+	if c, ok := e.exprCache[rule.ID]; ok && c.source == rule.Expression {
+		return c.expr, c.err
+	}
+	expr, err := CompileExpression(rule.Expression)
+	e.exprCache[rule.ID] = &compiledExpression{source: rule.Expression, expr: expr, err: err}
+	return expr, err
+}
+
+// cooldown returns the minimum time between alerts of a rule
+func (e *RuleEvaluator) cooldown(rule *models.AlertRule) time.Duration {
+	if rule.CooldownPeriod == 0 {
+		return e.defaultCooldown
+	}
+	return time.Duration(rule.CooldownPeriod) * time.Second
+}
+
+// historyWindow returns how much history the expression rules need
+func (e *RuleEvaluator) historyWindow(rules []models.AlertRule) time.Duration {
+	var window time.Duration
+	for i := range rules {
+		if rules[i].Expression == "" {
+			continue
+		}
+		expr, err := e.expression(&rules[i])
+		if err == nil && expr.MaxWindow() > window {
+			window = expr.MaxWindow()
+		}
+	}
+	return window
+}
+
+// recordSample adds stats to the container's history and drops samples
+// older than window. Containers that stopped reporting are forgotten.
+func (e *RuleEvaluator) recordSample(stats *models.ContainerStats, window time.Duration) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	samples := append(e.history[stats.ContainerID], stats)
+	cutoff := stats.Timestamp.Add(-window)
+	i := 0
+	for i < len(samples)-1 && !samples[i].Timestamp.After(cutoff) {
+		i++
+	}
+	e.history[stats.ContainerID] = append([]*models.ContainerStats(nil), samples[i:]...)
+
+	for id, h := range e.history {
+		if stats.Timestamp.Sub(h[len(h)-1].Timestamp) > MaxExpressionWindow {
+			delete(e.history, id)
+		}
 	}
 }
 
@@ -34,15 +111,35 @@ func (e *RuleEvaluator) EvaluateMetric(rule *models.AlertRule, stats *models.Con
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 
-	state, ok := e.stateCache[rule.ID]
+	fingerprint := Fingerprint(rule.ID, stats.ContainerID)
+	state, ok := e.stateCache[fingerprint]
 	if !ok {
-		state = &ruleState{}
-		e.stateCache[rule.ID] = state
+		// Pick up alerts left open by a previous run so they can still resolve
+		open, err := e.alertManager.FindOpenAlert(fingerprint)
+		if err != nil {
+			return err
+		}
+		state = &ruleState{AlertOpen: open != nil}
+		e.stateCache[fingerprint] = state
 	}
 
-	currentValue := e.extractMetricValue(rule.Metric, stats)
-	isViolating := e.evaluateCondition(rule.Operator, currentValue, rule.Threshold)
+	var expr *Expression
+	if rule.Expression != "" {
+		var err error
+		if expr, err = e.expression(rule); err != nil {
+			return fmt.Errorf("invalid expression %q: %v", rule.Expression, err)
+		}
+	}
+	currentValue, isViolating, explanation, err := e.checkCondition(rule, expr, stats, e.history[stats.ContainerID])
+	if errors.Is(err, ErrInsufficientData) {
+		// Not enough samples yet, keep the previous state
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 	now := time.Now()
+	state.LastValue = currentValue
 
 	if isViolating {
 		if !state.IsViolating {
@@ -50,43 +147,154 @@ func (e *RuleEvaluator) EvaluateMetric(rule *models.AlertRule, stats *models.Con
 			state.ViolationStart = now
 			state.IsViolating = true
 		}
+		state.ClearSince = time.Time{}
 
 		// Check if violation duration exceeds rule duration
-		if time.Since(state.ViolationStart) >= time.Duration(rule.Duration)*time.Second {
-			// Create and send alert
-			alert := &models.Alert{
-				RuleID:        rule.ID,
-				ContainerID:   stats.ContainerID,
-				ContainerName: stats.ContainerName,
-				Level:         rule.Level,
-				Metric:        string(rule.Metric),
-				Threshold:     rule.Threshold,
-				CurrentValue:  currentValue,
-				Message:       e.formatAlertMessage(rule, currentValue),
-				Status:        models.AlertStatusActive,
-				StartTime:     state.ViolationStart,
-				Value:         currentValue,
-			}
-			
-			if err := e.alertManager.SendAlert(alert); err != nil {
-				return fmt.Errorf("failed to send alert: %v", err)
-			}
-			
+		if time.Since(state.ViolationStart) < time.Duration(rule.Duration)*time.Second {
+			return nil
+		}
+
+		alert := &models.Alert{
+			Fingerprint:   fingerprint,
+			RuleID:        rule.ID,
+			RuleName:      rule.Name,
+			HostID:        stats.HostID,
+			ContainerID:   stats.ContainerID,
+			ContainerName: stats.ContainerName,
+			Image:         stats.Image,
+			Labels:        stats.Labels,
+			Level:         rule.Level,
+			Metric:        ruleMetric(rule),
+			Threshold:     rule.Threshold,
+			CurrentValue:  currentValue,
+			Message:       e.formatAlertMessage(rule, stats, currentValue, explanation),
+			StartTime:     state.ViolationStart,
+			Value:         currentValue,
+		}
+
+		created, err := e.alertManager.FireAlert(alert, e.cooldown(rule))
+		if created {
+			state.AlertOpen = true
+
 			// Update rule statistics
 			rule.LastTriggered = &now
 			rule.TriggerCount++
-			if err := e.db.Save(rule).Error; err != nil {
-				return fmt.Errorf("failed to update rule: %v", err)
+			if err := e.updateRuleStats(rule); err != nil {
+				return err
 			}
 		}
+		if err != nil {
+			return fmt.Errorf("failed to send alert: %v", err)
+		}
+		return nil
+	}
+
+	if state.IsViolating {
+		// Condition just stopped violating
+		state.IsViolating = false
+		state.ClearSince = now
+	}
+
+	if !state.AlertOpen {
+		return nil
+	}
+	if state.ClearSince.IsZero() {
+		state.ClearSince = now
+	}
+
+	// Resolve once the condition has stayed clear for the recovery period
+	if time.Since(state.ClearSince) < time.Duration(rule.RecoveryPeriod)*time.Second {
+		return nil
+	}
+
+	resolved, err := e.alertManager.AutoResolveAlert(fingerprint)
+	if resolved {
+		state.AlertOpen = false
+		rule.ResolvedCount++
+		if err := e.updateRuleStats(rule); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to resolve alert: %v", err)
+	}
+	return nil
+}
+
+// checkCondition evaluates the condition of a rule on stats, with expr the
+// rule's compiled expression, if any, and history the container's recent
+// samples. It reports the measured value and whether the condition holds,
+// without changing any state.
+func (e *RuleEvaluator) checkCondition(rule *models.AlertRule, expr *Expression, stats *models.ContainerStats, history []*models.ContainerStats) (float64, bool, []string, error) {
+	if expr != nil {
+		result, err := expr.Evaluate(stats, history)
+		if errors.Is(err, ErrInsufficientData) {
+			return 0, false, nil, err
+		}
+		if err != nil {
+			return 0, false, nil, fmt.Errorf("expression %q on container %s: %v", rule.Expression, stats.ContainerName, err)
+		}
+		return result.Value, result.Matched, result.Explanation, nil
+	}
+
+	var value float64
+	if rule.Event != "" {
+		count, err := e.countEvents(rule, stats.ContainerID)
+		if err != nil {
+			return 0, false, nil, err
+		}
+		value = float64(count)
 	} else {
-		if state.IsViolating {
-			// Condition just stopped violating
-			state.IsViolating = false
+		value = e.extractMetricValue(rule.Metric, stats)
+	}
+	return value, e.evaluateCondition(rule.Operator, value, rule.Threshold), nil, nil
+}
+
+// forgetRule drops the tracked condition state of a rule on all containers
+func (e *RuleEvaluator) forgetRule(ruleID uint) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	prefix := fmt.Sprintf("%d:", ruleID)
+	for fingerprint := range e.stateCache {
+		if strings.HasPrefix(fingerprint, prefix) {
+			delete(e.stateCache, fingerprint)
 		}
 	}
 
-	state.LastValue = currentValue
+	e.exprMutex.Lock()
+	delete(e.exprCache, ruleID)
+	e.exprMutex.Unlock()
+}
+
+// forgetContainers drops the tracked condition state and history of
+// containers on all rules
+func (e *RuleEvaluator) forgetContainers(containerIDs []string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, id := range containerIDs {
+		suffix := ":" + id
+		for fingerprint := range e.stateCache {
+			if strings.HasSuffix(fingerprint, suffix) {
+				delete(e.stateCache, fingerprint)
+			}
+		}
+		delete(e.history, id)
+	}
+}
+
+// updateRuleStats persists only the trigger bookkeeping columns so that a
+// concurrent edit of the rule definition is not overwritten.
+func (e *RuleEvaluator) updateRuleStats(rule *models.AlertRule) error {
+	err := e.db.Model(&models.AlertRule{}).Where("id = ?", rule.ID).UpdateColumns(map[string]interface{}{
+		"last_triggered": rule.LastTriggered,
+		"trigger_count":  rule.TriggerCount,
+		"resolved_count": rule.ResolvedCount,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update rule: %v", err)
+	}
 	return nil
 }
 
@@ -107,26 +315,131 @@ func (e *RuleEvaluator) evaluateCondition(operator models.Operator, current, thr
 	}
 }
 
-func (e *RuleEvaluator) extractMetricValue(metric models.Metric, stats *models.ContainerStats) float64 {
-	switch metric {
-	case models.MetricCPUUsage:
-		return stats.CPUPercent
-	case models.MetricMemoryUsage:
-		return stats.MemoryPercent
-	case models.MetricDiskIO:
-		return float64(stats.DiskIOTotal)
-	case models.MetricNetworkIO:
-		return float64(stats.NetworkTotal)
-	default:
+// countEvents returns how many of the rule's events the container had
+// within the rule's event window.
+func (e *RuleEvaluator) countEvents(rule *models.AlertRule, containerID string) (int64, error) {
+	since := time.Now().Add(-time.Duration(rule.EventWindow) * time.Second)
+
+	var count int64
+	err := e.db.Model(&models.ContainerEvent{}).
+		Where("container_id = ? AND type = ? AND time > ?", containerID, rule.Event, since).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count %s events: %v", rule.Event, err)
+	}
+	return count, nil
+}
+
+// metricValues maps the metric names usable in rules and expressions to
+// the stats field they read.
+var metricValues = map[models.Metric]func(*models.ContainerStats) float64{
+	models.MetricCPUUsage:            func(s *models.ContainerStats) float64 { return s.CPUPercent },
+	models.MetricMemoryUsage:         func(s *models.ContainerStats) float64 { return s.MemoryPercent },
+	models.MetricDiskIO:              func(s *models.ContainerStats) float64 { return float64(s.DiskIOTotal) },
+	models.MetricNetworkIO:           func(s *models.ContainerStats) float64 { return float64(s.NetworkTotal) },
+	models.MetricDiskIORate:          func(s *models.ContainerStats) float64 { return s.DiskIORate },
+	models.MetricDiskReadRate:        func(s *models.ContainerStats) float64 { return s.BlockReadRate },
+	models.MetricDiskWriteRate:       func(s *models.ContainerStats) float64 { return s.BlockWriteRate },
+	models.MetricNetworkIORate:       func(s *models.ContainerStats) float64 { return s.NetworkRate },
+	models.MetricNetworkRxRate:       func(s *models.ContainerStats) float64 { return s.NetworkRxRate },
+	models.MetricNetworkTxRate:       func(s *models.ContainerStats) float64 { return s.NetworkTxRate },
+	models.MetricNetworkRxPacketRate: func(s *models.ContainerStats) float64 { return s.NetworkRxPacketRate },
+	models.MetricNetworkTxPacketRate: func(s *models.ContainerStats) float64 { return s.NetworkTxPacketRate },
+	models.MetricNetworkRxErrorRate:  func(s *models.ContainerStats) float64 { return s.NetworkRxErrorRate },
+	models.MetricNetworkTxErrorRate:  func(s *models.ContainerStats) float64 { return s.NetworkTxErrorRate },
+	models.MetricHealthUnhealthy: func(s *models.ContainerStats) float64 {
+		if s.Health == "unhealthy" {
+			return 1
+		}
 		return 0
+	},
+	models.MetricHealthFailingStreak: func(s *models.ContainerStats) float64 { return float64(s.HealthFailingStreak) },
+
+	// Raw values, only available in expressions
+	"memory_usage": func(s *models.ContainerStats) float64 { return float64(s.MemoryUsage) },
+	"memory_limit": func(s *models.ContainerStats) float64 { return float64(s.MemoryLimit) },
+	"network_rx":   func(s *models.ContainerStats) float64 { return float64(s.NetworkRx) },
+	"network_tx":   func(s *models.ContainerStats) float64 { return float64(s.NetworkTx) },
+	"block_read":   func(s *models.ContainerStats) float64 { return float64(s.BlockRead) },
+	"block_write":  func(s *models.ContainerStats) float64 { return float64(s.BlockWrite) },
+	"pids":         func(s *models.ContainerStats) float64 { return float64(s.PIDs) },
+}
+
+func (e *RuleEvaluator) extractMetricValue(metric models.Metric, stats *models.ContainerStats) float64 {
+	if get, ok := metricValues[metric]; ok {
+		return get(stats)
+	}
+	return 0
+}
+
+// ruleMetric describes what a rule measures, for the alert's metric field
+func ruleMetric(rule *models.AlertRule) string {
+	if rule.Expression != "" {
+		return rule.Expression
+	}
+	if rule.Event != "" {
+		return "event:" + string(rule.Event)
+	}
+	return string(rule.Metric)
+}
+
+// maxProbeOutput limits how much of a health probe's output goes into an
+// alert message
+const maxProbeOutput = 500
+
+// usesHealth reports whether a rule reads a health check metric
+func (e *RuleEvaluator) usesHealth(rule *models.AlertRule) bool {
+	if rule.Expression == "" {
+		return rule.Metric == models.MetricHealthUnhealthy || rule.Metric == models.MetricHealthFailingStreak
+	}
+	expr, err := e.expression(rule)
+	if err != nil {
+		return false
 	}
+	for _, metric := range expr.Metrics() {
+		if metric == string(models.MetricHealthUnhealthy) || metric == string(models.MetricHealthFailingStreak) {
+			return true
+		}
+	}
+	return false
 }
 
-func (e *RuleEvaluator) formatAlertMessage(rule *models.AlertRule, currentValue float64) string {
+func (e *RuleEvaluator) formatAlertMessage(rule *models.AlertRule, stats *models.ContainerStats, currentValue float64, explanation []string) string {
+	message := e.formatConditionMessage(rule, stats, currentValue, explanation)
+
+	// Health alerts carry the output of the failing probe
+	if stats.HealthOutput != "" && e.usesHealth(rule) {
+		output := strings.TrimSpace(stats.HealthOutput)
+		if len(output) > maxProbeOutput {
+			output = output[:maxProbeOutput] + "..."
+		}
+		message += fmt.Sprintf(" (health %s, %d failing probes, last probe output: %s)",
+			stats.Health, stats.HealthFailingStreak, output)
+	}
+	return message
+}
+
+func (e *RuleEvaluator) formatConditionMessage(rule *models.AlertRule, stats *models.ContainerStats, currentValue float64, explanation []string) string {
+	if rule.Expression != "" {
+		return fmt.Sprintf("Alert: %s - %s for container %s",
+			rule.Name,
+			strings.Join(explanation, "; "),
+			stats.ContainerName)
+	}
+	if rule.Event != "" {
+		return fmt.Sprintf("Alert: %s - %d %s events in the last %s (threshold: %s %.0f) for container %s",
+			rule.Name,
+			int(currentValue),
+			rule.Event,
+			time.Duration(rule.EventWindow)*time.Second,
+			rule.Operator,
+			rule.Threshold,
+			stats.ContainerName)
+	}
 	return fmt.Sprintf("Alert: %s - %s is %.2f (threshold: %.2f) for container %s",
 		rule.Name,
 		rule.Metric,
 		currentValue,
 		rule.Threshold,
-		rule.ContainerName)
+		stats.ContainerName)
 }
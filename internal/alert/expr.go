@@ -0,0 +1,789 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"containereye/internal/models"
+	"containereye/internal/retention"
+)
+
+// MaxExpressionWindow is the longest aggregation window an expression may
+// use. It bounds the stats history the evaluator keeps in memory.
+const MaxExpressionWindow = time.Hour
+
+// ErrInsufficientData is returned when a window function does not have
+// enough samples yet, e.g. right after a container started. The condition
+// is then treated as unknown rather than as clear.
+var ErrInsufficientData = errors.New("insufficient data")
+
+// ParseError reports a syntax or type error in an expression
+type ParseError struct {
+	Pos int // Byte offset in the expression, 0-based
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("at position %d: %s", e.Pos+1, e.Msg)
+}
+
+// EvalError explains which part of an expression failed to evaluate
+type EvalError struct {
+	Expr string
+	Err  error
+}
+
+func (e *EvalError) Error() string {
+	return fmt.Sprintf("evaluating %s: %v", e.Expr, e.Err)
+}
+
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
+
+// Expression is a compiled rule condition such as
+// "avg(cpu_percent, 5m) > 80 and memory_percent > 70".
+type Expression struct {
+	source string
+	root   exprNode
+}
+
+// ExpressionResult is the outcome of evaluating an expression on a container
+type ExpressionResult struct {
+	Matched     bool
+	Value       float64  // Left hand side of the first comparison, used as the alert value
+	Explanation []string // Each evaluated comparison with its operand values
+}
+
+// CompileExpression parses and type checks an expression. The expression
+// must evaluate to a boolean.
+func CompileExpression(source string) (*Expression, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+	if root.kind() != kindBool {
+		return nil, &ParseError{Pos: 0, Msg: "expression must be a condition, e.g. cpu_percent > 80"}
+	}
+
+	return &Expression{source: source, root: root}, nil
+}
+
+func (e *Expression) String() string {
+	return e.root.String()
+}
+
+// MaxWindow returns the longest aggregation window the expression uses
+func (e *Expression) MaxWindow() time.Duration {
+	var max time.Duration
+	walk(e.root, func(n exprNode) {
+		if c, ok := n.(*callNode); ok && c.window > max {
+			max = c.window
+		}
+	})
+	return max
+}
+
+// Metrics returns the metrics the expression refers to, sorted
+func (e *Expression) Metrics() []string {
+	seen := make(map[string]bool)
+	walk(e.root, func(n exprNode) {
+		switch n := n.(type) {
+		case *metricNode:
+			seen[n.name] = true
+		case *callNode:
+			seen[n.metric] = true
+		}
+	})
+
+	metrics := make([]string, 0, len(seen))
+	for m := range seen {
+		metrics = append(metrics, m)
+	}
+	sort.Strings(metrics)
+	return metrics
+}
+
+// Evaluate runs the expression against the current stats of a container
+// and its recent history, oldest first. History may include current.
+func (e *Expression) Evaluate(current *models.ContainerStats, history []*models.ContainerStats) (*ExpressionResult, error) {
+	ctx := &evalContext{current: current, history: history}
+	v, err := e.root.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &ExpressionResult{Matched: v != 0, Value: ctx.value, Explanation: ctx.trace}, nil
+}
+
+type evalContext struct {
+	current  *models.ContainerStats
+	history  []*models.ContainerStats
+	trace    []string
+	value    float64
+	hasValue bool
+}
+
+// window returns the values of a metric sampled within d of the current
+// sample, oldest first.
+func (ctx *evalContext) window(metric string, d time.Duration) ([]float64, []time.Time) {
+	getter := metricValues[models.Metric(metric)]
+	since := ctx.current.Timestamp.Add(-d)
+
+	var values []float64
+	var times []time.Time
+	currentSeen := false
+	for _, s := range ctx.history {
+		if !s.Timestamp.After(since) || s.Timestamp.After(ctx.current.Timestamp) {
+			continue
+		}
+		if s == ctx.current {
+			currentSeen = true
+		}
+		values = append(values, getter(s))
+		times = append(times, s.Timestamp)
+	}
+	if !currentSeen {
+		values = append(values, getter(ctx.current))
+		times = append(times, ctx.current.Timestamp)
+	}
+	return values, times
+}
+
+type exprKind int
+
+const (
+	kindNumber exprKind = iota
+	kindBool
+)
+
+func (k exprKind) String() string {
+	if k == kindBool {
+		return "condition"
+	}
+	return "number"
+}
+
+// exprNode is a node of the expression tree. Conditions evaluate to 1 or 0.
+type exprNode interface {
+	kind() exprKind
+	eval(ctx *evalContext) (float64, error)
+	String() string
+}
+
+func walk(n exprNode, fn func(exprNode)) {
+	fn(n)
+	switch n := n.(type) {
+	case *unaryNode:
+		walk(n.x, fn)
+	case *binaryNode:
+		walk(n.left, fn)
+		walk(n.right, fn)
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type numberNode struct {
+	value float64
+	text  string
+}
+
+func (n *numberNode) kind() exprKind                     { return kindNumber }
+func (n *numberNode) eval(*evalContext) (float64, error) { return n.value, nil }
+func (n *numberNode) String() string                     { return n.text }
+
+type metricNode struct {
+	name string
+}
+
+func (n *metricNode) kind() exprKind { return kindNumber }
+func (n *metricNode) String() string { return n.name }
+
+func (n *metricNode) eval(ctx *evalContext) (float64, error) {
+	return metricValues[models.Metric(n.name)](ctx.current), nil
+}
+
+type unaryNode struct {
+	op string // "-" or "not"
+	x  exprNode
+}
+
+func (n *unaryNode) kind() exprKind {
+	if n.op == "not" {
+		return kindBool
+	}
+	return kindNumber
+}
+
+func (n *unaryNode) String() string {
+	x := n.x.String()
+	if _, ok := n.x.(*binaryNode); ok {
+		x = "(" + x + ")"
+	}
+	if n.op == "not" {
+		return "not " + x
+	}
+	return "-" + x
+}
+
+func (n *unaryNode) eval(ctx *evalContext) (float64, error) {
+	v, err := n.x.eval(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if n.op == "not" {
+		return boolValue(v == 0), nil
+	}
+	return -v, nil
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *binaryNode) kind() exprKind {
+	switch n.op {
+	case "+", "-", "*", "/", "%":
+		return kindNumber
+	}
+	return kindBool
+}
+
+func (n *binaryNode) String() string {
+	return fmt.Sprintf("%s %s %s", operand(n.left, n.op, false), n.op, operand(n.right, n.op, true))
+}
+
+// operand parenthesizes nested nodes that bind less tightly than op. The
+// parser nests left, so a right operand of equal precedence had parentheses.
+func operand(n exprNode, op string, right bool) string {
+	b, ok := n.(*binaryNode)
+	if ok && (precedence[b.op] < precedence[op] || right && precedence[b.op] == precedence[op]) {
+		return "(" + b.String() + ")"
+	}
+	return n.String()
+}
+
+func (n *binaryNode) eval(ctx *evalContext) (float64, error) {
+	l, err := n.left.eval(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Short-circuit so a decided condition never needs the other side's data
+	switch n.op {
+	case "and":
+		if l == 0 {
+			return 0, nil
+		}
+		r, err := n.right.eval(ctx)
+		return boolValue(r != 0), err
+	case "or":
+		if l != 0 {
+			return 1, nil
+		}
+		r, err := n.right.eval(ctx)
+		return boolValue(r != 0), err
+	}
+
+	r, err := n.right.eval(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/", "%":
+		if r == 0 {
+			return 0, &EvalError{Expr: n.String(), Err: fmt.Errorf("division by zero (%s is 0)", n.right)}
+		}
+		if n.op == "%" {
+			return math.Mod(l, r), nil
+		}
+		return l / r, nil
+	}
+
+	var result bool
+	switch n.op {
+	case ">":
+		result = l > r
+	case "<":
+		result = l < r
+	case ">=":
+		result = l >= r
+	case "<=":
+		result = l <= r
+	case "==":
+		result = l == r
+	case "!=":
+		result = l != r
+	}
+
+	if !ctx.hasValue {
+		ctx.value, ctx.hasValue = l, true
+	}
+	ctx.trace = append(ctx.trace, fmt.Sprintf("%s %s %s", describe(n.left, l), n.op, describe(n.right, r)))
+	return boolValue(result), nil
+}
+
+// describe shows an operand with its value unless it is a literal
+func describe(n exprNode, v float64) string {
+	if _, ok := n.(*numberNode); ok {
+		return n.String()
+	}
+	return fmt.Sprintf("%s (%s)", n, strconv.FormatFloat(v, 'f', 2, 64))
+}
+
+// windowFunc computes an aggregate over the samples of a window
+type windowFunc struct {
+	minSamples int
+	hasArg     bool // Takes a third numeric argument
+	apply      func(values []float64, times []time.Time, arg float64) float64
+}
+
+var windowFuncs = map[string]windowFunc{
+	"avg": {minSamples: 1, apply: func(v []float64, _ []time.Time, _ float64) float64 {
+		var sum float64
+		for _, x := range v {
+			sum += x
+		}
+		return sum / float64(len(v))
+	}},
+	"min": {minSamples: 1, apply: func(v []float64, _ []time.Time, _ float64) float64 {
+		min := v[0]
+		for _, x := range v[1:] {
+			min = math.Min(min, x)
+		}
+		return min
+	}},
+	"max": {minSamples: 1, apply: func(v []float64, _ []time.Time, _ float64) float64 {
+		max := v[0]
+		for _, x := range v[1:] {
+			max = math.Max(max, x)
+		}
+		return max
+	}},
+	"sum": {minSamples: 1, apply: func(v []float64, _ []time.Time, _ float64) float64 {
+		var sum float64
+		for _, x := range v {
+			sum += x
+		}
+		return sum
+	}},
+	"count": {minSamples: 0, apply: func(v []float64, _ []time.Time, _ float64) float64 {
+		return float64(len(v))
+	}},
+	"delta": {minSamples: 2, apply: func(v []float64, _ []time.Time, _ float64) float64 {
+		return v[len(v)-1] - v[0]
+	}},
+	// rate is the per-second increase of a counter, ignoring resets
+	"rate": {minSamples: 2, apply: func(v []float64, t []time.Time, _ float64) float64 {
+		elapsed := t[len(t)-1].Sub(t[0]).Seconds()
+		if elapsed <= 0 {
+			return 0
+		}
+		var increase float64
+		for i := 1; i < len(v); i++ {
+			if d := v[i] - v[i-1]; d > 0 {
+				increase += d
+			}
+		}
+		return increase / elapsed
+	}},
+	"percentile": {minSamples: 1, hasArg: true, apply: func(v []float64, _ []time.Time, q float64) float64 {
+		return retention.Percentile(v, q)
+	}},
+	"p50": {minSamples: 1, apply: fixedPercentile(50)},
+	"p90": {minSamples: 1, apply: fixedPercentile(90)},
+	"p95": {minSamples: 1, apply: fixedPercentile(95)},
+	"p99": {minSamples: 1, apply: fixedPercentile(99)},
+}
+
+func fixedPercentile(q float64) func([]float64, []time.Time, float64) float64 {
+	return func(v []float64, _ []time.Time, _ float64) float64 {
+		return retention.Percentile(v, q)
+	}
+}
+
+type callNode struct {
+	fn     string
+	metric string
+	window time.Duration
+	arg    float64
+	text   string
+}
+
+func (n *callNode) kind() exprKind { return kindNumber }
+func (n *callNode) String() string { return n.text }
+
+func (n *callNode) eval(ctx *evalContext) (float64, error) {
+	f := windowFuncs[n.fn]
+	values, times := ctx.window(n.metric, n.window)
+	if len(values) < f.minSamples {
+		return 0, &EvalError{Expr: n.text, Err: fmt.Errorf("%w: needs %d samples in the last %s, have %d",
+			ErrInsufficientData, f.minSamples, n.window, len(values))}
+	}
+	return f.apply(values, times, n.arg), nil
+}
+
+// Lexer
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokDuration
+	tokIdent
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{tokComma, ",", i})
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			start := i
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			// A unit suffix makes it a duration, e.g. 5m or 1h30m
+			if i < len(src) && unicode.IsLetter(rune(src[i])) {
+				for i < len(src) && (unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i])) || src[i] == '.') {
+					i++
+				}
+				if _, err := time.ParseDuration(src[start:i]); err != nil {
+					return nil, &ParseError{Pos: start, Msg: fmt.Sprintf("invalid duration %q", src[start:i])}
+				}
+				tokens = append(tokens, token{tokDuration, src[start:i], start})
+				continue
+			}
+			if _, err := strconv.ParseFloat(src[start:i], 64); err != nil {
+				return nil, &ParseError{Pos: start, Msg: fmt.Sprintf("invalid number %q", src[start:i])}
+			}
+			tokens = append(tokens, token{tokNumber, src[start:i], start})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(src) && (unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i])) || src[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, src[start:i], start})
+		default:
+			op := ""
+			for _, candidate := range []string{">=", "<=", "==", "!=", "&&", "||", ">", "<", "+", "-", "*", "/", "%", "!"} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, &ParseError{Pos: i, Msg: fmt.Sprintf("unexpected character %q", c)}
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokEOF, "end of expression", len(src)}), nil
+}
+
+// Parser
+
+var precedence = map[string]int{
+	"or": 1, "and": 2,
+	">": 3, "<": 3, ">=": 3, "<=": 3, "==": 3, "!=": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5, "%": 5,
+}
+
+// Symbol aliases accepted for the logical keywords
+var logicalAliases = map[string]string{"&&": "and", "||": "or", "!": "not"}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// keyword returns the logical keyword a token stands for, if any
+func keyword(tok token) string {
+	if tok.kind == tokIdent {
+		switch strings.ToLower(tok.text) {
+		case "and", "or", "not":
+			return strings.ToLower(tok.text)
+		}
+	}
+	if tok.kind == tokOp {
+		return logicalAliases[tok.text]
+	}
+	return ""
+}
+
+func (p *parser) parseOr() (exprNode, error) {
+	return p.parseLogical("or", p.parseAnd)
+}
+
+func (p *parser) parseAnd() (exprNode, error) {
+	return p.parseLogical("and", p.parseNot)
+}
+
+func (p *parser) parseLogical(op string, operand func() (exprNode, error)) (exprNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for keyword(p.peek()) == op {
+		tok := p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if err := expectKind(left, kindBool, tok, "left"); err != nil {
+			return nil, err
+		}
+		if err := expectKind(right, kindBool, tok, "right"); err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (exprNode, error) {
+	if keyword(p.peek()) == "not" {
+		tok := p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if err := expectKind(x, kindBool, tok, "operand"); err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: "not", x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (exprNode, error) {
+	left, err := p.parseArith(4)
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	if tok.kind != tokOp || precedence[tok.text] != 3 {
+		return left, nil
+	}
+	p.next()
+
+	right, err := p.parseArith(4)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectKind(left, kindNumber, tok, "left"); err != nil {
+		return nil, err
+	}
+	if err := expectKind(right, kindNumber, tok, "right"); err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind == tokOp && precedence[next.text] == 3 {
+		return nil, &ParseError{Pos: next.pos, Msg: "comparisons cannot be chained, combine them with and/or"}
+	}
+	return &binaryNode{op: tok.text, left: left, right: right}, nil
+}
+
+// parseArith parses + - (level 4) and * / % (level 5)
+func (p *parser) parseArith(level int) (exprNode, error) {
+	operand := p.parseUnary
+	if level == 4 {
+		operand = func() (exprNode, error) { return p.parseArith(5) }
+	}
+
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok.kind != tokOp || precedence[tok.text] != level {
+			return left, nil
+		}
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if err := expectKind(left, kindNumber, tok, "left"); err != nil {
+			return nil, err
+		}
+		if err := expectKind(right, kindNumber, tok, "right"); err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (exprNode, error) {
+	if tok := p.peek(); tok.kind == tokOp && tok.text == "-" {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if err := expectKind(x, kindNumber, tok, "operand"); err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: "-", x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (exprNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		v, _ := strconv.ParseFloat(tok.text, 64)
+		return &numberNode{value: v, text: tok.text}, nil
+	case tokLParen:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, &ParseError{Pos: closing.pos, Msg: fmt.Sprintf("expected ')' but found %q", closing.text)}
+		}
+		return x, nil
+	case tokIdent:
+		if keyword(tok) != "" {
+			return nil, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+		}
+		if p.peek().kind == tokLParen {
+			return p.parseCall(tok)
+		}
+		if _, ok := metricValues[models.Metric(tok.text)]; !ok {
+			return nil, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("unknown metric %q", tok.text)}
+		}
+		return &metricNode{name: tok.text}, nil
+	case tokDuration:
+		return nil, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("duration %s is only allowed as a window argument", tok.text)}
+	case tokEOF:
+		return nil, &ParseError{Pos: tok.pos, Msg: "unexpected end of expression"}
+	}
+	return nil, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+}
+
+// parseCall parses fn(metric, window[, arg])
+func (p *parser) parseCall(name token) (exprNode, error) {
+	f, ok := windowFuncs[strings.ToLower(name.text)]
+	if !ok {
+		return nil, &ParseError{Pos: name.pos, Msg: fmt.Sprintf("unknown function %q", name.text)}
+	}
+	call := &callNode{fn: strings.ToLower(name.text)}
+	p.next() // (
+
+	metric := p.next()
+	if metric.kind != tokIdent {
+		return nil, &ParseError{Pos: metric.pos, Msg: fmt.Sprintf("%s expects a metric as its first argument", call.fn)}
+	}
+	if _, ok := metricValues[models.Metric(metric.text)]; !ok {
+		return nil, &ParseError{Pos: metric.pos, Msg: fmt.Sprintf("unknown metric %q", metric.text)}
+	}
+	call.metric = metric.text
+
+	if comma := p.next(); comma.kind != tokComma {
+		return nil, &ParseError{Pos: comma.pos, Msg: fmt.Sprintf("%s expects a window such as 5m as its second argument", call.fn)}
+	}
+	window := p.next()
+	if window.kind != tokDuration {
+		return nil, &ParseError{Pos: window.pos, Msg: fmt.Sprintf("%s expects a window such as 5m as its second argument", call.fn)}
+	}
+	call.window, _ = time.ParseDuration(window.text)
+	if call.window <= 0 || call.window > MaxExpressionWindow {
+		return nil, &ParseError{Pos: window.pos, Msg: fmt.Sprintf("window must be between 1s and %s", MaxExpressionWindow)}
+	}
+	args := []string{call.metric, window.text}
+
+	if f.hasArg {
+		if comma := p.next(); comma.kind != tokComma {
+			return nil, &ParseError{Pos: comma.pos, Msg: fmt.Sprintf("%s expects a third argument", call.fn)}
+		}
+		arg := p.next()
+		if arg.kind != tokNumber {
+			return nil, &ParseError{Pos: arg.pos, Msg: fmt.Sprintf("%s expects a number as its third argument", call.fn)}
+		}
+		call.arg, _ = strconv.ParseFloat(arg.text, 64)
+		if call.fn == "percentile" && (call.arg < 0 || call.arg > 100) {
+			return nil, &ParseError{Pos: arg.pos, Msg: "percentile must be between 0 and 100"}
+		}
+		args = append(args, arg.text)
+	}
+
+	if closing := p.next(); closing.kind != tokRParen {
+		return nil, &ParseError{Pos: closing.pos, Msg: fmt.Sprintf("expected ')' but found %q", closing.text)}
+	}
+	call.text = fmt.Sprintf("%s(%s)", call.fn, strings.Join(args, ", "))
+	return call, nil
+}
+
+func expectKind(n exprNode, want exprKind, op token, side string) error {
+	if n.kind() == want {
+		return nil
+	}
+	return &ParseError{Pos: op.pos, Msg: fmt.Sprintf("%s operand of %q must be a %s, got %s", side, op.text, want, n)}
+}
@@ -2,26 +2,18 @@ package alert
 
 import (
 	"fmt"
-	"sync"
 	"time"
 
 	"containereye/internal/models"
 	"gorm.io/gorm"
 )
 
-type AlertStatus string
-
-const (
-	AlertStatusNew       AlertStatus = "NEW"
-	AlertStatusAcked    AlertStatus = "ACKNOWLEDGED"
-	AlertStatusResolved AlertStatus = "RESOLVED"
-	AlertStatusClosed   AlertStatus = "CLOSED"
-)
-
+// AlertHandler processes alerts coming from outside the rule evaluator and
+// answers queries about open alerts. All state lives in the database and
+// every status change goes through the AlertManager lifecycle.
 type AlertHandler struct {
-	db     *gorm.DB
-	mutex  sync.RWMutex
-	alerts map[uint]*models.Alert
+	db           *gorm.DB
+	alertManager *AlertManager
 }
 
 type AlertUpdate struct {
@@ -32,124 +24,51 @@ type AlertUpdate struct {
 	UpdatedAt   time.Time
 }
 
-func NewAlertHandler(db *gorm.DB) *AlertHandler {
+func NewAlertHandler(alertManager *AlertManager) *AlertHandler {
 	return &AlertHandler{
-		db:     db,
-		alerts: make(map[uint]*models.Alert),
+		db:           alertManager.db,
+		alertManager: alertManager,
 	}
 }
 
+// HandleAlert records an alert, deduplicating it against the open alert
+// with the same fingerprint. Alerts reported as resolved close the open
+// alert instead of creating a new one.
 func (h *AlertHandler) HandleAlert(alert *models.Alert) error {
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
-
-	// Check if alert already exists
-	if existing, ok := h.alerts[alert.ID]; ok {
-		// Update existing alert
-		existing.Value = alert.Value
-		existing.CurrentValue = alert.CurrentValue
-		if alert.Status == models.AlertStatusResolved {
-			existing.Status = models.AlertStatusResolved
-			existing.ResolvedAt = time.Now()
-		}
-		return h.updateAlert(existing)
+	if alert.Fingerprint == "" {
+		alert.Fingerprint = Fingerprint(alert.RuleID, alert.ContainerID)
 	}
 
-	// Set initial status
-	alert.Status = models.AlertStatusPending
-	alert.StartTime = time.Now()
-
-	// Save to database
-	if err := h.db.Create(alert).Error; err != nil {
-		return fmt.Errorf("failed to create alert: %v", err)
+	if alert.Status == models.AlertStatusResolved {
+		_, err := h.alertManager.AutoResolveAlert(alert.Fingerprint)
+		return err
 	}
 
-	// Add to active alerts
-	h.alerts[alert.ID] = alert
+	if alert.StartTime.IsZero() {
+		alert.StartTime = time.Now()
+	}
 
-	return nil
+	_, err := h.alertManager.FireAlert(alert, 0)
+	return err
 }
 
 func (h *AlertHandler) UpdateAlertStatus(update AlertUpdate) error {
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
-
-	alert, ok := h.alerts[update.ID]
-	if !ok {
+	var alert models.Alert
+	if err := h.db.First(&alert, update.ID).Error; err != nil {
 		return fmt.Errorf("alert not found: %d", update.ID)
 	}
 
-	// Update alert status
-	alert.Status = update.Status
-	alert.AcknowledgedBy = update.Handler
-	alert.Message = update.Comment
-	alert.UpdatedAt = update.UpdatedAt
-
-	// Update timestamps based on status
-	switch update.Status {
-	case models.AlertStatusAcknowledged:
-		alert.AcknowledgedAt = update.UpdatedAt
-	case models.AlertStatusResolved:
-		alert.ResolvedAt = update.UpdatedAt
-	}
-
-	// Save to database
-	if err := h.updateAlert(alert); err != nil {
-		return err
-	}
-
-	// Remove from active alerts if resolved
-	if update.Status == models.AlertStatusResolved {
-		delete(h.alerts, alert.ID)
+	if update.Comment != "" {
+		alert.Message = update.Comment
 	}
 
-	return nil
-}
-
-func (h *AlertHandler) CheckEscalations() []models.Alert {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
-
-	now := time.Now()
-	var needEscalation []models.Alert
-
-	for _, alert := range h.alerts {
-		// Escalate if alert has been active for more than the threshold time
-		if alert.Status == models.AlertStatusActive {
-			threshold := h.getEscalationThreshold(alert.Level)
-			if now.Sub(alert.StartTime) > threshold {
-				needEscalation = append(needEscalation, *alert)
-			}
-		}
-	}
-
-	return needEscalation
+	return h.alertManager.transitionAlert(&alert, update.Status, update.Handler)
 }
 
 func (h *AlertHandler) GetActiveAlerts() []models.Alert {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
-
-	alerts := make([]models.Alert, 0, len(h.alerts))
-	for _, alert := range h.alerts {
-		alerts = append(alerts, *alert)
+	var alerts []models.Alert
+	if err := h.db.Where("status IN ?", openStatuses).Find(&alerts).Error; err != nil {
+		return nil
 	}
 	return alerts
 }
-
-// Internal helper functions
-
-func (h *AlertHandler) updateAlert(alert *models.Alert) error {
-	return h.db.Save(alert).Error
-}
-
-func (h *AlertHandler) getEscalationThreshold(level models.AlertLevel) time.Duration {
-	switch level {
-	case models.AlertLevelCritical:
-		return 15 * time.Minute
-	case models.AlertLevelWarning:
-		return 30 * time.Minute
-	default:
-		return 1 * time.Hour
-	}
-}
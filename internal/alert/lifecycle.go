@@ -0,0 +1,226 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"containereye/internal/models"
+	"gorm.io/gorm"
+)
+
+// systemActor is recorded as the resolver of alerts closed automatically
+const systemActor = "system"
+
+// ErrInvalidTransition is returned when an alert cannot move to the
+// requested status, e.g. acknowledging an alert that is already resolved.
+var ErrInvalidTransition = errors.New("invalid alert transition")
+
+// openStatuses are the alert states that still count as an ongoing problem
+var openStatuses = []models.AlertStatus{
+	models.AlertStatusPending,
+	models.AlertStatusActive,
+	models.AlertStatusAcknowledged,
+}
+
+// validTransitions lists the states an alert may move to from each state.
+// RESOLVED is terminal: a condition that comes back opens a new alert.
+var validTransitions = map[models.AlertStatus][]models.AlertStatus{
+	models.AlertStatusPending:      {models.AlertStatusActive, models.AlertStatusResolved},
+	models.AlertStatusActive:       {models.AlertStatusAcknowledged, models.AlertStatusResolved},
+	models.AlertStatusAcknowledged: {models.AlertStatusResolved},
+}
+
+// Fingerprint identifies the alert stream of a rule on a single container.
+// At most one open alert exists per fingerprint at any time.
+func Fingerprint(ruleID uint, containerID string) string {
+	return fmt.Sprintf("%d:%s", ruleID, containerID)
+}
+
+// canTransition reports whether an alert may move from one status to another
+func canTransition(from, to models.AlertStatus) bool {
+	for _, next := range validTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// applyTransition moves alert to the given status, stamping the fields that
+// belong to the new state. It does not persist the alert.
+func applyTransition(alert *models.Alert, to models.AlertStatus, actor string, at time.Time) error {
+	if !canTransition(alert.Status, to) {
+		return fmt.Errorf("%w from %s to %s", ErrInvalidTransition, alert.Status, to)
+	}
+
+	alert.Status = to
+	switch to {
+	case models.AlertStatusAcknowledged:
+		alert.AcknowledgedBy = actor
+		alert.AcknowledgedAt = at
+	case models.AlertStatusResolved:
+		alert.ResolvedBy = actor
+		alert.ResolvedAt = at
+		alert.EndTime = at
+	}
+	return nil
+}
+
+// FindOpenAlert returns the open alert for a fingerprint, or nil if none
+func (am *AlertManager) FindOpenAlert(fingerprint string) (*models.Alert, error) {
+	// Find instead of First: a missing alert is the common case, not an error
+	var alerts []models.Alert
+	err := am.db.Where("fingerprint = ? AND status IN ?", fingerprint, openStatuses).
+		Order("id desc").Limit(1).Find(&alerts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find open alert: %v", err)
+	}
+	if len(alerts) == 0 {
+		return nil, nil
+	}
+	return &alerts[0], nil
+}
+
+// FireAlert records a violation for the alert's fingerprint. If an alert is
+// already open for it, that alert is updated in place and no notification is
+// sent. Otherwise a new alert is created and notified, unless the rule's
+// cooldown period since the last alert for the fingerprint has not elapsed.
+// It reports whether a new alert was created.
+func (am *AlertManager) FireAlert(alert *models.Alert, cooldown time.Duration) (bool, error) {
+	now := time.Now()
+
+	existing, err := am.FindOpenAlert(alert.Fingerprint)
+	if err != nil {
+		return false, err
+	}
+
+	if existing != nil {
+		existing.CurrentValue = alert.CurrentValue
+		existing.Value = alert.Value
+		existing.Message = alert.Message
+		existing.Image = alert.Image
+		existing.Labels = alert.Labels
+		existing.LastSeenAt = now
+		existing.OccurrenceCount++
+
+		// A silenced alert that outlives its silence is notified now
+		unsilenced := false
+		if existing.SilenceID != nil {
+			silence, err := am.MatchingSilence(existing)
+			if err != nil {
+				return false, err
+			}
+			if silence != nil {
+				existing.SilenceID = &silence.ID
+			} else {
+				existing.SilenceID = nil
+				unsilenced = true
+			}
+		}
+
+		err := am.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(existing).Error; err != nil {
+				return fmt.Errorf("failed to update alert: %v", err)
+			}
+			if unsilenced {
+				return am.outbox.Enqueue(tx, existing)
+			}
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+		*alert = *existing
+		return false, nil
+	}
+
+	if cooldown > 0 {
+		var last []models.Alert
+		err := am.db.Where("fingerprint = ?", alert.Fingerprint).Order("id desc").Limit(1).Find(&last).Error
+		if err != nil {
+			return false, fmt.Errorf("failed to find previous alert: %v", err)
+		}
+		if len(last) > 0 && now.Sub(last[0].EndTime) < cooldown {
+			return false, nil
+		}
+	}
+
+	alert.Status = models.AlertStatusActive
+	alert.LastSeenAt = now
+	alert.OccurrenceCount = 1
+	if err := am.SendAlert(alert); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AutoResolveAlert resolves the open alert for a fingerprint once its
+// condition has cleared and sends a resolve notification. It reports whether
+// an alert was resolved.
+func (am *AlertManager) AutoResolveAlert(fingerprint string) (bool, error) {
+	alert, err := am.FindOpenAlert(fingerprint)
+	if err != nil || alert == nil {
+		return false, err
+	}
+
+	if err := am.transitionAlert(alert, models.AlertStatusResolved, systemActor); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// transitionAlert applies a status change and persists it, queueing a
+// resolve notification in the same transaction when the alert is closed.
+// Silenced alerts were never notified, so their resolution is not either.
+func (am *AlertManager) transitionAlert(alert *models.Alert, to models.AlertStatus, actor string) error {
+	if err := applyTransition(alert, to, actor, time.Now()); err != nil {
+		return err
+	}
+
+	return am.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(alert).Error; err != nil {
+			return fmt.Errorf("failed to update alert: %v", err)
+		}
+		if to == models.AlertStatusResolved && alert.SilenceID == nil {
+			return am.outbox.Enqueue(tx, alert)
+		}
+		return nil
+	})
+}
+
+// ResolveRuleAlerts closes every open alert of a rule, used when the rule is
+// disabled or deleted and can therefore no longer resolve them itself.
+func (am *AlertManager) ResolveRuleAlerts(ruleID uint) error {
+	var alerts []models.Alert
+	if err := am.db.Where("rule_id = ? AND status IN ?", ruleID, openStatuses).Find(&alerts).Error; err != nil {
+		return fmt.Errorf("failed to find open alerts: %v", err)
+	}
+
+	for i := range alerts {
+		if err := am.transitionAlert(&alerts[i], models.AlertStatusResolved, systemActor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveContainerAlerts closes every open alert of the given containers,
+// used when they are gone and no rule will see them recover.
+func (am *AlertManager) ResolveContainerAlerts(containerIDs []string) error {
+	if len(containerIDs) == 0 {
+		return nil
+	}
+
+	var alerts []models.Alert
+	if err := am.db.Where("container_id IN ? AND status IN ?", containerIDs, openStatuses).Find(&alerts).Error; err != nil {
+		return fmt.Errorf("failed to find open alerts: %v", err)
+	}
+
+	for i := range alerts {
+		if err := am.transitionAlert(&alerts[i], models.AlertStatusResolved, systemActor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package alert
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"math/rand"
 	"os"
 	"time"
@@ -23,16 +25,59 @@ func NewRuleManager(alertManager *AlertManager, db *gorm.DB) *RuleManager {
 	}
 }
 
+// SetDefaultCooldown sets the cooldown of rules without a cooldown period
+func (rm *RuleManager) SetDefaultCooldown(cooldown time.Duration) {
+	rm.evaluator.defaultCooldown = cooldown
+}
+
 func (rm *RuleManager) CreateRule(rule *models.AlertRule) error {
 	return rm.db.Create(rule).Error
 }
 
+// UpdateRule saves a changed rule. Alerts the rule raised before are
+// resolved when it is disabled or its target or condition changes, since
+// the rule would no longer resolve them itself.
 func (rm *RuleManager) UpdateRule(rule *models.AlertRule) error {
-	return rm.db.Save(rule).Error
+	var before models.AlertRule
+	found := true
+	if err := rm.db.First(&before, rule.ID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		found = false
+	} else if err != nil {
+		return err
+	}
+
+	if err := rm.db.Save(rule).Error; err != nil {
+		return err
+	}
+	if found && before.IsEnabled && (!rule.IsEnabled || ruleRedefined(&before, rule)) {
+		return rm.closeRuleAlerts(rule.ID)
+	}
+	return nil
+}
+
+// ruleRedefined reports whether a rule changed the containers it targets
+// or the condition it checks.
+func ruleRedefined(before, after *models.AlertRule) bool {
+	return before.Host != after.Host ||
+		before.ContainerID != after.ContainerID ||
+		before.ContainerName != after.ContainerName ||
+		before.Image != after.Image ||
+		before.ComposeProject != after.ComposeProject ||
+		before.ComposeService != after.ComposeService ||
+		before.LabelSelector != after.LabelSelector ||
+		before.Expression != after.Expression ||
+		before.Event != after.Event ||
+		before.EventWindow != after.EventWindow ||
+		before.Metric != after.Metric ||
+		before.Operator != after.Operator ||
+		before.Threshold != after.Threshold
 }
 
 func (rm *RuleManager) DeleteRule(id uint) error {
-	return rm.db.Delete(&models.AlertRule{}, id).Error
+	if err := rm.db.Delete(&models.AlertRule{}, id).Error; err != nil {
+		return err
+	}
+	return rm.closeRuleAlerts(id)
 }
 
 func (rm *RuleManager) GetRule(id uint) (*models.AlertRule, error) {
@@ -60,30 +105,109 @@ func (rm *RuleManager) EnableRule(id uint) error {
 }
 
 func (rm *RuleManager) DisableRule(id uint) error {
-	return rm.db.Model(&models.AlertRule{}).Where("id = ?", id).Update("is_enabled", false).Error
+	if err := rm.db.Model(&models.AlertRule{}).Where("id = ?", id).Update("is_enabled", false).Error; err != nil {
+		return err
+	}
+	return rm.closeRuleAlerts(id)
+}
+
+// closeRuleAlerts resolves the open alerts of a rule that stopped being
+// evaluated and resets its tracked state.
+func (rm *RuleManager) closeRuleAlerts(id uint) error {
+	rm.evaluator.forgetRule(id)
+	return rm.evaluator.alertManager.ResolveRuleAlerts(id)
+}
+
+// CloseContainerAlerts resolves the open alerts of containers that are gone
+// and resets their tracked state.
+func (rm *RuleManager) CloseContainerAlerts(containerIDs []string) error {
+	rm.evaluator.forgetContainers(containerIDs)
+	return rm.evaluator.alertManager.ResolveContainerAlerts(containerIDs)
+}
+
+// LoadHistory fills the expression windows with the stored stats, so that
+// rules over a window are evaluated right after a restart instead of once
+// the window has been collected again. Windows are at most an hour, well
+// within the retention of raw stats.
+func (rm *RuleManager) LoadHistory() error {
+	enabled := true
+	rules, err := rm.ListRules(&enabled)
+	if err != nil {
+		return fmt.Errorf("failed to fetch rules: %v", err)
+	}
+	window := rm.evaluator.historyWindow(rules)
+	if window == 0 {
+		return nil
+	}
+
+	var stats []*models.ContainerStats
+	if err := rm.db.Where("timestamp >= ?", time.Now().Add(-window)).
+		Order("timestamp").
+		Find(&stats).Error; err != nil {
+		return fmt.Errorf("failed to fetch stats: %v", err)
+	}
+	for _, stat := range stats {
+		rm.evaluator.recordSample(stat, window)
+	}
+	return nil
 }
 
+// EvaluateRules evaluates the enabled rules against the container's stats.
+// Errors of single rules are logged, only failing to load the rules is
+// returned.
 func (rm *RuleManager) EvaluateRules(stats *models.ContainerStats) error {
 	var rules []models.AlertRule
 	if err := rm.db.Where("is_enabled = ?", true).Find(&rules).Error; err != nil {
 		return fmt.Errorf("failed to fetch rules: %v", err)
 	}
 
-	for _, rule := range rules {
+	// Keep as much history as the longest expression window needs
+	rm.evaluator.recordSample(stats, rm.evaluator.historyWindow(rules))
+
+	rm.evaluateAll(rules, stats)
+	return nil
+}
+
+// EvaluateEvent evaluates the event rules for the event's type against the
+// container the event happened to, so they fire without waiting for the
+// next collection. Errors of single rules are logged like in EvaluateRules.
+func (rm *RuleManager) EvaluateEvent(event *models.ContainerEvent) error {
+	var rules []models.AlertRule
+	if err := rm.db.Where("is_enabled = ? AND event = ?", true, event.Type).Find(&rules).Error; err != nil {
+		return fmt.Errorf("failed to fetch rules: %v", err)
+	}
+
+	stats := &models.ContainerStats{
+		HostID:        event.HostID,
+		ContainerID:   event.ContainerID,
+		ContainerName: event.ContainerName,
+		Image:         event.Image,
+		Labels:        event.Labels,
+		Timestamp:     event.Time,
+	}
+	rm.evaluateAll(rules, stats)
+	return nil
+}
+
+// evaluateAll evaluates the rules that target the container. A rule that
+// fails is logged and does not keep the others from being evaluated.
+func (rm *RuleManager) evaluateAll(rules []models.AlertRule, stats *models.ContainerStats) {
+	for i := range rules {
+		rule := &rules[i]
 		// Skip if container targeting doesn't match
-		if rule.ContainerID != "" && rule.ContainerID != stats.ContainerID {
+		targeted, err := RuleTargets(rule, stats)
+		if err != nil {
+			log.Printf("Error evaluating rule %q: invalid target: %v", rule.Name, err)
 			continue
 		}
-		if rule.ContainerName != "" && rule.ContainerName != stats.ContainerName {
+		if !targeted {
 			continue
 		}
 
-		if err := rm.evaluator.EvaluateMetric(&rule, stats); err != nil {
-			return fmt.Errorf("failed to evaluate rule %d: %v", rule.ID, err)
+		if err := rm.evaluator.EvaluateMetric(rule, stats); err != nil {
+			log.Printf("Error evaluating rule %q for container %s: %v", rule.Name, stats.ContainerName, err)
 		}
 	}
-
-	return nil
 }
 
 func (rm *RuleManager) CreateDefaultRules() error {
@@ -97,6 +221,7 @@ func (rm *RuleManager) CreateDefaultRules() error {
 			Duration:    300,
 			Level:       models.AlertLevelWarning,
 			CooldownPeriod: 1800, // 30 minutes
+			RecoveryPeriod: 120,
 		},
 		{
 			Name:        "Critical Memory Usage",
@@ -107,26 +232,29 @@ func (rm *RuleManager) CreateDefaultRules() error {
 			Duration:    180,
 			Level:       models.AlertLevelCritical,
 			CooldownPeriod: 900, // 15 minutes
+			RecoveryPeriod: 120,
 		},
 		{
 			Name:        "High Disk I/O",
 			Description: "Alert when disk I/O is above 100MB/s",
-			Metric:      models.MetricDiskIO,
+			Metric:      models.MetricDiskIORate,
 			Operator:    models.OperatorGT,
 			Threshold:   100 * 1024 * 1024, // 100MB/s
 			Duration:    120,
 			Level:       models.AlertLevelWarning,
 			CooldownPeriod: 1200, // 20 minutes
+			RecoveryPeriod: 120,
 		},
 		{
 			Name:        "High Network Traffic",
 			Description: "Alert when network traffic is above 100MB/s",
-			Metric:      models.MetricNetworkIO,
+			Metric:      models.MetricNetworkIORate,
 			Operator:    models.OperatorGT,
 			Threshold:   100 * 1024 * 1024, // 100MB/s
 			Duration:    120,
 			Level:       models.AlertLevelWarning,
 			CooldownPeriod: 1200, // 20 minutes
+			RecoveryPeriod: 120,
 		},
 	}
 
@@ -161,6 +289,20 @@ func (rm *RuleManager) ImportRulesFromFile(filename string) error {
 	for _, rule := range rules {
 		// Clear ID to ensure new records are created
 		rule.ID = 0
+		if err := ValidateRuleTarget(&rule); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to import rule '%s': %v", rule.Name, err)
+		}
+		if rule.Expression != "" {
+			if _, err := CompileExpression(rule.Expression); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to import rule '%s': invalid expression: %v", rule.Name, err)
+			}
+		}
+		if rule.Event != "" && rule.EventWindow <= 0 {
+			tx.Rollback()
+			return fmt.Errorf("failed to import rule '%s': event window must be positive", rule.Name)
+		}
 		if err := tx.Create(&rule).Error; err != nil {
 			tx.Rollback()
 			return fmt.Errorf("failed to import rule '%s': %v", rule.Name, err)
@@ -188,102 +330,136 @@ func (rm *RuleManager) ExportRulesToFile(filename string) error {
 	return nil
 }
 
+// TestRule dry-runs a rule on generated samples of a test container, one
+// per minute from startTime to endTime, and returns the alerts it would
+// have raised. Nothing is stored or notified: the condition is evaluated on
+// its own and the duration, cooldown and recovery periods are applied to
+// the sample times.
 func (rm *RuleManager) TestRule(rule *models.AlertRule, startTime, endTime time.Time) ([]models.Alert, error) {
-	var alerts []models.Alert
-	
-	// Generate test data points
-	interval := time.Minute
-	for t := startTime; t.Before(endTime); t = t.Add(interval) {
-		stats := &models.ContainerStats{
-			ContainerID:   "test-container",
-			ContainerName: "test-container",
-			Timestamp:     t,
+	if !endTime.After(startTime) {
+		return nil, fmt.Errorf("end time must be after start time")
+	}
+
+	var expr *Expression
+	var window time.Duration
+	if rule.Expression != "" {
+		var err error
+		if expr, err = CompileExpression(rule.Expression); err != nil {
+			return nil, fmt.Errorf("invalid expression %q: %v", rule.Expression, err)
 		}
+		window = expr.MaxWindow()
+	}
 
-		// Set test metric value
-		value := generateRandomValue(0, 100, rule.Threshold)
-		switch rule.Metric {
-		case models.MetricCPUUsage:
-			stats.CPUPercent = value
-		case models.MetricMemoryUsage:
-			stats.MemoryPercent = value
-		case models.MetricDiskIO:
-			stats.DiskIOTotal = uint64(value)
-		case models.MetricNetworkIO:
-			stats.NetworkTotal = uint64(value)
+	var (
+		alerts         []models.Alert
+		history        []*models.ContainerStats
+		open           bool
+		isViolating    bool
+		violationStart time.Time
+		clearSince     time.Time
+		lastEnd        time.Time
+	)
+	cooldown := rm.evaluator.cooldown(rule)
+	duration := time.Duration(rule.Duration) * time.Second
+	recovery := time.Duration(rule.RecoveryPeriod) * time.Second
+
+	for t := startTime; t.Before(endTime); t = t.Add(time.Minute) {
+		stats := testSample(rule, t)
+		history = append(history, stats)
+		for len(history) > 1 && !history[0].Timestamp.After(t.Add(-window)) {
+			history = history[1:]
 		}
 
-		// Evaluate rule with test data
-		if err := rm.evaluator.EvaluateMetric(rule, stats); err != nil {
+		value, violating, explanation, err := rm.evaluator.checkCondition(rule, expr, stats, history)
+		if errors.Is(err, ErrInsufficientData) {
+			continue
+		}
+		if err != nil {
 			return nil, fmt.Errorf("failed to evaluate test data: %v", err)
 		}
-	}
-
-	// Fetch generated alerts
-	if err := rm.db.Where("rule_id = ? AND created_at BETWEEN ? AND ?", 
-		rule.ID, startTime, endTime).Find(&alerts).Error; err != nil {
-		return nil, fmt.Errorf("failed to fetch test alerts: %v", err)
-	}
 
-	return alerts, nil
-}
-
-func (rm *RuleManager) TestRuleWithSampleData(rule *models.AlertRule) ([]models.Alert, error) {
-	// Generate sample data for the last hour
-	endTime := time.Now()
-	startTime := endTime.Add(-1 * time.Hour)
-	interval := time.Minute
-
-	var stats []models.ContainerStats
-	currentTime := startTime
-
-	// Generate sample data points
-	for currentTime.Before(endTime) {
-		// Generate random metric value around the threshold
-		var metricValue float64
-		switch rule.Metric {
-		case models.MetricCPUUsage:
-			metricValue = generateRandomValue(0, 100, rule.Threshold)
-		case models.MetricMemoryUsage:
-			metricValue = generateRandomValue(0, 100, rule.Threshold)
-		case models.MetricDiskIO:
-			metricValue = generateRandomValue(0, 1000*1024*1024, rule.Threshold) // 0-1000MB
-		case models.MetricNetworkIO:
-			metricValue = generateRandomValue(0, 100*1024*1024, rule.Threshold) // 0-100MB/s
+		if violating {
+			if !isViolating {
+				isViolating = true
+				violationStart = t
+			}
+			clearSince = time.Time{}
+
+			if open {
+				last := &alerts[len(alerts)-1]
+				last.CurrentValue = value
+				last.Value = value
+				last.LastSeenAt = t
+				last.OccurrenceCount++
+				continue
+			}
+			if t.Sub(violationStart) < duration || (!lastEnd.IsZero() && t.Sub(lastEnd) < cooldown) {
+				continue
+			}
+
+			alerts = append(alerts, models.Alert{
+				Fingerprint:     Fingerprint(rule.ID, stats.ContainerID),
+				RuleID:          rule.ID,
+				RuleName:        rule.Name,
+				ContainerID:     stats.ContainerID,
+				ContainerName:   stats.ContainerName,
+				Level:           rule.Level,
+				Metric:          ruleMetric(rule),
+				Threshold:       rule.Threshold,
+				CurrentValue:    value,
+				Value:           value,
+				Message:         rm.evaluator.formatAlertMessage(rule, stats, value, explanation),
+				Status:          models.AlertStatusActive,
+				StartTime:       violationStart,
+				LastSeenAt:      t,
+				OccurrenceCount: 1,
+			})
+			open = true
+			continue
 		}
 
-		stat := models.ContainerStats{
-			ContainerID:     "test-container",
-			ContainerName:   "test-container",
-			Timestamp:       currentTime,
-			CPUPercent:      metricValue,
-			MemoryPercent:   metricValue,
-			NetworkTotal:    uint64(metricValue),
-			DiskIOTotal:     uint64(metricValue),
+		if isViolating {
+			isViolating = false
+			clearSince = t
+		}
+		if open && t.Sub(clearSince) >= recovery {
+			if err := applyTransition(&alerts[len(alerts)-1], models.AlertStatusResolved, systemActor, t); err != nil {
+				return nil, err
+			}
+			open = false
+			lastEnd = t
 		}
-		stats = append(stats, stat)
-		currentTime = currentTime.Add(interval)
 	}
 
-	// Create temporary table for test data
-	tempTableName := fmt.Sprintf("temp_container_stats_%d", time.Now().UnixNano())
-	if err := rm.db.Table(tempTableName).AutoMigrate(&models.ContainerStats{}); err != nil {
-		return nil, fmt.Errorf("failed to create temporary table: %v", err)
-	}
-	defer rm.db.Migrator().DropTable(tempTableName)
+	return alerts, nil
+}
 
-	// Insert sample data
-	if err := rm.db.Table(tempTableName).Create(&stats).Error; err != nil {
-		return nil, fmt.Errorf("failed to insert sample data: %v", err)
+// testSample generates the stats of the test container at t, with the
+// rule's metric at a random value that is mostly close to the threshold
+func testSample(rule *models.AlertRule, t time.Time) *models.ContainerStats {
+	var value float64
+	switch rule.Metric {
+	case models.MetricDiskIO:
+		value = generateRandomValue(0, 1000*1024*1024, rule.Threshold) // 0-1000MB
+	case models.MetricNetworkIO:
+		value = generateRandomValue(0, 100*1024*1024, rule.Threshold) // 0-100MB/s
+	case models.MetricDiskIORate, models.MetricNetworkIORate:
+		value = generateRandomValue(0, 200*1024*1024, rule.Threshold) // 0-200MB/s
+	default:
+		value = generateRandomValue(0, 100, rule.Threshold)
 	}
 
-	// Test rule with sample data
-	alerts, err := rm.TestRule(rule, startTime, endTime)
-	if err != nil {
-		return nil, err
+	return &models.ContainerStats{
+		ContainerID:   "test-container",
+		ContainerName: "test-container",
+		Timestamp:     t,
+		CPUPercent:    value,
+		MemoryPercent: value,
+		NetworkTotal:  uint64(value),
+		DiskIOTotal:   uint64(value),
+		NetworkRate:   value,
+		DiskIORate:    value,
 	}
-
-	return alerts, nil
 }
 
 func generateRandomValue(min, max, threshold float64) float64 {
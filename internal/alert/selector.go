@@ -0,0 +1,282 @@
+package alert
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"containereye/internal/models"
+)
+
+// Docker Compose labels identifying a container's project and service
+const (
+	ComposeProjectLabel = "com.docker.compose.project"
+	ComposeServiceLabel = "com.docker.compose.service"
+)
+
+// regexPrefix marks a target pattern as a regular expression instead of a glob
+const regexPrefix = "re:"
+
+var (
+	regexCacheMutex sync.RWMutex
+	regexCache      = make(map[string]*regexp.Regexp)
+)
+
+// compilePattern returns the compiled regular expression of a "re:"
+// pattern, anchored to match the whole value like a glob does, caching it
+// since rules are matched on every collection.
+func compilePattern(expr string) (*regexp.Regexp, error) {
+	regexCacheMutex.RLock()
+	re, ok := regexCache[expr]
+	regexCacheMutex.RUnlock()
+	if ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, err
+	}
+
+	regexCacheMutex.Lock()
+	regexCache[expr] = re
+	regexCacheMutex.Unlock()
+	return re, nil
+}
+
+// MatchPattern matches the whole value against a shell glob, or against a
+// regular expression when the pattern starts with "re:".
+func MatchPattern(pattern, value string) (bool, error) {
+	if strings.HasPrefix(pattern, regexPrefix) {
+		re, err := compilePattern(strings.TrimPrefix(pattern, regexPrefix))
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(value), nil
+	}
+	return path.Match(pattern, value)
+}
+
+func validatePattern(field, pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+	if _, err := MatchPattern(pattern, ""); err != nil {
+		return fmt.Errorf("invalid %s pattern %q: %v", field, pattern, err)
+	}
+	return nil
+}
+
+type selectorOp string
+
+const (
+	selectorEquals       selectorOp = "="
+	selectorNotEquals    selectorOp = "!="
+	selectorIn           selectorOp = "in"
+	selectorNotIn        selectorOp = "notin"
+	selectorExists       selectorOp = "exists"
+	selectorDoesNotExist selectorOp = "!"
+)
+
+type labelRequirement struct {
+	key    string
+	op     selectorOp
+	values []string
+}
+
+func (r labelRequirement) matches(labels map[string]string) bool {
+	value, ok := labels[r.key]
+	switch r.op {
+	case selectorExists:
+		return ok
+	case selectorDoesNotExist:
+		return !ok
+	case selectorEquals:
+		return ok && value == r.values[0]
+	case selectorNotEquals:
+		return !ok || value != r.values[0]
+	case selectorIn:
+		return ok && containsString(r.values, value)
+	case selectorNotIn:
+		return !ok || !containsString(r.values, value)
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// LabelSelector is a list of label requirements that must all hold
+type LabelSelector []labelRequirement
+
+// ParseLabelSelector parses a comma separated list of requirements in the
+// form key=value, key==value, key!=value, key in (a,b), key notin (a,b),
+// key (label present) and !key (label absent).
+func ParseLabelSelector(s string) (LabelSelector, error) {
+	var selector LabelSelector
+	for _, term := range splitSelector(s) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		req, err := parseRequirement(term)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector %q: %v", term, err)
+		}
+		selector = append(selector, req)
+	}
+	return selector, nil
+}
+
+// splitSelector splits on commas outside of parentheses
+func splitSelector(s string) []string {
+	var terms []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, s[start:])
+}
+
+func parseRequirement(term string) (labelRequirement, error) {
+	if strings.HasPrefix(term, "!") {
+		key := strings.TrimSpace(term[1:])
+		if key == "" {
+			return labelRequirement{}, fmt.Errorf("missing label key")
+		}
+		return labelRequirement{key: key, op: selectorDoesNotExist}, nil
+	}
+
+	if i := strings.Index(term, "!="); i >= 0 {
+		return newRequirement(term[:i], selectorNotEquals, term[i+2:])
+	}
+	if i := strings.Index(term, "=="); i >= 0 {
+		return newRequirement(term[:i], selectorEquals, term[i+2:])
+	}
+	if i := strings.Index(term, "="); i >= 0 {
+		return newRequirement(term[:i], selectorEquals, term[i+1:])
+	}
+
+	fields := strings.Fields(term)
+	if len(fields) >= 2 && (fields[1] == "in" || fields[1] == "notin") {
+		op := selectorIn
+		if fields[1] == "notin" {
+			op = selectorNotIn
+		}
+		// The value list follows the key and the operator, either of which
+		// may contain the other, e.g. "kind in (a,b)"
+		rest := strings.TrimSpace(strings.TrimPrefix(term, fields[0]))
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, fields[1]))
+		if !strings.HasPrefix(rest, "(") || !strings.HasSuffix(rest, ")") {
+			return labelRequirement{}, fmt.Errorf("expected a parenthesized value list after %s", fields[1])
+		}
+		var values []string
+		for _, v := range strings.Split(rest[1:len(rest)-1], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			return labelRequirement{}, fmt.Errorf("empty value list")
+		}
+		return labelRequirement{key: fields[0], op: op, values: values}, nil
+	}
+
+	if len(fields) == 1 {
+		return labelRequirement{key: fields[0], op: selectorExists}, nil
+	}
+	return labelRequirement{}, fmt.Errorf("unknown operator")
+}
+
+func newRequirement(key string, op selectorOp, value string) (labelRequirement, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return labelRequirement{}, fmt.Errorf("missing label key")
+	}
+	return labelRequirement{key: key, op: op, values: []string{strings.TrimSpace(value)}}, nil
+}
+
+// Matches reports whether the labels satisfy every requirement
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, req := range s {
+		if !req.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateRuleTarget checks the container selectors of a rule
+func ValidateRuleTarget(rule *models.AlertRule) error {
+	patterns := []struct{ field, pattern string }{
+		{"host", rule.Host},
+		{"container name", rule.ContainerName},
+		{"image", rule.Image},
+		{"compose project", rule.ComposeProject},
+		{"compose service", rule.ComposeService},
+	}
+	for _, p := range patterns {
+		if err := validatePattern(p.field, p.pattern); err != nil {
+			return err
+		}
+	}
+	_, err := ParseLabelSelector(rule.LabelSelector)
+	return err
+}
+
+// RuleTargets reports whether a rule applies to the container the stats
+// were collected from. Every selector that is set must match.
+func RuleTargets(rule *models.AlertRule, stats *models.ContainerStats) (bool, error) {
+	// IDs may be given in their short form
+	if rule.ContainerID != "" && !strings.HasPrefix(stats.ContainerID, rule.ContainerID) {
+		return false, nil
+	}
+
+	// Docker reports names with a leading slash, rules are written without
+	name := strings.TrimPrefix(stats.ContainerName, "/")
+	checks := []struct{ pattern, value string }{
+		{rule.Host, stats.HostID},
+		{strings.TrimPrefix(rule.ContainerName, "/"), name},
+		{rule.Image, stats.Image},
+		{rule.ComposeProject, stats.Labels[ComposeProjectLabel]},
+		{rule.ComposeService, stats.Labels[ComposeServiceLabel]},
+	}
+	for _, c := range checks {
+		if c.pattern == "" {
+			continue
+		}
+		ok, err := MatchPattern(c.pattern, c.value)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	if rule.LabelSelector != "" {
+		selector, err := ParseLabelSelector(rule.LabelSelector)
+		if err != nil {
+			return false, err
+		}
+		if !selector.Matches(stats.Labels) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,74 @@
+package alert
+
+import "testing"
+
+func TestParseLabelSelector(t *testing.T) {
+	labels := map[string]string{
+		"kind":       "web",
+		"maintainer": "ops",
+		"tier":       "frontend",
+	}
+
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"tier=frontend", true},
+		{"tier==backend", false},
+		{"tier!=backend", true},
+		{"tier", true},
+		{"!tier", false},
+		{"!missing", true},
+		{"tier in (frontend, backend)", true},
+		{"tier notin (frontend)", false},
+		// Keys containing the operators
+		{"kind in (web,db)", true},
+		{"kind notin (web)", false},
+		{"maintainer in (ops)", true},
+		{"maintainer notin (dev)", true},
+		{"kind in (db), tier=frontend", false},
+	}
+	for _, test := range tests {
+		selector, err := ParseLabelSelector(test.selector)
+		if err != nil {
+			t.Errorf("ParseLabelSelector(%q): %v", test.selector, err)
+			continue
+		}
+		if got := selector.Matches(labels); got != test.want {
+			t.Errorf("%q matches = %v, want %v", test.selector, got, test.want)
+		}
+	}
+}
+
+func TestParseLabelSelectorErrors(t *testing.T) {
+	for _, selector := range []string{"=value", "kind in", "kind in ()", "kind in a,b", "kind between (a)"} {
+		if _, err := ParseLabelSelector(selector); err == nil {
+			t.Errorf("ParseLabelSelector(%q) succeeded, want an error", selector)
+		}
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"shop-*", "shop-web", true},
+		{"shop-*", "my-shop-web", false},
+		{"re:web", "web", true},
+		{"re:web", "not-a-webserver", false},
+		{"re:shop-(web|api)-[0-9]+", "shop-api-12", true},
+		{"re:^shop-(web|api)-[0-9]+$", "shop-api-12", true},
+		{"re:a|b", "ab", false},
+	}
+	for _, test := range tests {
+		got, err := MatchPattern(test.pattern, test.value)
+		if err != nil {
+			t.Errorf("MatchPattern(%q, %q): %v", test.pattern, test.value, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("MatchPattern(%q, %q) = %v, want %v", test.pattern, test.value, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"containereye/internal/models"
+	"containereye/internal/schedule"
+	"gorm.io/gorm"
+)
+
+// maxWindowDuration bounds recurring maintenance windows, which keeps the
+// lookup of the window's start cheap.
+const maxWindowDuration = 7 * 24 * time.Hour
+
+// ErrSilenceNotFound is returned when a silence does not exist
+var ErrSilenceNotFound = errors.New("silence not found")
+
+// ValidateSilence checks that a silence has at least one matcher, valid
+// patterns and a usable time range or schedule.
+func ValidateSilence(s *models.Silence) error {
+	if s.ContainerName == "" && s.ContainerID == "" && s.Image == "" &&
+		len(s.Labels) == 0 && s.RuleName == "" && s.Level == "" {
+		return fmt.Errorf("silence must have at least one matcher")
+	}
+
+	for _, pattern := range []string{s.ContainerName, s.ContainerID, s.Image, s.RuleName} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+	for k, v := range s.Labels {
+		if k == "" {
+			return fmt.Errorf("label matcher name is required")
+		}
+		if _, err := path.Match(v, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q for label %s: %v", v, k, err)
+		}
+	}
+
+	if s.Level != "" {
+		s.Level = models.AlertLevel(strings.ToUpper(string(s.Level)))
+		switch s.Level {
+		case models.AlertLevelInfo, models.AlertLevelWarning, models.AlertLevelCritical:
+		default:
+			return fmt.Errorf("invalid alert level: %s", s.Level)
+		}
+	}
+
+	if s.StartsAt.IsZero() {
+		s.StartsAt = time.Now()
+	}
+	if s.EndsAt != nil && !s.EndsAt.After(s.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+
+	if !s.IsRecurring() {
+		if s.EndsAt == nil {
+			return fmt.Errorf("ends_at is required for a silence without a schedule")
+		}
+		return nil
+	}
+
+	if _, err := schedule.Parse(s.Schedule); err != nil {
+		return err
+	}
+	if s.Timezone != "" {
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q: %v", s.Timezone, err)
+		}
+	}
+	window := time.Duration(s.Duration) * time.Second
+	if window <= 0 || window > maxWindowDuration {
+		return fmt.Errorf("duration must be between 1 second and %s for a scheduled silence", maxWindowDuration)
+	}
+	return nil
+}
+
+// silenceActive reports whether the silence suppresses notifications at now
+func silenceActive(s *models.Silence, now time.Time) bool {
+	if now.Before(s.StartsAt) || s.Expired(now) {
+		return false
+	}
+	if !s.IsRecurring() {
+		return true
+	}
+
+	sched, err := schedule.Parse(s.Schedule)
+	if err != nil {
+		return false
+	}
+	if s.Timezone != "" {
+		if loc, err := time.LoadLocation(s.Timezone); err == nil {
+			now = now.In(loc)
+		}
+	}
+	return sched.ActiveAt(now, time.Duration(s.Duration)*time.Second)
+}
+
+// silenceMatches reports whether every matcher of the silence matches the
+// alert. Missing alert fields never match a set matcher.
+func silenceMatches(s *models.Silence, alert *models.Alert) bool {
+	if s.Level != "" && !strings.EqualFold(string(s.Level), string(alert.Level)) {
+		return false
+	}
+	if s.RuleName != "" && !globMatch(s.RuleName, alert.RuleName) {
+		return false
+	}
+	if s.ContainerName != "" &&
+		!globMatch(s.ContainerName, alert.ContainerName) &&
+		!globMatch(s.ContainerName, strings.TrimPrefix(alert.ContainerName, "/")) {
+		return false
+	}
+	if s.ContainerID != "" &&
+		!globMatch(s.ContainerID, alert.ContainerID) &&
+		!(alert.ContainerID != "" && strings.HasPrefix(alert.ContainerID, s.ContainerID)) {
+		return false
+	}
+	if s.Image != "" && !globMatch(s.Image, alert.Image) {
+		return false
+	}
+	for k, v := range s.Labels {
+		actual, ok := alert.Labels[k]
+		if !ok || !globMatch(v, actual) {
+			return false
+		}
+	}
+	return true
+}
+
+func globMatch(pattern, value string) bool {
+	ok, err := path.Match(pattern, value)
+	return err == nil && ok
+}
+
+// MatchingSilence returns the first silence active now that matches the
+// alert, or nil if the alert's notifications should go out.
+func (am *AlertManager) MatchingSilence(alert *models.Alert) (*models.Silence, error) {
+	now := time.Now()
+	var silences []models.Silence
+	if err := am.db.Where("starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)", now, now).
+		Order("id").Find(&silences).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch silences: %v", err)
+	}
+
+	for i := range silences {
+		if silenceActive(&silences[i], now) && silenceMatches(&silences[i], alert) {
+			return &silences[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// IsSilenceActive reports whether the silence suppresses notifications now
+func IsSilenceActive(s *models.Silence) bool {
+	return silenceActive(s, time.Now())
+}
+
+// NextWindow returns when a scheduled silence opens next, or the zero time
+// for one-off silences and schedules that have ended.
+func NextWindow(s *models.Silence, after time.Time) time.Time {
+	if !s.IsRecurring() {
+		return time.Time{}
+	}
+	sched, err := schedule.Parse(s.Schedule)
+	if err != nil {
+		return time.Time{}
+	}
+	if s.Timezone != "" {
+		if loc, err := time.LoadLocation(s.Timezone); err == nil {
+			after = after.In(loc)
+		}
+	}
+	if after.Before(s.StartsAt) {
+		after = s.StartsAt
+	}
+	next := sched.Next(after)
+	if next.IsZero() || s.Expired(next) {
+		return time.Time{}
+	}
+	return next
+}
+
+// ListSilences returns all silences, or only those that have not expired
+func (am *AlertManager) ListSilences(activeOnly bool) ([]models.Silence, error) {
+	query := am.db.Order("id")
+	if activeOnly {
+		query = query.Where("ends_at IS NULL OR ends_at > ?", time.Now())
+	}
+
+	var silences []models.Silence
+	if err := query.Find(&silences).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch silences: %v", err)
+	}
+	return silences, nil
+}
+
+func (am *AlertManager) GetSilence(id uint) (*models.Silence, error) {
+	var silence models.Silence
+	if err := am.db.First(&silence, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSilenceNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch silence: %v", err)
+	}
+	return &silence, nil
+}
+
+func (am *AlertManager) CreateSilence(silence *models.Silence) error {
+	if err := ValidateSilence(silence); err != nil {
+		return err
+	}
+	if err := am.db.Create(silence).Error; err != nil {
+		return fmt.Errorf("failed to create silence: %v", err)
+	}
+	return nil
+}
+
+// UpdateSilence replaces the matchers and timing of a silence, keeping its
+// creator.
+func (am *AlertManager) UpdateSilence(silence *models.Silence) error {
+	existing, err := am.GetSilence(silence.ID)
+	if err != nil {
+		return err
+	}
+	if err := ValidateSilence(silence); err != nil {
+		return err
+	}
+
+	silence.CreatedAt = existing.CreatedAt
+	silence.CreatedBy = existing.CreatedBy
+	if err := am.db.Save(silence).Error; err != nil {
+		return fmt.Errorf("failed to update silence: %v", err)
+	}
+	return nil
+}
+
+// ExpireSilence ends a silence now. The record is kept so alerts can still
+// refer to it.
+func (am *AlertManager) ExpireSilence(id uint) error {
+	silence, err := am.GetSilence(id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if silence.Expired(now) {
+		return nil
+	}
+	if err := am.db.Model(silence).Update("ends_at", now).Error; err != nil {
+		return fmt.Errorf("failed to expire silence: %v", err)
+	}
+	return nil
+}
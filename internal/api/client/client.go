@@ -17,37 +17,89 @@ import (
 type Client struct {
 	baseURL    string
 	apiKey     string
+	session    *Session
 	httpClient *http.Client
 }
 
+// NewClient authenticates with CONTAINEREYE_API_KEY when set, and with the
+// session saved by `containereye login` otherwise
 func NewClient() (*Client, error) {
-	baseURL := os.Getenv("CONTAINEREYE_API_URL")
-	if baseURL == "" {
-		baseURL = "http://localhost:8080"
+	c := &Client{
+		baseURL: apiURL(),
+		apiKey:  os.Getenv("CONTAINEREYE_API_KEY"),
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+	if c.apiKey != "" {
+		return c, nil
+	}
+
+	session, err := LoadSession()
+	if err != nil {
+		return nil, err
 	}
+	if session == nil {
+		return nil, fmt.Errorf("not logged in, run containereye login or set CONTAINEREYE_API_KEY")
+	}
+	c.session = session
+	// The session belongs to the server it was opened on
+	if os.Getenv("CONTAINEREYE_API_URL") == "" {
+		c.baseURL = session.URL
+	}
+	return c, nil
+}
 
-	apiKey := os.Getenv("CONTAINEREYE_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("CONTAINEREYE_API_KEY environment variable is not set")
+// apiURL returns the server URL from CONTAINEREYE_API_URL
+func apiURL() string {
+	if baseURL := os.Getenv("CONTAINEREYE_API_URL"); baseURL != "" {
+		return baseURL
 	}
+	return "http://localhost:8080"
+}
 
-	return &Client{
-		baseURL: baseURL,
-		apiKey:  apiKey,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-	}, nil
+// ContainerListOptions holds the filters for ListContainers
+type ContainerListOptions struct {
+	Host        string
+	State       string
+	Image       string
+	Name        string
+	IncludeGone bool
 }
 
-func (c *Client) ListContainers() ([]models.Container, error) {
+func (c *Client) ListContainers(opts ContainerListOptions) ([]models.Container, error) {
+	query := url.Values{}
+	if opts.Host != "" {
+		query.Set("host", opts.Host)
+	}
+	if opts.State != "" {
+		query.Set("state", opts.State)
+	}
+	if opts.Image != "" {
+		query.Set("image", opts.Image)
+	}
+	if opts.Name != "" {
+		query.Set("name", opts.Name)
+	}
+	if opts.IncludeGone {
+		query.Set("gone", "true")
+	}
+
 	var containers []models.Container
-	if err := c.get("/api/v1/containers", &containers); err != nil {
+	if err := c.get("/api/v1/containers?"+query.Encode(), &containers); err != nil {
 		return nil, err
 	}
 	return containers, nil
 }
 
+func (c *Client) GetContainer(containerID string) (*models.Container, error) {
+	var container models.Container
+	if err := c.get(fmt.Sprintf("/api/v1/containers/%s", containerID), &container); err != nil {
+		return nil, err
+	}
+	return &container, nil
+}
+
 func (c *Client) GetContainerStats(containerID string) (*models.ContainerStats, error) {
 	var stats models.ContainerStats
 	if err := c.get(fmt.Sprintf("/api/v1/containers/%s/stats", containerID), &stats); err != nil {
@@ -77,22 +129,216 @@ func (c *Client) GetContainerStatsHistory(containerID string, from, to *time.Tim
 	return stats, nil
 }
 
-func (c *Client) ListAlerts(status, level string) ([]models.Alert, error) {
+// StatsQueryOptions selects the containers, range and aggregation of QueryStats
+type StatsQueryOptions struct {
+	Metric      string
+	ContainerID string // Comma separated, full or short IDs
+	Name        string
+	Image       string
+	Host        string
+	Labels      string
+	From        *time.Time
+	To          *time.Time
+	Step        time.Duration
+	Aggregation string
+	GroupBy     string
+}
+
+func (c *Client) QueryStats(opts StatsQueryOptions) (*models.SeriesResult, error) {
+	query := url.Values{}
+	query.Set("metric", opts.Metric)
+	params := map[string]string{
+		"container_id": opts.ContainerID,
+		"name":         opts.Name,
+		"image":        opts.Image,
+		"host":         opts.Host,
+		"labels":       opts.Labels,
+		"agg":          opts.Aggregation,
+		"group_by":     opts.GroupBy,
+	}
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if opts.From != nil {
+		query.Set("start", opts.From.Format(time.RFC3339))
+	}
+	if opts.To != nil {
+		query.Set("end", opts.To.Format(time.RFC3339))
+	}
+	if opts.Step > 0 {
+		query.Set("step", opts.Step.String())
+	}
+
+	var result models.SeriesResult
+	if err := c.get("/api/v1/query?"+query.Encode(), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AlertListOptions holds the filters and paging parameters for ListAlerts
+type AlertListOptions struct {
+	Status        string
+	Level         string
+	RuleID        uint
+	Host          string
+	ContainerID   string
+	ContainerName string
+	Search        string
+	SilenceID     uint
+	From          *time.Time
+	To            *time.Time
+	Sort          string // "asc" or "desc"
+	Limit         int
+	Cursor        string
+}
+
+// AlertPage is a single page of alerts returned by ListAlerts
+type AlertPage struct {
+	Alerts     []models.Alert
+	Total      int64
+	NextCursor string
+}
+
+func (c *Client) ListAlerts(opts AlertListOptions) (*AlertPage, error) {
 	endpoint := "/api/v1/alerts"
-	
+
 	query := url.Values{}
-	if status != "" {
-		query.Set("status", status)
+	if opts.Status != "" {
+		query.Set("status", opts.Status)
 	}
-	if level != "" {
-		query.Set("level", level)
+	if opts.Level != "" {
+		query.Set("level", opts.Level)
+	}
+	if opts.RuleID != 0 {
+		query.Set("rule_id", fmt.Sprintf("%d", opts.RuleID))
+	}
+	if opts.Host != "" {
+		query.Set("host", opts.Host)
+	}
+	if opts.ContainerID != "" {
+		query.Set("container_id", opts.ContainerID)
+	}
+	if opts.ContainerName != "" {
+		query.Set("container_name", opts.ContainerName)
+	}
+	if opts.Search != "" {
+		query.Set("q", opts.Search)
+	}
+	if opts.SilenceID != 0 {
+		query.Set("silence_id", fmt.Sprintf("%d", opts.SilenceID))
+	}
+	if opts.From != nil {
+		query.Set("start", opts.From.Format(time.RFC3339))
+	}
+	if opts.To != nil {
+		query.Set("end", opts.To.Format(time.RFC3339))
+	}
+	if opts.Sort != "" {
+		query.Set("sort", opts.Sort)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", fmt.Sprintf("%d", opts.Limit))
+	}
+	if opts.Cursor != "" {
+		query.Set("cursor", opts.Cursor)
 	}
 
-	var alerts []models.Alert
-	if err := c.get(endpoint+"?"+query.Encode(), &alerts); err != nil {
+	resp, err := c.doRequest(http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
 		return nil, err
 	}
-	return alerts, nil
+	defer resp.Body.Close()
+
+	page := &AlertPage{NextCursor: resp.Header.Get("X-Next-Cursor")}
+	if total := resp.Header.Get("X-Total-Count"); total != "" {
+		if _, err := fmt.Sscanf(total, "%d", &page.Total); err != nil {
+			return nil, fmt.Errorf("invalid total count header: %v", err)
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page.Alerts); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// AuditListOptions holds the filters and paging parameters for ListAudit
+type AuditListOptions struct {
+	User       string
+	Action     string // An action such as rule.disable, or a prefix such as rule
+	TargetType string
+	TargetID   string
+	APIKeyID   uint
+	SourceIP   string
+	From       *time.Time
+	To         *time.Time
+	Sort       string // "asc" or "desc"
+	Limit      int
+	Cursor     string
+}
+
+// AuditPage is a single page of audit entries returned by ListAudit
+type AuditPage struct {
+	Entries    []models.AuditEntry
+	Total      int64
+	NextCursor string
+}
+
+func (c *Client) ListAudit(opts AuditListOptions) (*AuditPage, error) {
+	query := url.Values{}
+	params := map[string]string{
+		"user":        opts.User,
+		"action":      opts.Action,
+		"target_type": opts.TargetType,
+		"target_id":   opts.TargetID,
+		"source_ip":   opts.SourceIP,
+		"sort":        opts.Sort,
+		"cursor":      opts.Cursor,
+	}
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if opts.APIKeyID != 0 {
+		query.Set("api_key_id", fmt.Sprintf("%d", opts.APIKeyID))
+	}
+	if opts.From != nil {
+		query.Set("start", opts.From.Format(time.RFC3339))
+	}
+	if opts.To != nil {
+		query.Set("end", opts.To.Format(time.RFC3339))
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", fmt.Sprintf("%d", opts.Limit))
+	}
+
+	resp, err := c.doRequest(http.MethodGet, "/api/v1/audit?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	page := &AuditPage{NextCursor: resp.Header.Get("X-Next-Cursor")}
+	if total := resp.Header.Get("X-Total-Count"); total != "" {
+		if _, err := fmt.Sscanf(total, "%d", &page.Total); err != nil {
+			return nil, fmt.Errorf("invalid total count header: %v", err)
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page.Entries); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (c *Client) ListAlertDeliveries(alertID string) ([]models.NotificationDelivery, error) {
+	var deliveries []models.NotificationDelivery
+	if err := c.get(fmt.Sprintf("/api/v1/alerts/%s/deliveries", alertID), &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
 }
 
 func (c *Client) AcknowledgeAlert(alertID, comment string) error {
@@ -109,6 +355,243 @@ func (c *Client) ResolveAlert(alertID, comment string) error {
 	return c.post(fmt.Sprintf("/api/v1/alerts/%s/resolve", alertID), data, nil)
 }
 
+// Silence is a silence together with its current state
+type Silence struct {
+	models.Silence
+	Active     bool       `json:"active"`
+	NextWindow *time.Time `json:"next_window,omitempty"`
+}
+
+func (c *Client) ListSilences(includeExpired bool) ([]Silence, error) {
+	endpoint := "/api/v1/silences"
+	if includeExpired {
+		endpoint += "?expired=true"
+	}
+
+	var silences []Silence
+	if err := c.get(endpoint, &silences); err != nil {
+		return nil, err
+	}
+	return silences, nil
+}
+
+func (c *Client) GetSilence(id string) (*Silence, error) {
+	var silence Silence
+	if err := c.get(fmt.Sprintf("/api/v1/silences/%s", id), &silence); err != nil {
+		return nil, err
+	}
+	return &silence, nil
+}
+
+func (c *Client) CreateSilence(silence *models.Silence) (*Silence, error) {
+	var created Silence
+	if err := c.post("/api/v1/silences", silence, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *Client) UpdateSilence(id string, silence *models.Silence) (*Silence, error) {
+	var updated Silence
+	if err := c.put(fmt.Sprintf("/api/v1/silences/%s", id), silence, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (c *Client) ExpireSilence(id string) error {
+	return c.delete(fmt.Sprintf("/api/v1/silences/%s", id))
+}
+
+func (c *Client) ListHosts() ([]models.Host, error) {
+	var hosts []models.Host
+	if err := c.get("/api/v1/hosts", &hosts); err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+func (c *Client) CreateHost(host *models.Host) (*models.Host, error) {
+	var created models.Host
+	if err := c.post("/api/v1/hosts", host, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *Client) DeleteHost(name string) error {
+	return c.delete(fmt.Sprintf("/api/v1/hosts/%s", url.PathEscape(name)))
+}
+
+// UserRequest is the body of user create and update requests. Unset fields
+// are left unchanged on update.
+type UserRequest struct {
+	Username string       `json:"username,omitempty"`
+	Email    *string      `json:"email,omitempty"`
+	Password string       `json:"password,omitempty"`
+	Role     *models.Role `json:"role,omitempty"`
+	IsActive *bool        `json:"is_active,omitempty"`
+	// Hosts and LabelSelector restrict the user, empty values remove the
+	// restriction
+	Hosts         *[]string `json:"hosts,omitempty"`
+	LabelSelector *string   `json:"label_selector,omitempty"`
+}
+
+func (c *Client) ListUsers() ([]models.User, error) {
+	var users []models.User
+	if err := c.get("/api/v1/admin/users", &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUser returns a user by ID or username
+func (c *Client) GetUser(ref string) (*models.User, error) {
+	var user models.User
+	if err := c.get(fmt.Sprintf("/api/v1/admin/users/%s", url.PathEscape(ref)), &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (c *Client) CreateUser(req *UserRequest) (*models.User, error) {
+	var created models.User
+	if err := c.post("/api/v1/admin/users", req, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *Client) UpdateUser(ref string, req *UserRequest) (*models.User, error) {
+	var updated models.User
+	if err := c.put(fmt.Sprintf("/api/v1/admin/users/%s", url.PathEscape(ref)), req, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (c *Client) DeleteUser(ref string) error {
+	return c.delete(fmt.Sprintf("/api/v1/admin/users/%s", url.PathEscape(ref)))
+}
+
+// APIKeyRequest is the body of an API key create request
+type APIKeyRequest struct {
+	Name      string      `json:"name"`
+	Scope     models.Role `json:"scope,omitempty"`
+	ExpiresIn string      `json:"expires_in,omitempty"`
+}
+
+// CreatedAPIKey is a new API key together with the key itself, which the
+// server returns only once
+type CreatedAPIKey struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// ListAPIKeys returns the keys of the authenticated user
+func (c *Client) ListAPIKeys() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := c.get("/api/v1/auth/keys", &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// ListAllAPIKeys returns the keys of all users, or of one user given by ID
+// or username
+func (c *Client) ListAllAPIKeys(user string) ([]models.APIKey, error) {
+	query := url.Values{}
+	if user != "" {
+		query.Set("user", user)
+	}
+
+	var keys []models.APIKey
+	if err := c.get("/api/v1/admin/keys?"+query.Encode(), &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (c *Client) CreateAPIKey(req *APIKeyRequest) (*CreatedAPIKey, error) {
+	var created CreatedAPIKey
+	if err := c.post("/api/v1/auth/keys", req, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// RevokeAPIKey revokes a key of the authenticated user, or of any user
+// when anyUser is set
+func (c *Client) RevokeAPIKey(id string, anyUser bool) error {
+	if anyUser {
+		return c.delete(fmt.Sprintf("/api/v1/admin/keys/%s", url.PathEscape(id)))
+	}
+	return c.delete(fmt.Sprintf("/api/v1/auth/keys/%s", url.PathEscape(id)))
+}
+
+// CurrentUser is the authenticated user with the permissions the request
+// was granted
+type CurrentUser struct {
+	User        models.User         `json:"user"`
+	Permissions []models.Permission `json:"permissions"`
+	APIKey      *models.APIKey      `json:"api_key,omitempty"`
+}
+
+func (c *Client) GetCurrentUser() (*CurrentUser, error) {
+	var me CurrentUser
+	if err := c.get("/api/v1/auth/me", &me); err != nil {
+		return nil, err
+	}
+	return &me, nil
+}
+
+// PermissionInfo describes a permission of the server's catalog
+type PermissionInfo struct {
+	Name        models.Permission `json:"name"`
+	Description string            `json:"description"`
+}
+
+// RoleInfo is a role with its permissions, Custom when they were changed
+// from the defaults
+type RoleInfo struct {
+	Role        models.Role         `json:"role"`
+	Permissions []models.Permission `json:"permissions"`
+	Custom      bool                `json:"custom"`
+}
+
+func (c *Client) ListPermissions() ([]PermissionInfo, error) {
+	var perms []PermissionInfo
+	if err := c.get("/api/v1/admin/permissions", &perms); err != nil {
+		return nil, err
+	}
+	return perms, nil
+}
+
+func (c *Client) ListRoles() ([]RoleInfo, error) {
+	var roles []RoleInfo
+	if err := c.get("/api/v1/admin/roles", &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (c *Client) SetRolePermissions(role models.Role, perms []models.Permission) (*RoleInfo, error) {
+	req := struct {
+		Permissions []models.Permission `json:"permissions"`
+	}{perms}
+
+	var updated RoleInfo
+	if err := c.put(fmt.Sprintf("/api/v1/admin/roles/%s", url.PathEscape(string(role))), req, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// ResetRolePermissions restores the default permissions of a role
+func (c *Client) ResetRolePermissions(role models.Role) error {
+	return c.delete(fmt.Sprintf("/api/v1/admin/roles/%s", url.PathEscape(string(role))))
+}
+
 func (c *Client) ExportContainerStats(containerID string, from, to *time.Time, format, output string) error {
 	endpoint := fmt.Sprintf("/api/v1/containers/%s/stats/export", containerID)
 	
@@ -148,16 +631,27 @@ func (c *Client) get(endpoint string, v interface{}) error {
 }
 
 func (c *Client) post(endpoint string, data, v interface{}) error {
-	var body io.Reader
+	return c.send(http.MethodPost, endpoint, data, v)
+}
+
+func (c *Client) put(endpoint string, data, v interface{}) error {
+	return c.send(http.MethodPut, endpoint, data, v)
+}
+
+func (c *Client) delete(endpoint string) error {
+	return c.send(http.MethodDelete, endpoint, nil, nil)
+}
+
+func (c *Client) send(method, endpoint string, data, v interface{}) error {
+	var body []byte
 	if data != nil {
-		jsonData, err := json.Marshal(data)
-		if err != nil {
+		var err error
+		if body, err = json.Marshal(data); err != nil {
 			return fmt.Errorf("failed to marshal request body: %v", err)
 		}
-		body = bytes.NewReader(jsonData)
 	}
 
-	resp, err := c.doRequest(http.MethodPost, endpoint, body)
+	resp, err := c.doRequest(method, endpoint, body)
 	if err != nil {
 		return err
 	}
@@ -169,26 +663,28 @@ func (c *Client) post(endpoint string, data, v interface{}) error {
 	return nil
 }
 
-func (c *Client) doRequest(method, endpoint string, body io.Reader) (*http.Response, error) {
-	u, err := url.Parse(c.baseURL)
-	if err != nil {
-		return nil, fmt.Errorf("invalid base URL: %v", err)
+// doRequest sends a request and returns its response, or the API's error.
+// A session's access token is refreshed shortly before it expires, or when
+// the server rejects it, and the request retried.
+func (c *Client) doRequest(method, endpoint string, body []byte) (*http.Response, error) {
+	if c.session != nil && time.Until(c.session.ExpiresAt) < refreshBefore {
+		if err := c.refresh(); err != nil {
+			return nil, err
+		}
 	}
-	u.Path = path.Join(u.Path, endpoint)
 
-	req, err := http.NewRequest(method, u.String(), body)
+	resp, err := c.sendRequest(method, endpoint, body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-
-	req.Header.Set("X-API-Key", c.apiKey)
-	if body != nil {
-		req.Header.Set("Content-Type", "application/json")
+		return nil, err
 	}
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
+	if resp.StatusCode == http.StatusUnauthorized && c.session != nil {
+		resp.Body.Close()
+		if err := c.refresh(); err != nil {
+			return nil, err
+		}
+		if resp, err = c.sendRequest(method, endpoint, body); err != nil {
+			return nil, err
+		}
 	}
 
 	if resp.StatusCode >= 400 {
@@ -204,3 +700,49 @@ func (c *Client) doRequest(method, endpoint string, body io.Reader) (*http.Respo
 
 	return resp, nil
 }
+
+func (c *Client) sendRequest(method, endpoint string, body []byte) (*http.Response, error) {
+	u, err := c.url(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	if c.session != nil {
+		req.Header.Set("Authorization", "Bearer "+c.session.AccessToken)
+	} else if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+	return resp, nil
+}
+
+// url resolves an endpoint with its query against the base URL
+func (c *Client) url(endpoint string) (string, error) {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %v", err)
+	}
+	ref, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint: %v", err)
+	}
+	u.Path = path.Join(u.Path, ref.Path)
+	u.RawQuery = ref.RawQuery
+	return u.String(), nil
+}
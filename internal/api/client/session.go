@@ -0,0 +1,261 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// refreshBefore is how long before its expiry an access token is renewed
+const refreshBefore = 30 * time.Second
+
+const (
+	// lockTimeout is how long a refresh waits for another process to
+	// finish refreshing the saved session
+	lockTimeout = 15 * time.Second
+	// staleLockAge is when a lock left by a process that died is removed
+	staleLockAge = time.Minute
+)
+
+// Session is a login saved by `containereye login`, renewed with its
+// refresh token when the access token expires
+type Session struct {
+	URL              string    `json:"url"`
+	Username         string    `json:"username"`
+	AccessToken      string    `json:"access_token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// tokenPair is the server's response to a login or refresh
+type tokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+func (s *Session) update(tokens *tokenPair) {
+	s.AccessToken = tokens.AccessToken
+	s.ExpiresAt = tokens.ExpiresAt
+	s.RefreshToken = tokens.RefreshToken
+	s.RefreshExpiresAt = tokens.RefreshExpiresAt
+}
+
+// credentialsPath returns the file the session is saved in
+func credentialsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the config directory: %v", err)
+	}
+	return filepath.Join(dir, "containereye", "credentials.json"), nil
+}
+
+// LoadSession returns the saved session, nil when not logged in
+func LoadSession() (*Session, error) {
+	file, err := credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials: %v", err)
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials %s: %v", file, err)
+	}
+	return &session, nil
+}
+
+// save writes the session, readable only by the current user. The file is
+// replaced at once so that other processes never read half of it.
+func (s *Session) save() error {
+	file, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(file), err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), "credentials-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save credentials: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save credentials: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save credentials: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return fmt.Errorf("failed to save credentials: %v", err)
+	}
+	return nil
+}
+
+// lockCredentials keeps other processes from refreshing the saved session
+// until the returned function is called. Refresh tokens can be used only
+// once, so two processes refreshing with the same token would end the
+// session.
+func lockCredentials() (func(), error) {
+	file, err := credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", filepath.Dir(file), err)
+	}
+
+	lock := file + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock credentials: %v", err)
+		}
+
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for another process to refresh the session, remove %s if none is running", lock)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// RemoveSession deletes the saved session
+func RemoveSession() error {
+	file, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove credentials: %v", err)
+	}
+	return nil
+}
+
+// Login authenticates with a username and password and saves the session
+// for later commands. The server URL is taken from CONTAINEREYE_API_URL
+// unless given.
+func Login(baseURL, username, password string) (*Session, error) {
+	if baseURL == "" {
+		baseURL = apiURL()
+	}
+	c := &Client{baseURL: baseURL, httpClient: &http.Client{Timeout: 10 * time.Second}}
+
+	req := map[string]string{"username": username, "password": password}
+	var tokens tokenPair
+	if err := c.post("/api/v1/auth/login", req, &tokens); err != nil {
+		return nil, err
+	}
+
+	session := &Session{URL: baseURL, Username: username}
+	session.update(&tokens)
+	if err := session.save(); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Logout revokes the client's session on the server, or all sessions of
+// the user with all set, and deletes the saved session
+func (c *Client) Logout(all bool) error {
+	if c.session == nil {
+		return fmt.Errorf("not logged in")
+	}
+	req := struct {
+		All bool `json:"all"`
+	}{all}
+	if err := c.post("/api/v1/auth/logout", req, nil); err != nil {
+		return err
+	}
+	return RemoveSession()
+}
+
+// Session returns the client's saved session, nil when it uses an API key
+func (c *Client) Session() *Session {
+	return c.session
+}
+
+// refresh renews the session's tokens and saves them. When another process
+// has renewed the saved session in the meantime, its tokens are used
+// instead.
+func (c *Client) refresh() error {
+	unlock, err := lockCredentials()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	saved, err := LoadSession()
+	if err != nil {
+		return err
+	}
+	if saved != nil && saved.URL == c.session.URL && saved.Username == c.session.Username &&
+		saved.RefreshToken != c.session.RefreshToken {
+		*c.session = *saved
+		if time.Until(saved.ExpiresAt) >= refreshBefore {
+			return nil
+		}
+	}
+
+	if time.Now().After(c.session.RefreshExpiresAt) {
+		return fmt.Errorf("session expired, run containereye login")
+	}
+
+	body, err := json.Marshal(map[string]string{"refresh_token": c.session.RefreshToken})
+	if err != nil {
+		return err
+	}
+	u, err := c.url("/api/v1/auth/refresh")
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Post(u, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err == nil && errResp.Error != "" {
+			return fmt.Errorf("session expired, run containereye login: %s", errResp.Error)
+		}
+		return fmt.Errorf("session expired, run containereye login: refresh failed with status %d", resp.StatusCode)
+	}
+
+	var tokens tokenPair
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return fmt.Errorf("failed to decode refresh response: %v", err)
+	}
+	c.session.update(&tokens)
+	return c.session.save()
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"containereye/internal/database"
+	"containereye/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metricsContentType is the Prometheus text exposition format
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type label struct {
+	name, value string
+}
+
+// metricsWriter writes metric families in the Prometheus text format
+type metricsWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *metricsWriter) family(name, typ, help string) {
+	fmt.Fprintf(&w.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (w *metricsWriter) sample(name string, value float64, labels ...label) {
+	w.buf.WriteString(name)
+	if len(labels) > 0 {
+		w.buf.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			fmt.Fprintf(&w.buf, "%s=\"%s\"", l.name, labelEscaper.Replace(l.value))
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(formatSampleValue(value))
+	w.buf.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatSampleValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// containerMetrics are the per-container samples exported from the latest
+// collected stats
+var containerMetrics = []struct {
+	name, typ, help string
+	value           func(*models.ContainerStats) float64
+}{
+	{"containereye_container_cpu_percent", "gauge", "CPU usage of the container in percent.",
+		func(s *models.ContainerStats) float64 { return s.CPUPercent }},
+	{"containereye_container_memory_usage_bytes", "gauge", "Memory used by the container.",
+		func(s *models.ContainerStats) float64 { return float64(s.MemoryUsage) }},
+	{"containereye_container_memory_limit_bytes", "gauge", "Memory limit of the container.",
+		func(s *models.ContainerStats) float64 { return float64(s.MemoryLimit) }},
+	{"containereye_container_memory_percent", "gauge", "Memory usage of the container in percent of its limit.",
+		func(s *models.ContainerStats) float64 { return s.MemoryPercent }},
+	{"containereye_container_network_receive_bytes_total", "counter", "Bytes received by the container.",
+		func(s *models.ContainerStats) float64 { return float64(s.NetworkRx) }},
+	{"containereye_container_network_transmit_bytes_total", "counter", "Bytes transmitted by the container.",
+		func(s *models.ContainerStats) float64 { return float64(s.NetworkTx) }},
+	{"containereye_container_block_read_bytes_total", "counter", "Bytes read from block devices by the container.",
+		func(s *models.ContainerStats) float64 { return float64(s.BlockRead) }},
+	{"containereye_container_block_write_bytes_total", "counter", "Bytes written to block devices by the container.",
+		func(s *models.ContainerStats) float64 { return float64(s.BlockWrite) }},
+	{"containereye_container_pids", "gauge", "Number of processes in the container.",
+		func(s *models.ContainerStats) float64 { return float64(s.PIDs) }},
+}
+
+// metrics exposes the latest container stats and ContainerEye's internal
+// metrics for Prometheus to scrape
+func (s *Server) metrics(c *gin.Context) {
+	var w metricsWriter
+
+	var stats []*models.ContainerStats
+	collectors := s.hosts.Collectors()
+	for _, collector := range collectors {
+		stats = append(stats, collector.LatestStats()...)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].HostID != stats[j].HostID {
+			return stats[i].HostID < stats[j].HostID
+		}
+		return stats[i].ContainerName < stats[j].ContainerName
+	})
+	for _, m := range containerMetrics {
+		w.family(m.name, m.typ, m.help)
+		for _, stat := range stats {
+			w.sample(m.name, m.value(stat),
+				label{"host", stat.HostID},
+				label{"container_id", stat.ContainerID},
+				label{"container_name", strings.TrimPrefix(stat.ContainerName, "/")},
+				label{"image", stat.Image},
+			)
+		}
+	}
+
+	w.family("containereye_collections_total", "counter", "Successful collection cycles per host.")
+	for _, collector := range collectors {
+		st := collector.Stats()
+		w.sample("containereye_collections_total", float64(st.TotalCollections), label{"host", st.HostID})
+	}
+	w.family("containereye_collection_failures_total", "counter", "Failed collection cycles per host.")
+	for _, collector := range collectors {
+		st := collector.Stats()
+		w.sample("containereye_collection_failures_total", float64(st.FailedCollections), label{"host", st.HostID})
+	}
+	w.family("containereye_collection_processing_seconds_total", "counter", "Time spent in collection cycles per host.")
+	for _, collector := range collectors {
+		st := collector.Stats()
+		w.sample("containereye_collection_processing_seconds_total", st.TotalProcessingTime.Seconds(), label{"host", st.HostID})
+	}
+	w.family("containereye_collection_batch_size", "gauge", "Current number of containers collected per batch per host.")
+	for _, collector := range collectors {
+		st := collector.Stats()
+		w.sample("containereye_collection_batch_size", float64(st.BatchSize), label{"host", st.HostID})
+	}
+
+	if err := writeAlertMetrics(&w); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := writeDeliveryMetrics(&w); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Data(http.StatusOK, metricsContentType, w.buf.Bytes())
+}
+
+// writeAlertMetrics exports the number of alerts that are not resolved, by
+// level
+func writeAlertMetrics(w *metricsWriter) error {
+	var rows []struct {
+		Level models.AlertLevel
+		Count int64
+	}
+	if err := database.GetDB().Model(&models.Alert{}).
+		Select("level, COUNT(*) AS count").
+		Where("status <> ?", models.AlertStatusResolved).
+		Group("level").
+		Scan(&rows).Error; err != nil {
+		return fmt.Errorf("failed to count alerts: %v", err)
+	}
+
+	// Every level is exported so that a level without alerts reads 0
+	open := map[models.AlertLevel]int64{
+		models.AlertLevelInfo:     0,
+		models.AlertLevelWarning:  0,
+		models.AlertLevelCritical: 0,
+	}
+	for _, row := range rows {
+		open[row.Level] = row.Count
+	}
+
+	w.family("containereye_alerts_open", "gauge", "Alerts that are not resolved, by level.")
+	for _, level := range []models.AlertLevel{models.AlertLevelInfo, models.AlertLevelWarning, models.AlertLevelCritical} {
+		w.sample("containereye_alerts_open", float64(open[level]), label{"level", string(level)})
+	}
+	return nil
+}
+
+// writeDeliveryMetrics exports the notification outbox by channel and
+// status, and the outcome of every delivery attempt
+func writeDeliveryMetrics(w *metricsWriter) error {
+	db := database.GetDB()
+
+	var deliveries []struct {
+		Channel string
+		Status  models.DeliveryStatus
+		Count   int64
+	}
+	if err := db.Model(&models.NotificationDelivery{}).
+		Select("channel, status, COUNT(*) AS count").
+		Group("channel, status").
+		Order("channel, status").
+		Scan(&deliveries).Error; err != nil {
+		return fmt.Errorf("failed to count deliveries: %v", err)
+	}
+
+	w.family("containereye_notification_deliveries", "gauge", "Notifications in the outbox by channel and status.")
+	for _, row := range deliveries {
+		w.sample("containereye_notification_deliveries", float64(row.Count),
+			label{"channel", row.Channel}, label{"status", strings.ToLower(string(row.Status))})
+	}
+
+	var attempts []struct {
+		Channel string
+		Success bool
+		Count   int64
+	}
+	if err := db.Table("delivery_attempts").
+		Select("notification_deliveries.channel AS channel, delivery_attempts.success AS success, COUNT(*) AS count").
+		Joins("JOIN notification_deliveries ON notification_deliveries.id = delivery_attempts.delivery_id").
+		Where("delivery_attempts.deleted_at IS NULL").
+		Group("notification_deliveries.channel, delivery_attempts.success").
+		Order("channel").
+		Scan(&attempts).Error; err != nil {
+		return fmt.Errorf("failed to count delivery attempts: %v", err)
+	}
+
+	w.family("containereye_notification_attempts_total", "counter", "Notification send attempts by channel and result.")
+	for _, row := range attempts {
+		result := "failure"
+		if row.Success {
+			result = "success"
+		}
+		w.sample("containereye_notification_attempts_total", float64(row.Count),
+			label{"channel", row.Channel}, label{"result", result})
+	}
+	return nil
+}
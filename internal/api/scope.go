@@ -0,0 +1,113 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"containereye/internal/auth"
+	"containereye/internal/database"
+	"containereye/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Users restricted to some hosts or container labels only see the matching
+// containers and their stats, events and alerts. Containers and alerts out
+// of scope are reported as not found.
+
+// scopedContainerIDs returns the IDs of the inventory containers within the
+// request's scope, and false when the request is not restricted
+func scopedContainerIDs(c *gin.Context) ([]string, bool, error) {
+	scope := auth.ScopeOf(c)
+	if scope == nil {
+		return nil, false, nil
+	}
+
+	var containers []models.Container
+	if err := database.GetDB().Select("container_id, host_id, labels").Find(&containers).Error; err != nil {
+		return nil, true, err
+	}
+	ids := []string{}
+	for _, container := range containers {
+		if scope.AllowsContainer(container.HostID, container.Labels) {
+			ids = append(ids, container.ContainerID)
+		}
+	}
+	return ids, true, nil
+}
+
+// scopeContainers limits a query on a table with a container_id column to
+// the containers within the request's scope
+func scopeContainers(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	ids, restricted, err := scopedContainerIDs(c)
+	if err != nil || !restricted {
+		return query, err
+	}
+	return query.Where("container_id IN ?", ids), nil
+}
+
+// containerInScope reports whether the container with the full ID id is
+// within the request's scope
+func containerInScope(c *gin.Context, id string) (bool, error) {
+	ids, restricted, err := scopedContainerIDs(c)
+	if err != nil || !restricted {
+		return true, err
+	}
+	return containsString(ids, id), nil
+}
+
+// requireAlertInScope answers requests for an alert outside of the
+// request's scope with 404
+func (s *Server) requireAlertInScope(c *gin.Context) {
+	if auth.ScopeOf(c) == nil {
+		c.Next()
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alert ID"})
+		c.Abort()
+		return
+	}
+
+	var alert models.Alert
+	if err := database.GetDB().Select("id, container_id").First(&alert, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "alert not found"})
+		c.Abort()
+		return
+	}
+	ok, err := containerInScope(c, alert.ContainerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "alert not found"})
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// requireHostInScope answers requests for a host outside of the request's
+// scope with 404
+func (s *Server) requireHostInScope(c *gin.Context) {
+	if !auth.ScopeOf(c).AllowsHost(c.Param("name")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "host not found"})
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
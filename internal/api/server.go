@@ -1,241 +1,1586 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 	
 	"containereye/internal/alert"
+	"containereye/internal/audit"
 	"containereye/internal/auth"
 	"containereye/internal/database"
 	"containereye/internal/models"
 	"containereye/internal/monitor"
+	"containereye/internal/retention"
 	
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type Server struct {
-	collector    *monitor.Collector
+	hosts        *monitor.HostManager
 	alertManager *alert.AlertManager
 	ruleManager  *alert.RuleManager
+	retention    retention.Policy
+	options      Options
 	router      *gin.Engine
 }
 
-func NewServer(collector *monitor.Collector, alertManager *alert.AlertManager, ruleManager *alert.RuleManager) *Server {
+// Options are the HTTP settings of the API server
+type Options struct {
+	// TrustedProxies are the proxies whose X-Forwarded-For header names the
+	// client address of requests, e.g. the source IP of audit entries
+	TrustedProxies []string
+	// PublicMetrics serves /metrics without authentication. Otherwise it
+	// requires an API key or token with containers:read.
+	PublicMetrics bool
+}
+
+func NewServer(hosts *monitor.HostManager, alertManager *alert.AlertManager, ruleManager *alert.RuleManager, policy retention.Policy, options Options) (*Server, error) {
+	router := gin.Default()
+	if err := router.SetTrustedProxies(options.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %v", err)
+	}
+
 	server := &Server{
-		collector:    collector,
+		hosts:        hosts,
 		alertManager: alertManager,
 		ruleManager:  ruleManager,
-		router:      gin.Default(),
+		retention:    policy,
+		options:      options,
+		router:      router,
 	}
 	
 	server.setupRoutes()
-	return server
+	return server, nil
 }
 
 func (s *Server) setupRoutes() {
 	// Public routes
 	s.router.POST("/api/v1/auth/login", s.login)
 	s.router.POST("/api/v1/auth/register", s.register)
+	s.router.POST("/api/v1/auth/refresh", s.refresh)
+	if s.options.PublicMetrics {
+		s.router.GET("/metrics", s.metrics)
+	} else {
+		// Prometheus authenticates with an API key as its bearer token
+		s.router.GET("/metrics", auth.AuthMiddleware(), auth.RequirePermission(models.PermContainersRead), s.metrics)
+	}
 	
 	// Protected routes (require authentication)
 	api := s.router.Group("/api/v1")
 	api.Use(auth.AuthMiddleware())
+	api.POST("/auth/logout", s.logout)
+	api.PUT("/auth/password", s.changePassword)
+	api.GET("/auth/keys", s.listAPIKeys)
+	api.POST("/auth/keys", s.createAPIKey)
+	api.DELETE("/auth/keys/:id", s.revokeAPIKey)
 	
+	api.GET("/auth/me", s.getCurrentUser)
+
 	// Container monitoring endpoints
-	api.GET("/containers", s.listContainers)
-	api.GET("/containers/:id/stats", s.getContainerStats)
+	api.GET("/containers", auth.RequirePermission(models.PermContainersRead), s.listContainers)
+	api.GET("/containers/:id", auth.RequirePermission(models.PermContainersRead), s.getContainer)
+	api.GET("/containers/:id/stats", auth.RequirePermission(models.PermContainersRead), s.getContainerStats)
+	api.GET("/containers/:id/events", auth.RequirePermission(models.PermContainersRead), s.getContainerEvents)
+	api.GET("/query", auth.RequirePermission(models.PermContainersRead), s.queryStats)
 	
 	// Alert management endpoints
-	api.GET("/alerts", s.listAlerts)
-	api.POST("/alerts", auth.RequireRole(models.RoleAdmin, models.RoleUser), s.createAlert)
-	api.PUT("/alerts/:id/acknowledge", auth.RequireRole(models.RoleAdmin, models.RoleUser), s.acknowledgeAlert)
-	api.PUT("/alerts/:id/resolve", auth.RequireRole(models.RoleAdmin, models.RoleUser), s.resolveAlert)
+	api.GET("/alerts", auth.RequirePermission(models.PermAlertsRead), s.listAlerts)
+	api.POST("/alerts", auth.RequirePermission(models.PermAlertsWrite), s.createAlert)
+	api.GET("/alerts/:id/deliveries", auth.RequirePermission(models.PermAlertsRead), s.requireAlertInScope, s.listAlertDeliveries)
+	api.GET("/alerts/:id/escalations", auth.RequirePermission(models.PermAlertsRead), s.requireAlertInScope, s.listAlertEscalations)
+	api.PUT("/alerts/:id/acknowledge", auth.RequirePermission(models.PermAlertsAck), s.requireAlertInScope, s.acknowledgeAlert)
+	api.PUT("/alerts/:id/resolve", auth.RequirePermission(models.PermAlertsAck), s.requireAlertInScope, s.resolveAlert)
 	
+	// Silence endpoints
+	silences := api.Group("/silences")
+	{
+		silences.GET("", auth.RequirePermission(models.PermSilencesRead), s.listSilences)
+		silences.GET("/:id", auth.RequirePermission(models.PermSilencesRead), s.getSilence)
+		silences.POST("", auth.RequirePermission(models.PermSilencesWrite), s.createSilence)
+		silences.PUT("/:id", auth.RequirePermission(models.PermSilencesWrite), s.updateSilence)
+		silences.DELETE("/:id", auth.RequirePermission(models.PermSilencesWrite), s.expireSilence)
+	}
+
+	// Stats pushed by agents
+	api.POST("/ingest", auth.RequirePermission(models.PermStatsIngest), s.ingest)
+
+	// Host endpoints
+	hosts := api.Group("/hosts")
+	{
+		hosts.GET("", auth.RequirePermission(models.PermHostsRead), s.listHosts)
+		hosts.GET("/:name", auth.RequirePermission(models.PermHostsRead), s.requireHostInScope, s.getHost)
+		hosts.POST("", auth.RequirePermission(models.PermHostsWrite), s.createHost)
+		hosts.PUT("/:name", auth.RequirePermission(models.PermHostsWrite), s.requireHostInScope, s.updateHost)
+		hosts.DELETE("/:name", auth.RequirePermission(models.PermHostsWrite), s.requireHostInScope, s.deleteHost)
+	}
+
 	// Rule management endpoints
 	rules := api.Group("/rules")
 	{
-		rules.GET("", s.listRules)
-		rules.GET("/:id", s.getRule)
-		rules.POST("", auth.RequireRole(models.RoleAdmin), s.createRule)
-		rules.PUT("/:id", auth.RequireRole(models.RoleAdmin), s.updateRule)
-		rules.DELETE("/:id", auth.RequireRole(models.RoleAdmin), s.deleteRule)
-		rules.PUT("/:id/enable", auth.RequireRole(models.RoleAdmin), s.enableRule)
-		rules.PUT("/:id/disable", auth.RequireRole(models.RoleAdmin), s.disableRule)
-		rules.POST("/validate", auth.RequireRole(models.RoleAdmin), s.validateRule)
-		rules.POST("/import", auth.RequireRole(models.RoleAdmin), s.importRules)
-		rules.GET("/export", auth.RequireRole(models.RoleAdmin), s.exportRules)
-		rules.POST("/test", auth.RequireRole(models.RoleAdmin), s.testRule)
+		rules.GET("", auth.RequirePermission(models.PermRulesRead), s.listRules)
+		rules.GET("/:id", auth.RequirePermission(models.PermRulesRead), s.getRule)
+		rules.POST("", auth.RequirePermission(models.PermRulesWrite), s.createRule)
+		rules.PUT("/:id", auth.RequirePermission(models.PermRulesWrite), s.updateRule)
+		rules.DELETE("/:id", auth.RequirePermission(models.PermRulesWrite), s.deleteRule)
+		rules.PUT("/:id/enable", auth.RequirePermission(models.PermRulesWrite), s.enableRule)
+		rules.PUT("/:id/disable", auth.RequirePermission(models.PermRulesWrite), s.disableRule)
+		rules.POST("/validate", auth.RequirePermission(models.PermRulesWrite), s.validateRule)
+		rules.POST("/import", auth.RequirePermission(models.PermRulesWrite), s.importRules)
+		rules.GET("/export", auth.RequirePermission(models.PermRulesRead), s.exportRules)
+		rules.POST("/test", auth.RequirePermission(models.PermRulesWrite), s.testRule)
 	}
 	
+	api.GET("/audit", auth.RequirePermission(models.PermAuditRead), s.listAudit)
+
 	// User management endpoints
 	admin := api.Group("/admin")
-	admin.Use(auth.RequireRole(models.RoleAdmin))
+	admin.Use(auth.RequirePermission(models.PermUsersAdmin))
 	admin.GET("/users", s.listUsers)
+	admin.GET("/users/:id", s.getUser)
 	admin.POST("/users", s.createUser)
 	admin.PUT("/users/:id", s.updateUser)
 	admin.DELETE("/users/:id", s.deleteUser)
+	admin.GET("/keys", s.listAllAPIKeys)
+	admin.DELETE("/keys/:id", s.revokeAnyAPIKey)
+	admin.GET("/permissions", s.listPermissions)
+	admin.GET("/roles", s.listRoles)
+	admin.PUT("/roles/:role", s.updateRole)
+	admin.DELETE("/roles/:role", s.resetRole)
+}
+
+// Start serves the API on addr, e.g. ":8080"
+func (s *Server) Start(addr string) error {
+	return s.router.Run(addr)
+}
+
+// listContainers returns the container inventory kept by the collector.
+// Removed containers are only listed with gone=true.
+func (s *Server) listContainers(c *gin.Context) {
+	query := database.GetDB().Model(&models.Container{})
+
+	if c.Query("gone") != "true" {
+		query = query.Where("gone = ?", false)
+	}
+	if host := c.Query("host"); host != "" {
+		query = query.Where("host_id = ?", host)
+	}
+	if state := c.Query("state"); state != "" {
+		query = query.Where("state = ?", strings.ToLower(state))
+	}
+	if image := c.Query("image"); image != "" {
+		query = query.Where(`image LIKE ? ESCAPE '\'`, escapeLike(image)+"%")
+	}
+	if name := c.Query("name"); name != "" {
+		query = query.Where(`name LIKE ? ESCAPE '\'`, "%"+escapeLike(name)+"%")
+	}
+	query, err := scopeContainers(c, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch containers"})
+		return
+	}
+
+	var containers []models.Container
+	if err := query.Order("name").Find(&containers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch containers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, containers)
+}
+
+// getContainer returns the inventory row of a container by full or short ID,
+// including its health check status and recent probe results.
+func (s *Server) getContainer(c *gin.Context) {
+	query, err := scopeContainers(c, database.GetDB().Where(`container_id LIKE ? ESCAPE '\'`, escapeLike(c.Param("id"))+"%"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch container"})
+		return
+	}
+
+	var container models.Container
+	err = query.Order("gone, last_seen desc").First(&container).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "container not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch container"})
+		return
+	}
+
+	c.JSON(http.StatusOK, container)
+}
+
+// getContainerStats returns the stored stats of a container, newest first.
+// Ranges that reach back beyond the raw retention or span long periods are
+// served from the 1-minute or 1-hour rollups unless a resolution is given.
+func (s *Server) getContainerStats(c *gin.Context) {
+	q := retention.StatsQuery{ContainerID: c.Param("id")}
+
+	if ok, err := containerInScope(c, q.ContainerID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch container stats"})
+		return
+	} else if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "container not found"})
+		return
+	}
+
+	// Add time range filter if provided
+	if startTime := c.Query("start"); startTime != "" {
+		if t, err := time.Parse(time.RFC3339, startTime); err == nil {
+			q.Start = t
+		}
+	}
+	if endTime := c.Query("end"); endTime != "" {
+		if t, err := time.Parse(time.RFC3339, endTime); err == nil {
+			q.End = t
+		}
+	}
+
+	// Add limit if provided
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil {
+			q.Limit = l
+		}
+	}
+
+	resolution := s.retention.Resolution(q.Start, q.End)
+	if r := c.Query("resolution"); r != "" {
+		var err error
+		if resolution, err = retention.ParseResolution(r); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Execute query
+	stats, err := retention.Stats(database.GetDB(), resolution, q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch container stats"})
+		return
+	}
+
+	c.Header("X-Stats-Resolution", retention.FormatResolution(resolution))
+	c.JSON(http.StatusOK, stats)
+}
+
+// getContainerEvents returns the recorded lifecycle events of a container,
+// newest first, optionally filtered by type and time range.
+func (s *Server) getContainerEvents(c *gin.Context) {
+	query, err := scopeContainers(c, database.GetDB().Where(`container_id LIKE ? ESCAPE '\'`, escapeLike(c.Param("id"))+"%"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch container events"})
+		return
+	}
+
+	if eventType := c.Query("type"); eventType != "" {
+		if !isValidEvent(models.EventType(eventType)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event type"})
+			return
+		}
+		query = query.Where("type = ?", eventType)
+	}
+	if startTime := c.Query("start"); startTime != "" {
+		t, err := time.Parse(time.RFC3339, startTime)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start time, expected RFC3339"})
+			return
+		}
+		query = query.Where("time >= ?", t)
+	}
+	if endTime := c.Query("end"); endTime != "" {
+		t, err := time.Parse(time.RFC3339, endTime)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end time, expected RFC3339"})
+			return
+		}
+		query = query.Where("time <= ?", t)
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil {
+			query = query.Limit(l)
+		}
+	}
+
+	var events []models.ContainerEvent
+	if err := query.Order("time desc").Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch container events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+const (
+	defaultAlertPageSize = 50
+	maxAlertPageSize     = 500
+)
+
+// queryStats aggregates a metric of the selected containers into aligned
+// series. Containers are selected from the inventory, including removed
+// ones, by comma separated IDs (full or short), name, image and host
+// patterns and a label selector; without selectors every container is
+// queried. The range defaults to the last hour.
+func (s *Server) queryStats(c *gin.Context) {
+	q := retention.SeriesQuery{
+		Metric:      c.Query("metric"),
+		Aggregation: c.Query("agg"),
+		GroupBy:     c.Query("group_by"),
+		End:         time.Now(),
+	}
+	if endTime := c.Query("end"); endTime != "" {
+		t, err := time.Parse(time.RFC3339, endTime)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end time, expected RFC3339"})
+			return
+		}
+		q.End = t
+	}
+	q.Start = q.End.Add(-time.Hour)
+	if startTime := c.Query("start"); startTime != "" {
+		t, err := time.Parse(time.RFC3339, startTime)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start time, expected RFC3339"})
+			return
+		}
+		q.Start = t
+	}
+	if step := c.Query("step"); step != "" {
+		d, err := time.ParseDuration(step)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid step, expected a duration such as 30s or 5m"})
+			return
+		}
+		q.Step = d
+	}
+
+	resolution := s.retention.Resolution(q.Start, q.End)
+	if r := c.Query("resolution"); r != "" {
+		var err error
+		if resolution, err = retention.ParseResolution(r); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := q.Validate(resolution); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	selector, err := parseContainerSelector(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	containers, err := selector.containers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch containers"})
+		return
+	}
+	allowed, restricted, err := scopedContainerIDs(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch containers"})
+		return
+	}
+	if restricted {
+		inScope := []string{}
+		for _, id := range containers {
+			if containsString(allowed, id) {
+				inScope = append(inScope, id)
+			}
+		}
+		containers = inScope
+	}
+	q.Containers = containers
+
+	result, err := retention.QuerySeries(database.GetDB(), resolution, q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query container stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// containerSelector selects containers of the inventory for a query
+type containerSelector struct {
+	ids    []string // Full or short IDs
+	name   string
+	image  string
+	host   string
+	labels alert.LabelSelector
+}
+
+func parseContainerSelector(c *gin.Context) (*containerSelector, error) {
+	sel := &containerSelector{
+		name:  strings.TrimPrefix(c.Query("name"), "/"),
+		image: c.Query("image"),
+		host:  c.Query("host"),
+	}
+	for _, id := range strings.Split(c.Query("container_id"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			sel.ids = append(sel.ids, id)
+		}
+	}
+	for field, pattern := range map[string]string{"name": sel.name, "image": sel.image, "host": sel.host} {
+		if pattern == "" {
+			continue
+		}
+		if _, err := alert.MatchPattern(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %v", field, pattern, err)
+		}
+	}
+
+	labels, err := alert.ParseLabelSelector(c.Query("labels"))
+	if err != nil {
+		return nil, err
+	}
+	sel.labels = labels
+	return sel, nil
+}
+
+// containers returns the IDs of the inventory containers, including removed
+// ones, matching every selector that is set
+func (sel *containerSelector) containers() ([]string, error) {
+	var containers []models.Container
+	if err := database.GetDB().Select("container_id, name, image, host_id, labels").
+		Find(&containers).Error; err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, container := range containers {
+		if len(sel.ids) > 0 && !hasIDPrefix(container.ContainerID, sel.ids) {
+			continue
+		}
+		checks := []struct{ pattern, value string }{
+			{sel.name, strings.TrimPrefix(container.Name, "/")},
+			{sel.image, container.Image},
+			{sel.host, container.HostID},
+		}
+		matched := true
+		for _, check := range checks {
+			if check.pattern == "" {
+				continue
+			}
+			if ok, _ := alert.MatchPattern(check.pattern, check.value); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched && sel.labels.Matches(container.Labels) {
+			ids = append(ids, container.ContainerID)
+		}
+	}
+	return ids, nil
+}
+
+// likeEscaper escapes the wildcards in a LIKE pattern, matched with
+// ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike makes user input match literally inside a LIKE pattern
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+func hasIDPrefix(id string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// listAlerts returns alerts matching the query filters, newest first unless
+// sort=asc is given. Pagination is cursor based on the alert ID: the response
+// carries the total match count in X-Total-Count and, if more results are
+// available, the cursor for the next page in X-Next-Cursor.
+func (s *Server) listAlerts(c *gin.Context) {
+	query := database.GetDB().Model(&models.Alert{})
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", strings.ToUpper(status))
+	}
+	if level := c.Query("level"); level != "" {
+		query = query.Where("level = ?", strings.ToUpper(level))
+	}
+	if ruleID := c.Query("rule_id"); ruleID != "" {
+		id, err := strconv.ParseUint(ruleID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule_id"})
+			return
+		}
+		query = query.Where("rule_id = ?", id)
+	}
+	if host := c.Query("host"); host != "" {
+		query = query.Where("host_id = ?", host)
+	}
+	if containerID := c.Query("container_id"); containerID != "" {
+		query = query.Where(`container_id LIKE ? ESCAPE '\'`, escapeLike(containerID)+"%")
+	}
+	if containerName := c.Query("container_name"); containerName != "" {
+		query = query.Where("container_name = ? OR container_name = ?", containerName, "/"+containerName)
+	}
+	if startTime := c.Query("start"); startTime != "" {
+		t, err := time.Parse(time.RFC3339, startTime)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start time, expected RFC3339"})
+			return
+		}
+		query = query.Where("start_time >= ?", t)
+	}
+	if endTime := c.Query("end"); endTime != "" {
+		t, err := time.Parse(time.RFC3339, endTime)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end time, expected RFC3339"})
+			return
+		}
+		query = query.Where("start_time <= ?", t)
+	}
+	if search := c.Query("q"); search != "" {
+		query = query.Where(`message LIKE ? ESCAPE '\'`, "%"+escapeLike(search)+"%")
+	}
+	if silenceID := c.Query("silence_id"); silenceID != "" {
+		id, err := strconv.ParseUint(silenceID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid silence_id"})
+			return
+		}
+		query = query.Where("silence_id = ?", id)
+	}
+	query, err := scopeContainers(c, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count alerts"})
+		return
+	}
+
+	limit := defaultAlertPageSize
+	if l := c.Query("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxAlertPageSize {
+		limit = maxAlertPageSize
+	}
+
+	ascending := false
+	switch strings.ToLower(c.DefaultQuery("sort", "desc")) {
+	case "asc":
+		ascending = true
+	case "desc":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be asc or desc"})
+		return
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		id, err := strconv.ParseUint(cursor, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		if ascending {
+			query = query.Where("id > ?", id)
+		} else {
+			query = query.Where("id < ?", id)
+		}
+	}
+
+	order := "id desc"
+	if ascending {
+		order = "id asc"
+	}
+
+	// Fetch one extra row to find out whether another page exists
+	var alerts []models.Alert
+	if err := query.Order(order).Limit(limit + 1).Find(&alerts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+		return
+	}
+
+	if len(alerts) > limit {
+		alerts = alerts[:limit]
+		c.Header("X-Next-Cursor", strconv.FormatUint(uint64(alerts[len(alerts)-1].ID), 10))
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	c.JSON(http.StatusOK, alerts)
+}
+
+func (s *Server) createAlert(c *gin.Context) {
+	var alert models.Alert
+	if err := c.BindJSON(&alert); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	if !isValidAlertLevel(alert.Level) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid alert level: %s", alert.Level)})
+		return
+	}
+
+	if ok, err := containerInScope(c, alert.ContainerID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "container is outside of your scope"})
+		return
+	}
+
+	alert.ID = 0
+	alert.Status = models.AlertStatusActive
+	if alert.StartTime.IsZero() {
+		alert.StartTime = time.Now()
+	}
+	alert.LastSeenAt = alert.StartTime
+	alert.OccurrenceCount = 1
+
+	// Save the alert and queue its notifications; delivery happens in the
+	// background and can be followed through the deliveries endpoint
+	if err := s.alertManager.SendAlert(&alert); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	audit.Record(c, audit.Event{Action: audit.ActionAlertCreate, TargetType: "alert", TargetID: alert.ID, After: alert})
+	
+	c.JSON(http.StatusCreated, alert)
+}
+
+func (s *Server) listAlertDeliveries(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alert ID"})
+		return
+	}
+
+	deliveries, err := s.alertManager.ListDeliveries(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func (s *Server) listAlertEscalations(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alert ID"})
+		return
+	}
+
+	escalations, err := s.alertManager.ListEscalations(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, escalations)
+}
+
+// acknowledgeAlert records the authenticated user as acknowledging the alert
+func (s *Server) acknowledgeAlert(c *gin.Context) {
+	actor := c.MustGet("user").(models.User)
+
+	var before models.Alert
+	if err := database.GetDB().First(&before, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "alert not found"})
+		return
+	}
+
+	if err := s.alertManager.AcknowledgeAlert(c.Param("id"), actor.Username); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, alert.ErrInvalidTransition) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	s.recordAlert(c, audit.ActionAlertAcknowledge, &before)
+
+	c.Status(http.StatusOK)
+}
+
+// resolveAlert records the authenticated user as resolving the alert
+func (s *Server) resolveAlert(c *gin.Context) {
+	actor := c.MustGet("user").(models.User)
+
+	var before models.Alert
+	if err := database.GetDB().First(&before, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "alert not found"})
+		return
+	}
+
+	if err := s.alertManager.ResolveAlert(c.Param("id"), actor.Username); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, alert.ErrInvalidTransition) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	s.recordAlert(c, audit.ActionAlertResolve, &before)
+
+	c.Status(http.StatusOK)
+}
+
+// recordAlert records a change of an alert, reloading it to compare with
+// its state before
+func (s *Server) recordAlert(c *gin.Context, action string, before *models.Alert) {
+	var after models.Alert
+	if err := database.GetDB().First(&after, before.ID).Error; err != nil {
+		log.Printf("Error reloading alert %d for the audit log: %v", before.ID, err)
+	}
+	audit.Record(c, audit.Event{Action: action, TargetType: "alert", TargetID: before.ID, Before: before, After: &after})
+}
+
+// silenceResponse adds the silence's current state to the stored record
+type silenceResponse struct {
+	models.Silence
+	Active     bool       `json:"active"`
+	NextWindow *time.Time `json:"next_window,omitempty"`
+}
+
+func newSilenceResponse(silence models.Silence) silenceResponse {
+	resp := silenceResponse{Silence: silence, Active: alert.IsSilenceActive(&silence)}
+	if next := alert.NextWindow(&silence, time.Now()); !next.IsZero() {
+		resp.NextWindow = &next
+	}
+	return resp
+}
+
+func (s *Server) listSilences(c *gin.Context) {
+	// Expired silences are only listed on request
+	silences, err := s.alertManager.ListSilences(c.Query("expired") != "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := make([]silenceResponse, 0, len(silences))
+	for _, silence := range silences {
+		resp = append(resp, newSilenceResponse(silence))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) getSilence(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid silence ID"})
+		return
+	}
+
+	silence, err := s.alertManager.GetSilence(uint(id))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, alert.ErrSilenceNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newSilenceResponse(*silence))
+}
+
+func (s *Server) createSilence(c *gin.Context) {
+	var silence models.Silence
+	if err := c.ShouldBindJSON(&silence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	silence.ID = 0
+	if user, ok := c.Get("user"); ok {
+		silence.CreatedBy = user.(models.User).Username
+	}
+
+	if err := alert.ValidateSilence(&silence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.alertManager.CreateSilence(&silence); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	audit.Record(c, audit.Event{Action: audit.ActionSilenceCreate, TargetType: "silence", TargetID: silence.ID, After: silence})
+
+	c.JSON(http.StatusCreated, newSilenceResponse(silence))
+}
+
+func (s *Server) updateSilence(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid silence ID"})
+		return
+	}
+
+	var silence models.Silence
+	if err := c.ShouldBindJSON(&silence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	silence.ID = uint(id)
+
+	if err := alert.ValidateSilence(&silence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, _ := s.alertManager.GetSilence(silence.ID)
+	if err := s.alertManager.UpdateSilence(&silence); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, alert.ErrSilenceNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	audit.Record(c, audit.Event{Action: audit.ActionSilenceUpdate, TargetType: "silence", TargetID: silence.ID, Before: before, After: silence})
+
+	c.JSON(http.StatusOK, newSilenceResponse(silence))
+}
+
+func (s *Server) expireSilence(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid silence ID"})
+		return
+	}
+
+	before, _ := s.alertManager.GetSilence(uint(id))
+	if err := s.alertManager.ExpireSilence(uint(id)); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, alert.ErrSilenceNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	after, _ := s.alertManager.GetSilence(uint(id))
+	audit.Record(c, audit.Event{Action: audit.ActionSilenceExpire, TargetType: "silence", TargetID: id, Before: before, After: after})
+
+	c.JSON(http.StatusOK, gin.H{"message": "silence expired successfully"})
+}
+
+func (s *Server) listHosts(c *gin.Context) {
+	hosts, err := s.hosts.ListHosts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope := auth.ScopeOf(c)
+	visible := hosts[:0]
+	for _, host := range hosts {
+		if scope.AllowsHost(host.Name) {
+			visible = append(visible, host)
+		}
+	}
+	hosts = visible
+
+	c.JSON(http.StatusOK, hosts)
+}
+
+func (s *Server) getHost(c *gin.Context) {
+	host, err := s.hosts.GetHost(c.Param("name"))
+	if err != nil {
+		c.JSON(hostErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, host)
+}
+
+// hostRequest is the body of host create and update requests. Hosts are
+// enabled unless enabled is set to false.
+type hostRequest struct {
+	Name      string `json:"name"`
+	Endpoint  string `json:"endpoint"`
+	TLSCACert string `json:"tls_ca_cert"`
+	TLSCert   string `json:"tls_cert"`
+	TLSKey    string `json:"tls_key"`
+	Push      bool   `json:"push"`
+	Enabled   *bool  `json:"enabled"`
+}
+
+func (r *hostRequest) host() *models.Host {
+	host := &models.Host{
+		Name:      r.Name,
+		Endpoint:  r.Endpoint,
+		TLSCACert: r.TLSCACert,
+		TLSCert:   r.TLSCert,
+		TLSKey:    r.TLSKey,
+		Push:      r.Push,
+		Enabled:   true,
+	}
+	if r.Enabled != nil {
+		host.Enabled = *r.Enabled
+	}
+	return host
+}
+
+func (s *Server) createHost(c *gin.Context) {
+	var req hostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !auth.ScopeOf(c).AllowsHost(req.Name) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "host is outside of your scope"})
+		return
+	}
+	if _, err := s.hosts.GetHost(req.Name); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "host already exists"})
+		return
+	}
+
+	host := req.host()
+	if err := monitor.ValidateHost(host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.hosts.CreateHost(host); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	audit.Record(c, audit.Event{Action: audit.ActionHostCreate, TargetType: "host", TargetID: host.Name, After: host})
+
+	c.JSON(http.StatusCreated, host)
+}
+
+func (s *Server) updateHost(c *gin.Context) {
+	var req hostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The name identifies the host's data and cannot be changed
+	req.Name = c.Param("name")
+	host := req.host()
+	if err := monitor.ValidateHost(host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, _ := s.hosts.GetHost(host.Name)
+	if err := s.hosts.UpdateHost(host); err != nil {
+		c.JSON(hostErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	audit.Record(c, audit.Event{Action: audit.ActionHostUpdate, TargetType: "host", TargetID: host.Name, Before: before, After: host})
+
+	c.JSON(http.StatusOK, host)
+}
+
+func (s *Server) deleteHost(c *gin.Context) {
+	before, _ := s.hosts.GetHost(c.Param("name"))
+	if err := s.hosts.DeleteHost(c.Param("name")); err != nil {
+		c.JSON(hostErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	audit.Record(c, audit.Event{Action: audit.ActionHostDelete, TargetType: "host", TargetID: c.Param("name"), Before: before})
+
+	c.JSON(http.StatusOK, gin.H{"message": "host deleted successfully"})
+}
+
+// ingest stores a batch of stats and container inventory pushed by an agent
+func (s *Server) ingest(c *gin.Context) {
+	var batch models.IngestBatch
+	if err := c.ShouldBindJSON(&batch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if batch.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "host is required"})
+		return
+	}
+	if !auth.ScopeOf(c).AllowsHost(batch.Host) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "host is outside of your scope"})
+		return
+	}
+
+	// Pushing for a host that is not registered yet adds it, which takes the
+	// permission to add hosts
+	if err := s.hosts.Ingest(&batch, auth.HasPermission(c, models.PermHostsWrite)); err != nil {
+		c.JSON(hostErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stats": len(batch.Stats), "containers": len(batch.Containers)})
+}
+
+func hostErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, monitor.ErrHostNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, monitor.ErrStaticHost), errors.Is(err, monitor.ErrNotPushHost):
+		return http.StatusConflict
+	case errors.Is(err, monitor.ErrHostDisabled):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+func (s *Server) login(c *gin.Context) {
+	var loginReq struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	
+	if err := c.ShouldBindJSON(&loginReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	user, err := auth.Authenticate(loginReq.Username, loginReq.Password)
+	if err != nil {
+		audit.RecordFor(c, &models.User{Username: loginReq.Username}, audit.Event{
+			Action:     audit.ActionLoginFailed,
+			TargetType: "user",
+			TargetID:   loginReq.Username,
+			Detail:     err.Error(),
+		})
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	
+	tokens, err := auth.IssueTokens(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+	audit.RecordFor(c, user, audit.Event{Action: audit.ActionLogin, TargetType: "user", TargetID: user.ID})
+	
+	c.JSON(http.StatusOK, tokens)
 }
 
-func (s *Server) Start(port int) error {
-	return s.router.Run(fmt.Sprintf(":%d", port))
+// refresh exchanges a refresh token for a new access and refresh token.
+// Presenting a refresh token twice revokes its session.
+func (s *Server) refresh(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, user, err := auth.RefreshTokens(req.RefreshToken)
+	if errors.Is(err, auth.ErrRefreshTokenReused) {
+		audit.RecordFor(c, user, audit.Event{Action: audit.ActionRefreshReused, TargetType: "user", TargetID: user.ID, Detail: err.Error()})
+	}
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	audit.RecordFor(c, user, audit.Event{Action: audit.ActionRefresh, TargetType: "user", TargetID: user.ID})
+
+	c.JSON(http.StatusOK, tokens)
 }
 
-func (s *Server) listContainers(c *gin.Context) {
-	stats, err := s.collector.CollectContainerStats()
+// logout revokes the access token of the request and its session, or every
+// session of the user with all=true
+func (s *Server) logout(c *gin.Context) {
+	var req struct {
+		All bool `json:"all"`
+	}
+	// The body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	value, ok := c.Get("token_claims")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "API keys cannot log out, revoke the key instead"})
+		return
+	}
+	claims := value.(*auth.Claims)
+
+	err := auth.RevokeToken(claims)
+	if err == nil && req.All {
+		err = auth.RevokeUserSessions(claims.UserID, "")
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	
-	c.JSON(http.StatusOK, stats)
+
+	event := audit.Event{Action: audit.ActionLogout, TargetType: "user", TargetID: claims.UserID}
+	if req.All {
+		event.Detail = "all sessions"
+	}
+	audit.Record(c, event)
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
 }
 
-func (s *Server) getContainerStats(c *gin.Context) {
-	containerID := c.Param("id")
-	var stats []models.ContainerStats
+// register signs up a user when registration is enabled in the config
+func (s *Server) register(c *gin.Context) {
+	var req struct {
+		Username    string `json:"username" binding:"required"`
+		Email       string `json:"email"`
+		Password    string `json:"password" binding:"required"`
+		InviteToken string `json:"invite_token"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	query := database.GetDB().Where("container_id = ?", containerID)
+	input := auth.UserInput{Username: req.Username, Password: req.Password}
+	if req.Email != "" {
+		input.Email = &req.Email
+	}
+	user, err := auth.Register(input, req.InviteToken)
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	audit.RecordFor(c, user, audit.Event{Action: audit.ActionRegister, TargetType: "user", TargetID: user.ID, After: user})
 
-	// Add time range filter if provided
-	if startTime := c.Query("start"); startTime != "" {
-		if t, err := time.Parse(time.RFC3339, startTime); err == nil {
-			query = query.Where("timestamp >= ?", t)
-		}
+	c.JSON(http.StatusCreated, user)
+}
+
+// changePassword sets the authenticated user's password
+func (s *Server) changePassword(c *gin.Context) {
+	var req struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
 	}
-	if endTime := c.Query("end"); endTime != "" {
-		if t, err := time.Parse(time.RFC3339, endTime); err == nil {
-			query = query.Where("timestamp <= ?", t)
-		}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	// Add limit if provided
-	if limit := c.Query("limit"); limit != "" {
-		if l, err := strconv.Atoi(limit); err == nil {
-			query = query.Limit(l)
-		}
+	// Other sessions of the user have to log in with the new password
+	var session string
+	if claims, ok := c.Get("token_claims"); ok {
+		session = claims.(*auth.Claims).SessionID
 	}
 
-	// Execute query
-	if err := query.Order("timestamp desc").Find(&stats).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch container stats"})
+	user := c.MustGet("user").(models.User)
+	if err := auth.ChangePassword(&user, req.CurrentPassword, req.NewPassword, session); err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
+	audit.Record(c, audit.Event{Action: audit.ActionPasswordChange, TargetType: "user", TargetID: user.ID})
 
-	c.JSON(http.StatusOK, stats)
+	c.JSON(http.StatusOK, gin.H{"message": "password changed successfully"})
 }
 
-func (s *Server) listAlerts(c *gin.Context) {
-	// TODO: Implement alert listing with filtering and pagination
-	c.JSON(http.StatusOK, []models.Alert{})
+func (s *Server) listUsers(c *gin.Context) {
+	users, err := auth.ListUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
 }
 
-func (s *Server) createAlert(c *gin.Context) {
-	var alert models.Alert
-	if err := c.BindJSON(&alert); err != nil {
+func (s *Server) getUser(c *gin.Context) {
+	user, err := auth.GetUser(c.Param("id"))
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (s *Server) createUser(c *gin.Context) {
+	var input auth.UserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	
-	// TODO: Save alert to database
-	
-	// Send notifications based on alert level
-	if alert.Level == models.AlertLevelCritical {
-		go s.alertManager.SendSlackAlert(&alert)
-		go s.alertManager.SendEmailAlert(&alert)
-	} else if alert.Level == models.AlertLevelWarning {
-		go s.alertManager.SendSlackAlert(&alert)
+
+	user, err := auth.CreateUser(input)
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
 	}
-	
-	c.JSON(http.StatusCreated, alert)
+	audit.Record(c, audit.Event{Action: audit.ActionUserCreate, TargetType: "user", TargetID: user.ID, After: user})
+
+	c.JSON(http.StatusCreated, user)
 }
 
-func (s *Server) acknowledgeAlert(c *gin.Context) {
-	var req struct {
-		UserID string `json:"user_id" binding:"required"`
-	}
-	
-	if err := c.ShouldBindJSON(&req); err != nil {
+// updateUser changes a user's email, role or active flag, or resets its
+// password; users are addressed by ID or username
+func (s *Server) updateUser(c *gin.Context) {
+	var input auth.UserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := s.alertManager.AcknowledgeAlert(c.Param("id"), req.UserID); err != nil {
+	before, _ := auth.GetUser(c.Param("id"))
+	user, err := auth.UpdateUser(c.Param("id"), input)
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	event := audit.Event{Action: audit.ActionUserUpdate, TargetType: "user", TargetID: user.ID, Before: before, After: user}
+	if input.Password != "" {
+		event.Detail = "password reset"
+	}
+	audit.Record(c, event)
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (s *Server) deleteUser(c *gin.Context) {
+	before, err := auth.GetUser(c.Param("id"))
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if err := auth.DeleteUser(c.Param("id")); err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	audit.Record(c, audit.Event{Action: audit.ActionUserDelete, TargetType: "user", TargetID: before.ID, Before: before})
+
+	c.JSON(http.StatusOK, gin.H{"message": "user deleted successfully"})
+}
+
+// listAPIKeys lists the authenticated user's API keys
+func (s *Server) listAPIKeys(c *gin.Context) {
+	keys, err := auth.ListAPIKeys(c.GetUint("user_id"))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.Status(http.StatusOK)
+	c.JSON(http.StatusOK, keys)
 }
 
-func (s *Server) resolveAlert(c *gin.Context) {
+// createAPIKey creates an API key for the authenticated user. The key is
+// only returned in this response.
+func (s *Server) createAPIKey(c *gin.Context) {
 	var req struct {
-		UserID string `json:"user_id" binding:"required"`
+		auth.APIKeyInput
+		// ExpiresIn is a duration such as "720h", instead of expires_at
+		ExpiresIn string `json:"expires_in"`
 	}
-	
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expires_in, expected a positive duration such as 720h"})
+			return
+		}
+		expiresAt := time.Now().Add(d)
+		req.ExpiresAt = &expiresAt
+	}
 
-	if err := s.alertManager.ResolveAlert(c.Param("id"), req.UserID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// A key created with a key is limited by it
+	var parent *models.APIKey
+	if key, ok := c.Get("api_key"); ok {
+		parent = key.(*models.APIKey)
+	}
+
+	user := c.MustGet("user").(models.User)
+	key, plain, err := auth.CreateAPIKey(&user, req.APIKeyInput, parent)
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
+	audit.Record(c, audit.Event{Action: audit.ActionAPIKeyCreate, TargetType: "apikey", TargetID: key.ID, After: key})
 
-	c.Status(http.StatusOK)
+	c.JSON(http.StatusCreated, struct {
+		*models.APIKey
+		Key string `json:"key"`
+	}{key, plain})
 }
 
-func (s *Server) login(c *gin.Context) {
-	var loginReq struct {
-		Username string `json:"username" binding:"required"`
-		Password string `json:"password" binding:"required"`
+// revokeAPIKey revokes one of the authenticated user's API keys
+func (s *Server) revokeAPIKey(c *gin.Context) {
+	s.revokeKey(c, c.GetUint("user_id"))
+}
+
+// listAllAPIKeys lists the API keys of all users, or of the user given
+// by ID or username in the user parameter
+func (s *Server) listAllAPIKeys(c *gin.Context) {
+	var userID uint
+	if ref := c.Query("user"); ref != "" {
+		user, err := auth.GetUser(ref)
+		if err != nil {
+			c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		userID = user.ID
 	}
-	
-	if err := c.ShouldBindJSON(&loginReq); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+	keys, err := auth.ListAPIKeys(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	
-	var user models.User
-	if err := database.GetDB().Where("username = ?", loginReq.Username).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+
+	c.JSON(http.StatusOK, keys)
+}
+
+func (s *Server) revokeAnyAPIKey(c *gin.Context) {
+	s.revokeKey(c, 0)
+}
+
+func (s *Server) revokeKey(c *gin.Context, userID uint) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid api key ID"})
 		return
 	}
-	
-	if !user.CheckPassword(loginReq.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+
+	var before models.APIKey
+	database.GetDB().First(&before, id)
+	if err := auth.RevokeAPIKey(userID, uint(id)); err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	
-	token, err := auth.GenerateToken(&user)
+	audit.Record(c, audit.Event{Action: audit.ActionAPIKeyRevoke, TargetType: "apikey", TargetID: id, Before: &before})
+
+	c.JSON(http.StatusOK, gin.H{"message": "api key revoked successfully"})
+}
+
+// getCurrentUser returns the authenticated user with the permissions and
+// scope the request was granted
+func (s *Server) getCurrentUser(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	resp := gin.H{
+		"user":        user,
+		"permissions": c.MustGet("permissions").(auth.PermissionSet).List(),
+	}
+	if key, ok := c.Get("api_key"); ok {
+		resp["api_key"] = key
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) listPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, auth.Catalog())
+}
+
+func (s *Server) listRoles(c *gin.Context) {
+	roles, err := auth.Roles()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	
-	c.JSON(http.StatusOK, gin.H{"token": token})
+
+	c.JSON(http.StatusOK, roles)
 }
 
-func (s *Server) register(c *gin.Context) {
-	// TODO: Implement user registration
+// updateRole replaces the permissions of the user or viewer role
+func (s *Server) updateRole(c *gin.Context) {
+	var req struct {
+		Permissions []models.Permission `json:"permissions" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := findRole(models.Role(c.Param("role")))
+	role, err := auth.SetRolePermissions(models.Role(c.Param("role")), req.Permissions)
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	audit.Record(c, audit.Event{Action: audit.ActionRoleUpdate, TargetType: "role", TargetID: role.Role, Before: before, After: role})
+
+	c.JSON(http.StatusOK, role)
 }
 
-func (s *Server) listUsers(c *gin.Context) {
-	// TODO: Implement user listing
+// resetRole restores the default permissions of the user or viewer role
+func (s *Server) resetRole(c *gin.Context) {
+	before := findRole(models.Role(c.Param("role")))
+	role, err := auth.ResetRolePermissions(models.Role(c.Param("role")))
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	audit.Record(c, audit.Event{Action: audit.ActionRoleReset, TargetType: "role", TargetID: role.Role, Before: before, After: role})
+
+	c.JSON(http.StatusOK, role)
 }
 
-func (s *Server) createUser(c *gin.Context) {
-	// TODO: Implement user creation
+// findRole returns the current permissions of a role for the audit log,
+// nil when they cannot be loaded
+func findRole(role models.Role) *auth.RoleInfo {
+	roles, err := auth.Roles()
+	if err != nil {
+		return nil
+	}
+	for i := range roles {
+		if roles[i].Role == role {
+			return &roles[i]
+		}
+	}
+	return nil
 }
 
-func (s *Server) updateUser(c *gin.Context) {
-	// TODO: Implement user update
+func userErrorStatus(err error) int {
+	var invalid *auth.ValidationError
+	switch {
+	case errors.As(err, &invalid), errors.Is(err, auth.ErrAdminRole):
+		return http.StatusBadRequest
+	case errors.Is(err, auth.ErrUserNotFound), errors.Is(err, auth.ErrAPIKeyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, auth.ErrUserExists), errors.Is(err, auth.ErrLastAdmin):
+		return http.StatusConflict
+	case errors.Is(err, auth.ErrInvalidCredentials), errors.Is(err, auth.ErrInvalidRefreshToken), errors.Is(err, auth.ErrRefreshTokenReused):
+		return http.StatusUnauthorized
+	case errors.Is(err, auth.ErrUserInactive), errors.Is(err, auth.ErrRegistrationDisabled), errors.Is(err, auth.ErrInvalidInvite):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
 }
 
-func (s *Server) deleteUser(c *gin.Context) {
-	// TODO: Implement user deletion
+// listAudit returns audit log entries, newest first unless sort=asc, paged
+// like alerts
+func (s *Server) listAudit(c *gin.Context) {
+	query := database.GetDB().Model(&models.AuditEntry{})
+
+	if username := c.Query("user"); username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if action := c.Query("action"); action != "" {
+		// "rule" selects every rule action
+		if strings.Contains(action, ".") {
+			query = query.Where("action = ?", action)
+		} else {
+			query = query.Where(`action LIKE ? ESCAPE '\'`, escapeLike(action)+".%")
+		}
+	}
+	if targetType := c.Query("target_type"); targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	if targetID := c.Query("target_id"); targetID != "" {
+		query = query.Where("target_id = ?", targetID)
+	}
+	if keyID := c.Query("api_key_id"); keyID != "" {
+		id, err := strconv.ParseUint(keyID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid api_key_id"})
+			return
+		}
+		query = query.Where("api_key_id = ?", id)
+	}
+	if ip := c.Query("source_ip"); ip != "" {
+		query = query.Where("source_ip = ?", ip)
+	}
+	if startTime := c.Query("start"); startTime != "" {
+		t, err := time.Parse(time.RFC3339, startTime)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start time, expected RFC3339"})
+			return
+		}
+		query = query.Where("created_at >= ?", t)
+	}
+	if endTime := c.Query("end"); endTime != "" {
+		t, err := time.Parse(time.RFC3339, endTime)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end time, expected RFC3339"})
+			return
+		}
+		query = query.Where("created_at <= ?", t)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count audit entries"})
+		return
+	}
+
+	limit := defaultAlertPageSize
+	if l := c.Query("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxAlertPageSize {
+		limit = maxAlertPageSize
+	}
+
+	ascending := false
+	switch strings.ToLower(c.DefaultQuery("sort", "desc")) {
+	case "asc":
+		ascending = true
+	case "desc":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be asc or desc"})
+		return
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		id, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		if ascending {
+			query = query.Where("id > ?", id)
+		} else {
+			query = query.Where("id < ?", id)
+		}
+	}
+
+	order := "id desc"
+	if ascending {
+		order = "id asc"
+	}
+
+	// Fetch one extra row to find out whether another page exists
+	var entries []models.AuditEntry
+	if err := query.Order(order).Limit(limit + 1).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit entries"})
+		return
+	}
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+		c.Header("X-Next-Cursor", strconv.FormatUint(uint64(entries[len(entries)-1].ID), 10))
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	c.JSON(http.StatusOK, entries)
 }
 
 // Rule management handlers
@@ -289,6 +1634,7 @@ func (s *Server) createRule(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	audit.Record(c, audit.Event{Action: audit.ActionRuleCreate, TargetType: "rule", TargetID: rule.ID, After: rule})
 
 	c.JSON(http.StatusCreated, rule)
 }
@@ -314,10 +1660,12 @@ func (s *Server) updateRule(c *gin.Context) {
 		return
 	}
 
+	before, _ := s.ruleManager.GetRule(rule.ID)
 	if err := s.ruleManager.UpdateRule(&rule); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	audit.Record(c, audit.Event{Action: audit.ActionRuleUpdate, TargetType: "rule", TargetID: rule.ID, Before: before, After: rule})
 
 	c.JSON(http.StatusOK, rule)
 }
@@ -329,10 +1677,12 @@ func (s *Server) deleteRule(c *gin.Context) {
 		return
 	}
 
+	before, _ := s.ruleManager.GetRule(uint(id))
 	if err := s.ruleManager.DeleteRule(uint(id)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	audit.Record(c, audit.Event{Action: audit.ActionRuleDelete, TargetType: "rule", TargetID: id, Before: before})
 
 	c.JSON(http.StatusOK, gin.H{"message": "rule deleted successfully"})
 }
@@ -344,10 +1694,13 @@ func (s *Server) enableRule(c *gin.Context) {
 		return
 	}
 
+	before, _ := s.ruleManager.GetRule(uint(id))
 	if err := s.ruleManager.EnableRule(uint(id)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	after, _ := s.ruleManager.GetRule(uint(id))
+	audit.Record(c, audit.Event{Action: audit.ActionRuleEnable, TargetType: "rule", TargetID: id, Before: before, After: after})
 
 	c.JSON(http.StatusOK, gin.H{"message": "rule enabled successfully"})
 }
@@ -359,10 +1712,13 @@ func (s *Server) disableRule(c *gin.Context) {
 		return
 	}
 
+	before, _ := s.ruleManager.GetRule(uint(id))
 	if err := s.ruleManager.DisableRule(uint(id)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	after, _ := s.ruleManager.GetRule(uint(id))
+	audit.Record(c, audit.Event{Action: audit.ActionRuleDisable, TargetType: "rule", TargetID: id, Before: before, After: after})
 
 	c.JSON(http.StatusOK, gin.H{"message": "rule disabled successfully"})
 }
@@ -375,11 +1731,24 @@ func (s *Server) validateRule(c *gin.Context) {
 	}
 
 	if err := s.validateRuleFields(&rule); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		resp := gin.H{"error": err.Error()}
+		var parseErr *alert.ParseError
+		if errors.As(err, &parseErr) {
+			resp["position"] = parseErr.Pos + 1
+		}
+		c.JSON(http.StatusBadRequest, resp)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "rule is valid"})
+	resp := gin.H{"message": "rule is valid"}
+	if rule.Expression != "" {
+		// Show how the expression was understood
+		expr, _ := alert.CompileExpression(rule.Expression)
+		resp["expression"] = expr.String()
+		resp["metrics"] = expr.Metrics()
+		resp["max_window"] = expr.MaxWindow().String()
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 func (s *Server) validateRuleFields(rule *models.AlertRule) error {
@@ -387,22 +1756,57 @@ func (s *Server) validateRuleFields(rule *models.AlertRule) error {
 		return fmt.Errorf("rule name is required")
 	}
 
-	if !isValidMetric(rule.Metric) {
-		return fmt.Errorf("invalid metric: %s", rule.Metric)
+	if rule.Expression != "" && rule.Event != "" {
+		return fmt.Errorf("a rule cannot have both an expression and an event")
 	}
 
-	if !isValidOperator(rule.Operator) {
-		return fmt.Errorf("invalid operator: %s", rule.Operator)
+	if rule.Expression != "" {
+		if _, err := alert.CompileExpression(rule.Expression); err != nil {
+			return fmt.Errorf("invalid expression: %w", err)
+		}
+	} else if rule.Event != "" {
+		if !isValidEvent(rule.Event) {
+			return fmt.Errorf("invalid event: %s", rule.Event)
+		}
+
+		if !isValidOperator(rule.Operator) {
+			return fmt.Errorf("invalid operator: %s", rule.Operator)
+		}
+
+		if rule.EventWindow <= 0 {
+			return fmt.Errorf("event window must be positive")
+		}
+	} else {
+		if !isValidMetric(rule.Metric) {
+			return fmt.Errorf("invalid metric: %s", rule.Metric)
+		}
+
+		if !isValidOperator(rule.Operator) {
+			return fmt.Errorf("invalid operator: %s", rule.Operator)
+		}
 	}
 
 	if !isValidAlertLevel(rule.Level) {
 		return fmt.Errorf("invalid alert level: %s", rule.Level)
 	}
 
-	if rule.Duration <= 0 {
+	// Event rules fire as soon as the events are counted
+	if rule.Duration < 0 || rule.Duration == 0 && rule.Event == "" {
 		return fmt.Errorf("duration must be positive")
 	}
 
+	if rule.CooldownPeriod < 0 {
+		return fmt.Errorf("cooldown period must not be negative")
+	}
+
+	if rule.RecoveryPeriod < 0 {
+		return fmt.Errorf("recovery period must not be negative")
+	}
+
+	if err := alert.ValidateRuleTarget(rule); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -420,11 +1824,13 @@ func (s *Server) importRules(c *gin.Context) {
 		}
 	}
 
-	for _, rule := range rules {
-		if err := s.ruleManager.CreateRule(&rule); err != nil {
+	for i := range rules {
+		rule := &rules[i]
+		if err := s.ruleManager.CreateRule(rule); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to import rule '%s': %v", rule.Name, err)})
 			return
 		}
+		audit.Record(c, audit.Event{Action: audit.ActionRuleImport, TargetType: "rule", TargetID: rule.ID, After: rule})
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("successfully imported %d rules", len(rules))})
@@ -459,19 +1865,24 @@ func (s *Server) testRule(c *gin.Context) {
 		return
 	}
 
-	var alerts []models.Alert
-	var err error
-
+	// Sample data covers the last hour
+	var startTime, endTime time.Time
 	if request.UseSample {
-		alerts, err = s.ruleManager.TestRuleWithSampleData(&request.Rule)
+		endTime = time.Now()
+		startTime = endTime.Add(-time.Hour)
 	} else {
 		if request.StartTime == nil || request.EndTime == nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "start_time and end_time are required for historical data testing"})
 			return
 		}
-		alerts, err = s.ruleManager.TestRule(&request.Rule, *request.StartTime, *request.EndTime)
+		startTime, endTime = *request.StartTime, *request.EndTime
+		if !endTime.After(startTime) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end_time must be after start_time"})
+			return
+		}
 	}
 
+	alerts, err := s.ruleManager.TestRule(&request.Rule, startTime, endTime)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -482,8 +1893,8 @@ func (s *Server) testRule(c *gin.Context) {
 		"alerts": alerts,
 		"summary": gin.H{
 			"total_alerts":    len(alerts),
-			"test_duration":   request.EndTime.Sub(*request.StartTime).String(),
-			"alerts_per_hour": float64(len(alerts)) / request.EndTime.Sub(*request.StartTime).Hours(),
+			"test_duration":   endTime.Sub(startTime).String(),
+			"alerts_per_hour": float64(len(alerts)) / endTime.Sub(startTime).Hours(),
 		},
 	})
 }
@@ -495,10 +1906,31 @@ func isValidMetric(metric models.Metric) bool {
 		models.MetricMemoryUsage: true,
 		models.MetricDiskIO:      true,
 		models.MetricNetworkIO:   true,
+		models.MetricDiskIORate:          true,
+		models.MetricDiskReadRate:        true,
+		models.MetricDiskWriteRate:       true,
+		models.MetricNetworkIORate:       true,
+		models.MetricNetworkRxRate:       true,
+		models.MetricNetworkTxRate:       true,
+		models.MetricNetworkRxPacketRate: true,
+		models.MetricNetworkTxPacketRate: true,
+		models.MetricNetworkRxErrorRate:  true,
+		models.MetricNetworkTxErrorRate:  true,
+		models.MetricHealthUnhealthy:     true,
+		models.MetricHealthFailingStreak: true,
 	}
 	return validMetrics[metric]
 }
 
+func isValidEvent(event models.EventType) bool {
+	switch event {
+	case models.EventStart, models.EventStop, models.EventDie,
+		models.EventOOM, models.EventRestart, models.EventHealthStatus:
+		return true
+	}
+	return false
+}
+
 func isValidOperator(operator models.Operator) bool {
 	validOperators := map[models.Operator]bool{
 		models.OperatorGT:  true,
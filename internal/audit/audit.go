@@ -0,0 +1,164 @@
+// Package audit records who changed what through the API
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+
+	"containereye/internal/database"
+	"containereye/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionLogin          = "auth.login"
+	ActionLoginFailed    = "auth.login_failed"
+	ActionRefresh        = "auth.refresh"
+	ActionRefreshReused  = "auth.refresh_reused"
+	ActionLogout         = "auth.logout"
+	ActionRegister       = "auth.register"
+	ActionPasswordChange = "auth.password_change"
+
+	ActionUserCreate = "user.create"
+	ActionUserUpdate = "user.update"
+	ActionUserDelete = "user.delete"
+
+	ActionAPIKeyCreate = "apikey.create"
+	ActionAPIKeyRevoke = "apikey.revoke"
+
+	ActionRoleUpdate = "role.update"
+	ActionRoleReset  = "role.reset"
+
+	ActionRuleCreate  = "rule.create"
+	ActionRuleUpdate  = "rule.update"
+	ActionRuleDelete  = "rule.delete"
+	ActionRuleEnable  = "rule.enable"
+	ActionRuleDisable = "rule.disable"
+	ActionRuleImport  = "rule.import"
+
+	ActionAlertCreate      = "alert.create"
+	ActionAlertAcknowledge = "alert.acknowledge"
+	ActionAlertResolve     = "alert.resolve"
+
+	ActionSilenceCreate = "silence.create"
+	ActionSilenceUpdate = "silence.update"
+	ActionSilenceExpire = "silence.expire"
+
+	ActionHostCreate = "host.create"
+	ActionHostUpdate = "host.update"
+	ActionHostDelete = "host.delete"
+)
+
+// Timestamps change on every write and are left out of the changes
+var ignoredFields = map[string]bool{"CreatedAt": true, "UpdatedAt": true, "DeletedAt": true}
+
+// Event is a change to record. Before and After are the target's state
+// around the change, nil when it did not exist; they are compared by their
+// JSON fields, so secrets hidden from the API stay out of the log.
+type Event struct {
+	Action     string
+	TargetType string
+	TargetID   interface{}
+	Before     interface{}
+	After      interface{}
+	Detail     string
+}
+
+// Record stores an event made by the request's user and API key
+func Record(c *gin.Context, e Event) {
+	var user *models.User
+	if u, ok := c.Get("user"); ok {
+		u := u.(models.User)
+		user = &u
+	}
+	RecordFor(c, user, e)
+}
+
+// RecordFor stores an event made by user, for requests that are not
+// authenticated such as logins. The user's ID may be zero when the
+// attempted username does not exist.
+func RecordFor(c *gin.Context, user *models.User, e Event) {
+	entry := models.AuditEntry{
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		Detail:     e.Detail,
+		SourceIP:   c.ClientIP(),
+	}
+	if e.TargetID != nil {
+		entry.TargetID = fmt.Sprint(e.TargetID)
+	}
+	if user != nil {
+		entry.Username = user.Username
+		if user.ID != 0 {
+			id := user.ID
+			entry.UserID = &id
+		}
+	}
+	if key, ok := c.Get("api_key"); ok {
+		key := key.(*models.APIKey)
+		entry.APIKeyID = &key.ID
+		entry.APIKeyName = key.Name
+	}
+
+	changes, err := Diff(e.Before, e.After)
+	if err != nil {
+		log.Printf("Error comparing %s %s for the audit log: %v", e.TargetType, entry.TargetID, err)
+	}
+	entry.Changes = changes
+
+	if err := database.GetDB().Create(&entry).Error; err != nil {
+		log.Printf("Error recording %s of %s %s in the audit log: %v", e.Action, e.TargetType, entry.TargetID, err)
+	}
+}
+
+// Diff returns the JSON fields that differ between before and after, nil
+// when nothing changed
+func Diff(before, after interface{}) (map[string]models.AuditChange, error) {
+	was, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	is, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]models.AuditChange)
+	for name, value := range is {
+		if ignoredFields[name] {
+			continue
+		}
+		if prev, ok := was[name]; !ok || !reflect.DeepEqual(prev, value) {
+			changes[name] = models.AuditChange{Before: prev, After: value}
+		}
+	}
+	for name, value := range was {
+		if _, ok := is[name]; !ok && !ignoredFields[name] {
+			changes[name] = models.AuditChange{Before: value}
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return changes, nil
+}
+
+// fields returns the JSON object fields of v
+func fields(v interface{}) (map[string]interface{}, error) {
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil() {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"containereye/internal/database"
+	"containereye/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix starts every API key, telling keys apart from JWTs
+const APIKeyPrefix = "cek_"
+
+const (
+	// apiKeyDisplayLength is how much of a key is stored to recognize it
+	apiKeyDisplayLength = len(APIKeyPrefix) + 8
+	// lastUsedInterval limits how often a key's last use is written
+	lastUsedInterval = time.Minute
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+)
+
+// APIKeyInput holds the fields of an API key create request. The scope
+// defaults to the user's role, and keys without an expiry never expire.
+// Keys of the agent scope can only push stats.
+type APIKeyInput struct {
+	Name      string      `json:"name"`
+	Scope     models.Role `json:"scope"`
+	ExpiresAt *time.Time  `json:"expires_at"`
+}
+
+// CreateAPIKey creates a key for user and returns it together with the
+// plain key, which is not stored and cannot be shown again. When the
+// request is authenticated by an API key, parent, the new key can neither
+// have a wider scope nor outlive it.
+func CreateAPIKey(user *models.User, input APIKeyInput, parent *models.APIKey) (*models.APIKey, string, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" || len(name) > 64 {
+		return nil, "", &ValidationError{fmt.Errorf("name must be 1 to 64 characters")}
+	}
+
+	limit := user.Role
+	if parent != nil && !parent.Scope.Includes(limit) {
+		limit = parent.Scope
+	}
+	scope := input.Scope
+	if scope == "" {
+		scope = limit
+	}
+	if !scope.ValidScope() {
+		return nil, "", &ValidationError{fmt.Errorf("unknown scope %q, expected admin, user, viewer or agent", scope)}
+	}
+	if !limit.Includes(scope) {
+		return nil, "", &ValidationError{fmt.Errorf("scope %s exceeds %s", scope, limit)}
+	}
+	// Every role includes the agent scope, but only those allowed to push
+	// stats can hand that on
+	if scope == models.RoleAgent {
+		perms := PermissionsOf(user.Role)
+		if parent != nil {
+			perms = perms.Intersect(PermissionsOf(parent.Scope))
+		}
+		if !perms[models.PermStatsIngest] {
+			return nil, "", &ValidationError{fmt.Errorf("scope agent requires the %s permission", models.PermStatsIngest)}
+		}
+	}
+
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, "", &ValidationError{fmt.Errorf("expiry must be in the future")}
+	}
+	if parent != nil && parent.ExpiresAt != nil {
+		if input.ExpiresAt == nil {
+			input.ExpiresAt = parent.ExpiresAt
+		} else if input.ExpiresAt.After(*parent.ExpiresAt) {
+			return nil, "", &ValidationError{fmt.Errorf("expiry must not be later than the expiry of the key in use")}
+		}
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", fmt.Errorf("failed to generate api key: %v", err)
+	}
+	plain := APIKeyPrefix + hex.EncodeToString(buf)
+
+	key := &models.APIKey{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Name:      name,
+		Prefix:    plain[:apiKeyDisplayLength],
+		Hash:      hashToken(plain),
+		Scope:     scope,
+		ExpiresAt: input.ExpiresAt,
+	}
+	if err := database.GetDB().Create(key).Error; err != nil {
+		return nil, "", err
+	}
+	return key, plain, nil
+}
+
+// ListAPIKeys returns the keys of a user, of all users when userID is 0,
+// newest first
+func ListAPIKeys(userID uint) ([]models.APIKey, error) {
+	query := database.GetDB().Order("id DESC")
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var keys []models.APIKey
+	if err := query.Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, fillUsernames(keys)
+}
+
+// RevokeAPIKey revokes a key of a user, or any key when userID is 0
+func RevokeAPIKey(userID, id uint) error {
+	query := database.GetDB().Where("id = ?", id)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	result := query.Delete(&models.APIKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// authenticateAPIKey returns the user of a key that is neither
+// revoked nor expired, and the key. The key's last use is recorded.
+func authenticateAPIKey(plain string) (*models.User, *models.APIKey, error) {
+	db := database.GetDB()
+
+	var key models.APIKey
+	if err := db.Where("hash = ?", hashToken(plain)).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, err
+	}
+	now := time.Now()
+	if key.Expired(now) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	var user models.User
+	if err := db.First(&user, key.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, err
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedInterval {
+		key.LastUsedAt = &now
+		if err := db.Model(&key).UpdateColumn("last_used_at", now).Error; err != nil {
+			log.Printf("Warning: Failed to record use of api key %d: %v", key.ID, err)
+		}
+	}
+	key.Username = user.Username
+	return &user, &key, nil
+}
+
+// hashToken returns the SHA-256 hash stored for API keys and refresh tokens
+func hashToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// fillUsernames sets the username of the keys' owners
+func fillUsernames(keys []models.APIKey) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	ids := make([]uint, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, key.UserID)
+	}
+
+	var users []models.User
+	if err := database.GetDB().Select("id", "username").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return err
+	}
+	names := make(map[uint]string, len(users))
+	for _, user := range users {
+		names[user.ID] = user.Username
+	}
+	for i := range keys {
+		keys[i].Username = names[keys[i].UserID]
+	}
+	return nil
+}
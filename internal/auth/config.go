@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"containereye/internal/models"
+)
+
+const minSecretLength = 16
+
+// exampleSecrets are the placeholders of the example configuration
+var exampleSecrets = map[string]bool{
+	"your-jwt-secret": true,
+	"your-secret-key": true,
+}
+
+// JWTKey is a secret tokens were signed with, identified by the kid header
+// of the tokens. Without an ID one is derived from the secret.
+type JWTKey struct {
+	ID     string `mapstructure:"id"`
+	Secret string `mapstructure:"secret"`
+}
+
+// Config sets how tokens are signed and how long they are valid, and how
+// accounts are created
+type Config struct {
+	// JWTSecret signs new access tokens. To rotate it, move the old secret
+	// to PreviousJWTKeys until the tokens it signed have expired.
+	JWTSecret       string        `mapstructure:"jwt_secret"`
+	JWTKeyID        string        `mapstructure:"jwt_key_id"`
+	PreviousJWTKeys []JWTKey      `mapstructure:"previous_jwt_keys"`
+	TokenExpiry     time.Duration `mapstructure:"token_expiry"` // Lifetime of access tokens
+
+	// RefreshTokenExpiry is how long a login session lasts without being
+	// refreshed
+	RefreshTokenExpiry time.Duration `mapstructure:"refresh_token_expiry"`
+
+	// AllowRegistration lets anyone sign up through /api/v1/auth/register,
+	// with InviteToken when one is set. Registered users get RegistrationRole.
+	AllowRegistration bool        `mapstructure:"allow_registration"`
+	InviteToken       string      `mapstructure:"invite_token"`
+	RegistrationRole  models.Role `mapstructure:"registration_role"`
+
+	MinPasswordLength int `mapstructure:"min_password_length"`
+
+	// AdminUsername and AdminPassword create the first admin on an empty
+	// database. Without a password a random one is generated and printed to
+	// stderr, which must then be a terminal.
+	AdminUsername string `mapstructure:"admin_username"`
+	AdminPassword string `mapstructure:"admin_password"`
+}
+
+// DefaultConfig returns the token settings used when none are configured.
+// There is no default jwt_secret, it must be configured.
+func DefaultConfig() Config {
+	return Config{
+		TokenExpiry:        15 * time.Minute,
+		RefreshTokenExpiry: 30 * 24 * time.Hour,
+		RegistrationRole:   models.RoleViewer,
+		MinPasswordLength:  8,
+		AdminUsername:      "admin",
+	}
+}
+
+// Validate checks that a secret is set and that the secrets are not
+// placeholders and long enough
+func (c Config) Validate() error {
+	if c.JWTSecret == "" {
+		return fmt.Errorf("auth jwt_secret is required, e.g. the output of openssl rand -hex 32")
+	}
+	if err := checkSecret("jwt_secret", c.JWTSecret); err != nil {
+		return err
+	}
+	ids := map[string]bool{keyID(c.JWTKeyID, c.JWTSecret): true}
+	for i, key := range c.PreviousJWTKeys {
+		name := fmt.Sprintf("previous_jwt_keys[%d].secret", i)
+		if key.Secret == "" {
+			return fmt.Errorf("auth %s is required", name)
+		}
+		if err := checkSecret(name, key.Secret); err != nil {
+			return err
+		}
+		id := keyID(key.ID, key.Secret)
+		if ids[id] {
+			return fmt.Errorf("auth previous_jwt_keys[%d] has the key ID %q of another key", i, id)
+		}
+		ids[id] = true
+	}
+	if c.TokenExpiry <= 0 {
+		return fmt.Errorf("auth token_expiry must be positive")
+	}
+	if c.RefreshTokenExpiry < c.TokenExpiry {
+		return fmt.Errorf("auth refresh_token_expiry must not be shorter than token_expiry")
+	}
+	if !c.RegistrationRole.Valid() {
+		return fmt.Errorf("auth registration_role %q is not a known role", c.RegistrationRole)
+	}
+	if c.MinPasswordLength < minPasswordLength || c.MinPasswordLength > maxPasswordLength {
+		return fmt.Errorf("auth min_password_length must be between %d and %d", minPasswordLength, maxPasswordLength)
+	}
+	if err := validateUsername(c.AdminUsername); err != nil {
+		return fmt.Errorf("auth admin_username: %v", err)
+	}
+	if c.AdminPassword != "" {
+		if err := checkPassword(c.AdminUsername, c.AdminPassword, c.MinPasswordLength); err != nil {
+			return fmt.Errorf("auth admin_password: %v", err)
+		}
+	}
+	return nil
+}
+
+func checkSecret(name, secret string) error {
+	if exampleSecrets[secret] {
+		return fmt.Errorf("auth %s is the example value, set a secret of your own", name)
+	}
+	if len(secret) < minSecretLength {
+		return fmt.Errorf("auth %s must be at least %d characters", name, minSecretLength)
+	}
+	return nil
+}
+
+// keyID returns id, or an ID derived from the secret when it is empty
+func keyID(id, secret string) string {
+	if id != "" {
+		return id
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:4])
+}
+
+// Configure validates and applies the token settings. It must be called
+// before tokens are issued or checked.
+func Configure(c Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	secret := c.JWTSecret
+
+	keys := map[string][]byte{}
+	for _, key := range c.PreviousJWTKeys {
+		keys[keyID(key.ID, key.Secret)] = []byte(key.Secret)
+	}
+	signingKeyID = keyID(c.JWTKeyID, secret)
+	keys[signingKeyID] = []byte(secret)
+
+	jwtKeys = keys
+	tokenExpiry = c.TokenExpiry
+	refreshExpiry = c.RefreshTokenExpiry
+	settings = c
+	return nil
+}
@@ -1,91 +1,108 @@
 package auth
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"containereye/internal/database"
 	"containereye/internal/models"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
 )
 
-var jwtSecret = []byte("your-secret-key") // 在生产环境中应该从配置中读取
-
-type Claims struct {
-	UserID uint
-	Role   models.Role
-	jwt.StandardClaims
-}
-
-func GenerateToken(user *models.User) (string, error) {
-	claims := Claims{
-		UserID: user.ID,
-		Role:   user.Role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
-			IssuedAt:  time.Now().Unix(),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
-}
+var errNotConfigured = errors.New("auth is not configured")
 
+// AuthMiddleware authenticates requests with a JWT or an API key. API keys
+// are sent in the X-API-Key header or as a "Bearer cek_..." token. A key
+// is granted the permissions of both its scope and its user's current role,
+// so permission checks apply to both alike. Access tokens are rejected once
+// they or their session are revoked.
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		auth := c.GetHeader("Authorization")
-		if auth == "" {
+		token := c.GetHeader("X-API-Key")
+		if token == "" {
+			token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+		if token == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header required"})
 			c.Abort()
 			return
 		}
 
-		token := strings.TrimPrefix(auth, "Bearer ")
-		claims := &Claims{}
-
-		tkn, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
+		var user *models.User
+		var role models.Role
+		var perms PermissionSet
+		if strings.HasPrefix(token, APIKeyPrefix) {
+			u, key, err := authenticateAPIKey(token)
+			if err != nil {
+				status := http.StatusUnauthorized
+				if !errors.Is(err, ErrInvalidAPIKey) {
+					status = http.StatusInternalServerError
+				}
+				c.JSON(status, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			user, role = u, key.Scope
+			if !user.Role.Includes(role) {
+				role = user.Role
+			}
+			// Roles' permissions are configurable, so a key's scope may
+			// grant permissions its user lacks
+			perms = PermissionsOf(user.Role).Intersect(PermissionsOf(key.Scope))
+			c.Set("api_key", key)
+		} else {
+			claims, err := parseToken(token)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+				c.Abort()
+				return
+			}
+			revoked, err := isRevoked(claims)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			if revoked {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": ErrTokenRevoked.Error()})
+				c.Abort()
+				return
+			}
 
-		if err != nil || !tkn.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-			c.Abort()
-			return
+			user = &models.User{}
+			if err := database.GetDB().First(user, claims.UserID).Error; err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+				c.Abort()
+				return
+			}
+			role = user.Role
+			perms = PermissionsOf(role)
+			c.Set("token_claims", claims)
 		}
 
-		var user models.User
-		if err := database.GetDB().First(&user, claims.UserID).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+		if !user.IsActive {
+			c.JSON(http.StatusForbidden, gin.H{"error": "user is inactive"})
 			c.Abort()
 			return
 		}
 
-		if !user.IsActive {
-			c.JSON(http.StatusForbidden, gin.H{"error": "user is inactive"})
+		scope, err := NewScope(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("invalid scope of user %s: %v", user.Username, err)})
 			c.Abort()
 			return
 		}
+		if scope != nil {
+			c.Set("scope", scope)
+		}
 
-		c.Set("user", user)
+		c.Set("user", *user)
 		c.Set("user_id", user.ID)
-		c.Set("role", user.Role)
+		c.Set("role", role)
+		c.Set("permissions", perms)
 		c.Next()
 	}
 }
-
-func RequireRole(roles ...models.Role) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		userRole := c.GetString("role")
-		for _, role := range roles {
-			if string(role) == userRole {
-				c.Next()
-				return
-			}
-		}
-		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
-		c.Abort()
-	}
-}
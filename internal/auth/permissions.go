@@ -0,0 +1,274 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"containereye/internal/database"
+	"containereye/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rolesRefresh is how long the role permissions are cached, so that
+// servers sharing a database pick up each other's changes
+const rolesRefresh = 30 * time.Second
+
+var ErrAdminRole = errors.New("the admin role always has every permission")
+
+// PermissionInfo describes a permission of the catalog
+type PermissionInfo struct {
+	Name        models.Permission `json:"name"`
+	Description string            `json:"description"`
+}
+
+// catalog lists every permission roles can be granted
+var catalog = []PermissionInfo{
+	{models.PermContainersRead, "View containers, their stats and events, and query stats"},
+	{models.PermAlertsRead, "View alerts, their deliveries and escalations"},
+	{models.PermAlertsWrite, "Raise alerts through the API"},
+	{models.PermAlertsAck, "Acknowledge and resolve alerts"},
+	{models.PermSilencesRead, "View silences"},
+	{models.PermSilencesWrite, "Create, change and expire silences"},
+	{models.PermHostsRead, "View monitored hosts"},
+	{models.PermHostsWrite, "Add, change and remove monitored hosts"},
+	{models.PermStatsIngest, "Push stats and container inventory as an agent"},
+	{models.PermRulesRead, "View alert rules"},
+	{models.PermRulesWrite, "Create, change, import, validate and test alert rules"},
+	{models.PermUsersAdmin, "Manage users, API keys and role permissions"},
+	{models.PermAuditRead, "View the audit log"},
+}
+
+// defaultPermissions are the permissions of the user and viewer roles until
+// they are changed, and of the agent scope of API keys
+var defaultPermissions = map[models.Role][]models.Permission{
+	models.RoleUser: {
+		models.PermContainersRead,
+		models.PermAlertsRead,
+		models.PermAlertsWrite,
+		models.PermAlertsAck,
+		models.PermSilencesRead,
+		models.PermSilencesWrite,
+		models.PermHostsRead,
+		models.PermRulesRead,
+	},
+	models.RoleViewer: {
+		models.PermContainersRead,
+		models.PermAlertsRead,
+		models.PermSilencesRead,
+		models.PermHostsRead,
+		models.PermRulesRead,
+	},
+	models.RoleAgent: {
+		models.PermStatsIngest,
+	},
+}
+
+// roleCache holds the stored permissions of the roles that were changed
+var roleCache struct {
+	sync.Mutex
+	stored   map[models.Role][]models.Permission
+	loadedAt time.Time
+}
+
+// PermissionSet is the set of permissions a request was granted
+type PermissionSet map[models.Permission]bool
+
+// Catalog returns every permission with its description
+func Catalog() []PermissionInfo {
+	return append([]PermissionInfo(nil), catalog...)
+}
+
+func knownPermission(p models.Permission) bool {
+	for _, info := range catalog {
+		if info.Name == p {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleInfo is a role with its current permissions, Custom when they were
+// changed from the defaults
+type RoleInfo struct {
+	Role        models.Role         `json:"role"`
+	Permissions []models.Permission `json:"permissions"`
+	Custom      bool                `json:"custom"`
+}
+
+// Roles returns the permissions of every role
+func Roles() ([]RoleInfo, error) {
+	stored, err := storedPermissions(true)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := []RoleInfo{{Role: models.RoleAdmin, Permissions: allPermissions()}}
+	for _, role := range []models.Role{models.RoleUser, models.RoleViewer} {
+		info := RoleInfo{Role: role, Permissions: defaultPermissions[role]}
+		if perms, ok := stored[role]; ok {
+			info.Permissions, info.Custom = perms, true
+		}
+		roles = append(roles, info)
+	}
+	return roles, nil
+}
+
+// SetRolePermissions replaces the permissions of the user or viewer role
+func SetRolePermissions(role models.Role, perms []models.Permission) (*RoleInfo, error) {
+	if err := checkEditableRole(role); err != nil {
+		return nil, err
+	}
+	set := make(map[models.Permission]bool, len(perms))
+	for _, p := range perms {
+		if !knownPermission(p) {
+			return nil, &ValidationError{fmt.Errorf("unknown permission %q", p)}
+		}
+		set[p] = true
+	}
+	perms = make([]models.Permission, 0, len(set))
+	for p := range set {
+		perms = append(perms, p)
+	}
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+
+	row := models.RolePermissions{Role: role, Permissions: perms}
+	if err := database.GetDB().Save(&row).Error; err != nil {
+		return nil, err
+	}
+	invalidateRoles()
+	return &RoleInfo{Role: role, Permissions: perms, Custom: true}, nil
+}
+
+// ResetRolePermissions restores the default permissions of a role
+func ResetRolePermissions(role models.Role) (*RoleInfo, error) {
+	if err := checkEditableRole(role); err != nil {
+		return nil, err
+	}
+	if err := database.GetDB().Delete(&models.RolePermissions{}, "role = ?", role).Error; err != nil {
+		return nil, err
+	}
+	invalidateRoles()
+	return &RoleInfo{Role: role, Permissions: defaultPermissions[role]}, nil
+}
+
+func checkEditableRole(role models.Role) error {
+	if role == models.RoleAdmin {
+		return ErrAdminRole
+	}
+	if !role.Valid() {
+		return &ValidationError{fmt.Errorf("unknown role %q", role)}
+	}
+	return nil
+}
+
+// PermissionsOf returns the permissions of a role
+func PermissionsOf(role models.Role) PermissionSet {
+	set := make(PermissionSet)
+	if role == models.RoleAdmin {
+		for _, p := range allPermissions() {
+			set[p] = true
+		}
+		return set
+	}
+
+	perms := defaultPermissions[role]
+	stored, err := storedPermissions(false)
+	if err != nil {
+		log.Printf("Warning: Failed to load role permissions, using the defaults: %v", err)
+	} else if custom, ok := stored[role]; ok {
+		perms = custom
+	}
+	for _, p := range perms {
+		set[p] = true
+	}
+	return set
+}
+
+// Intersect returns the permissions contained in both sets
+func (s PermissionSet) Intersect(other PermissionSet) PermissionSet {
+	set := make(PermissionSet)
+	for p := range s {
+		if other[p] {
+			set[p] = true
+		}
+	}
+	return set
+}
+
+// List returns the permissions in the set, sorted
+func (s PermissionSet) List() []models.Permission {
+	perms := make([]models.Permission, 0, len(s))
+	for p := range s {
+		perms = append(perms, p)
+	}
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms
+}
+
+// HasPermission reports whether the authenticated request was granted perm
+func HasPermission(c *gin.Context, perm models.Permission) bool {
+	perms, ok := c.Get("permissions")
+	return ok && perms.(PermissionSet)[perm]
+}
+
+// RequirePermission rejects requests that were not granted perm
+func RequirePermission(perm models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, perm) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("permission %s required", perm)})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func allPermissions() []models.Permission {
+	perms := make([]models.Permission, 0, len(catalog))
+	for _, info := range catalog {
+		perms = append(perms, info.Name)
+	}
+	return perms
+}
+
+// storedPermissions returns the changed role permissions, reloading them
+// when the cache is older than rolesRefresh or fresh is set
+func storedPermissions(fresh bool) (map[models.Role][]models.Permission, error) {
+	roleCache.Lock()
+	defer roleCache.Unlock()
+
+	if !fresh && roleCache.stored != nil && time.Since(roleCache.loadedAt) < rolesRefresh {
+		return roleCache.stored, nil
+	}
+
+	var rows []models.RolePermissions
+	if err := database.GetDB().Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	stored := make(map[models.Role][]models.Permission, len(rows))
+	for _, row := range rows {
+		// Permissions dropped from the catalog may still be stored
+		perms := make([]models.Permission, 0, len(row.Permissions))
+		for _, p := range row.Permissions {
+			if knownPermission(p) {
+				perms = append(perms, p)
+			}
+		}
+		stored[row.Role] = perms
+	}
+	roleCache.stored = stored
+	roleCache.loadedAt = time.Now()
+	return stored, nil
+}
+
+func invalidateRoles() {
+	roleCache.Lock()
+	roleCache.stored = nil
+	roleCache.Unlock()
+}
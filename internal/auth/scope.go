@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"containereye/internal/alert"
+	"containereye/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Scope restricts a user to the containers of some hosts and/or to the
+// containers matching a label selector
+type Scope struct {
+	hosts  []string
+	labels alert.LabelSelector
+}
+
+// NewScope returns the scope of a user, nil when the user may see every
+// container
+func NewScope(user *models.User) (*Scope, error) {
+	if len(user.Hosts) == 0 && strings.TrimSpace(user.LabelSelector) == "" {
+		return nil, nil
+	}
+	labels, err := alert.ParseLabelSelector(user.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	return &Scope{hosts: user.Hosts, labels: labels}, nil
+}
+
+// ScopeOf returns the scope of the authenticated request, nil when it is
+// not restricted
+func ScopeOf(c *gin.Context) *Scope {
+	if scope, ok := c.Get("scope"); ok {
+		return scope.(*Scope)
+	}
+	return nil
+}
+
+// AllowsHost reports whether the host matches one of the scope's host
+// patterns. Scopes restricted by labels only allow every host.
+func (s *Scope) AllowsHost(host string) bool {
+	if s == nil || len(s.hosts) == 0 {
+		return true
+	}
+	for _, pattern := range s.hosts {
+		if ok, _ := alert.MatchPattern(pattern, host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsContainer reports whether a container of host with the given
+// labels is within the scope
+func (s *Scope) AllowsContainer(host string, labels map[string]string) bool {
+	if s == nil {
+		return true
+	}
+	return s.AllowsHost(host) && s.labels.Matches(labels)
+}
+
+// validateScope checks the host patterns and label selector of a user
+func validateScope(hosts []string, labelSelector string) error {
+	for _, pattern := range hosts {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("host patterns must not be empty")
+		}
+		if _, err := alert.MatchPattern(pattern, ""); err != nil {
+			return fmt.Errorf("invalid host pattern %q: %v", pattern, err)
+		}
+	}
+	_, err := alert.ParseLabelSelector(labelSelector)
+	return err
+}
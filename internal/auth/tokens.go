@@ -0,0 +1,308 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"containereye/internal/database"
+	"containereye/internal/models"
+
+	"github.com/golang-jwt/jwt"
+	"gorm.io/gorm"
+)
+
+// RefreshTokenPrefix starts every refresh token
+const RefreshTokenPrefix = "cer_"
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, the session has been revoked")
+	ErrTokenRevoked        = errors.New("token has been revoked")
+)
+
+// jwtKeys, signingKeyID, tokenExpiry and refreshExpiry are set from the
+// config by Configure. Tokens are signed with the key signingKeyID and
+// checked with the key named by their kid header.
+var (
+	jwtKeys       map[string][]byte
+	signingKeyID  string
+	tokenExpiry   = 15 * time.Minute
+	refreshExpiry = 30 * 24 * time.Hour
+)
+
+// Claims are the claims of an access token. Id is the token's own ID and
+// SessionID the login session it was issued for; either can be revoked.
+type Claims struct {
+	UserID    uint
+	Role      models.Role
+	SessionID string `json:"sid,omitempty"`
+	jwt.StandardClaims
+}
+
+// TokenPair is the response to a login or refresh: a short-lived access
+// token and the refresh token that renews it
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	Token            string    `json:"token"` // The access token, as returned by earlier versions
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// IssueTokens starts a login session for user
+func IssueTokens(user *models.User) (*TokenPair, error) {
+	pruneTokens()
+
+	sessionID, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	return issueTokens(database.GetDB(), user, sessionID)
+}
+
+// RefreshTokens exchanges a refresh token for new tokens of the same
+// session. The refresh token cannot be used again: a second use revokes the
+// session and returns ErrRefreshTokenReused along with the session's user.
+func RefreshTokens(plain string) (*TokenPair, *models.User, error) {
+	db := database.GetDB()
+
+	var token models.RefreshToken
+	if err := db.Where("hash = ?", hashToken(plain)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		return nil, nil, err
+	}
+	now := time.Now()
+	if !now.Before(token.ExpiresAt) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	var user models.User
+	if err := db.First(&user, token.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		return nil, nil, ErrUserInactive
+	}
+
+	var pair *TokenPair
+	reused := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Only one refresh can claim the token
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			UpdateColumn("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return nil
+		}
+
+		var err error
+		pair, err = issueTokens(tx, &user, token.SessionID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if reused {
+		log.Printf("Warning: Refresh token of session %s of user %s was reused, revoking the session", token.SessionID, user.Username)
+		if err := revokeSessions(db, []string{token.SessionID}); err != nil {
+			return nil, nil, err
+		}
+		return nil, &user, ErrRefreshTokenReused
+	}
+	return pair, &user, nil
+}
+
+// RevokeToken revokes an access token and the session it was issued for
+func RevokeToken(claims *Claims) error {
+	db := database.GetDB()
+	if claims.SessionID != "" {
+		if err := revokeSessions(db, []string{claims.SessionID}); err != nil {
+			return err
+		}
+	}
+	if claims.Id == "" {
+		return nil
+	}
+	return db.Save(&models.RevokedToken{ID: claims.Id, ExpiresAt: time.Unix(claims.ExpiresAt, 0)}).Error
+}
+
+// RevokeUserSessions revokes every session of a user except keep, which may
+// be empty
+func RevokeUserSessions(userID uint, keep string) error {
+	db := database.GetDB()
+
+	var sessions []string
+	err := db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND session_id <> ? AND expires_at > ?", userID, keep, time.Now()).
+		Distinct().Pluck("session_id", &sessions).Error
+	if err != nil {
+		return err
+	}
+	return revokeSessions(db, sessions)
+}
+
+// revokeSessions deletes the refresh tokens of the sessions and lists them
+// as revoked until their last access tokens have expired
+func revokeSessions(db *gorm.DB, sessions []string) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id IN ?", sessions).Delete(&models.RefreshToken{}).Error; err != nil {
+			return err
+		}
+		expiresAt := time.Now().Add(tokenExpiry)
+		for _, id := range sessions {
+			if err := tx.Save(&models.RevokedToken{ID: id, ExpiresAt: expiresAt}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// isRevoked reports whether the token or its session was revoked
+func isRevoked(claims *Claims) (bool, error) {
+	var ids []string
+	for _, id := range []string{claims.Id, claims.SessionID} {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return false, nil
+	}
+
+	var count int64
+	if err := database.GetDB().Model(&models.RevokedToken{}).Where("id IN ?", ids).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func issueTokens(db *gorm.DB, user *models.User, sessionID string) (*TokenPair, error) {
+	access, expiresAt, err := signAccessToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %v", err)
+	}
+	plain := RefreshTokenPrefix + hex.EncodeToString(buf)
+	refresh := models.RefreshToken{
+		UserID:    user.ID,
+		SessionID: sessionID,
+		Hash:      hashToken(plain),
+		ExpiresAt: time.Now().Add(refreshExpiry),
+	}
+	if err := db.Create(&refresh).Error; err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      access,
+		Token:            access,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     plain,
+		RefreshExpiresAt: refresh.ExpiresAt,
+	}, nil
+}
+
+// signAccessToken returns an access token of a session and its expiry
+func signAccessToken(user *models.User, sessionID string) (string, time.Time, error) {
+	key, ok := jwtKeys[signingKeyID]
+	if !ok {
+		return "", time.Time{}, errNotConfigured
+	}
+	id, err := randomID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(tokenExpiry)
+	claims := Claims{
+		UserID:    user.ID,
+		Role:      user.Role,
+		SessionID: sessionID,
+		StandardClaims: jwt.StandardClaims{
+			Id:        id,
+			ExpiresAt: expiresAt.Unix(),
+			IssuedAt:  now.Unix(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = signingKeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, time.Unix(expiresAt.Unix(), 0), nil
+}
+
+// parseToken checks a JWT's signature and expiry and returns its claims.
+// Tokens without a kid header were issued by earlier versions and are
+// checked with the current key.
+func parseToken(token string) (*Claims, error) {
+	claims := &Claims{}
+	tkn, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		if len(jwtKeys) == 0 {
+			return nil, errNotConfigured
+		}
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			kid = signingKeyID
+		}
+		key, ok := jwtKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !tkn.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// pruneTokens removes the refresh tokens and revocations that expired
+func pruneTokens() {
+	db := database.GetDB()
+	now := time.Now()
+	if err := db.Unscoped().Where("expires_at <= ?", now).Delete(&models.RefreshToken{}).Error; err != nil {
+		log.Printf("Warning: Failed to remove expired refresh tokens: %v", err)
+	}
+	if err := db.Where("expires_at <= ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		log.Printf("Warning: Failed to remove expired token revocations: %v", err)
+	}
+}
+
+func randomID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,468 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"containereye/internal/database"
+	"containereye/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignores everything after 72 bytes
+	maxPasswordLength = 72
+)
+
+var (
+	ErrUserNotFound         = errors.New("user not found")
+	ErrUserExists           = errors.New("username or email is already taken")
+	ErrLastAdmin            = errors.New("the last active admin cannot be removed, deactivated or demoted")
+	ErrRegistrationDisabled = errors.New("registration is disabled")
+	ErrInvalidInvite        = errors.New("invalid invite token")
+	ErrInvalidCredentials   = errors.New("invalid credentials")
+	ErrUserInactive         = errors.New("user is inactive")
+)
+
+// ValidationError is returned for invalid user input
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// settings holds the account settings, set by Configure
+var settings = DefaultConfig()
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{2,31}$`)
+
+// commonPasswords are rejected regardless of their length and mix
+var commonPasswords = map[string]bool{
+	"password1": true, "password123": true, "passw0rd": true, "p@ssw0rd": true,
+	"qwerty123": true, "12345678a": true, "letmein1": true, "welcome1": true,
+	"admin123": true, "changeme1": true, "iloveyou1": true, "abc12345": true,
+}
+
+func validateUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return fmt.Errorf("username must be 3 to 32 letters, digits, dots, dashes or underscores, starting with a letter or digit")
+	}
+	return nil
+}
+
+func validateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return fmt.Errorf("invalid email address %q", email)
+	}
+	return nil
+}
+
+// ValidatePassword checks the strength of a user's new password
+func ValidatePassword(username, password string) error {
+	return checkPassword(username, password, settings.MinPasswordLength)
+}
+
+// checkPassword requires minLength characters mixing at least two of
+// lowercase letters, uppercase letters, digits and symbols, and rejects
+// common passwords and passwords containing the username
+func checkPassword(username, password string, minLength int) error {
+	if len([]rune(password)) < minLength {
+		return fmt.Errorf("password must be at least %d characters", minLength)
+	}
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("password must be at most %d bytes", maxPasswordLength)
+	}
+
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	if lower+upper+digit+symbol < 2 {
+		return fmt.Errorf("password must mix at least two of lowercase letters, uppercase letters, digits and symbols")
+	}
+
+	folded := strings.ToLower(password)
+	if username != "" && strings.Contains(folded, strings.ToLower(username)) {
+		return fmt.Errorf("password must not contain the username")
+	}
+	if commonPasswords[folded] {
+		return fmt.Errorf("password is too common")
+	}
+	return nil
+}
+
+// UserInput holds the fields of a user create or update request. Unset
+// fields are left unchanged on update.
+type UserInput struct {
+	Username      string       `json:"username"`
+	Email         *string      `json:"email"`
+	Password      string       `json:"password"`
+	Role          *models.Role `json:"role"`
+	IsActive      *bool        `json:"is_active"`
+	Hosts         *[]string    `json:"hosts"`          // Host patterns the user is restricted to, empty for all
+	LabelSelector *string      `json:"label_selector"` // Container label selector the user is restricted to
+}
+
+// ListUsers returns all users ordered by username
+func ListUsers() ([]models.User, error) {
+	var users []models.User
+	if err := database.GetDB().Order("username").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUser looks up a user by numeric ID or username
+func GetUser(ref string) (*models.User, error) {
+	return getUser(database.GetDB(), ref)
+}
+
+func getUser(db *gorm.DB, ref string) (*models.User, error) {
+	query := db.Where("username = ?", ref)
+	if id, err := strconv.ParseUint(ref, 10, 64); err == nil {
+		query = db.Where("id = ?", id)
+	}
+
+	var user models.User
+	if err := query.First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateUser creates an active user, a viewer unless a role is given
+func CreateUser(input UserInput) (*models.User, error) {
+	user := &models.User{
+		Username: input.Username,
+		Role:     models.RoleViewer,
+		IsActive: true,
+	}
+	if input.Role != nil {
+		user.Role = *input.Role
+	}
+	if err := applyInput(user, input); err != nil {
+		return nil, &ValidationError{err}
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := checkUnique(tx, user); err != nil {
+			return err
+		}
+		// The column defaults to active, which Create reads back, so a
+		// deactivated user is updated afterwards
+		active := user.IsActive
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if !active {
+			return tx.Model(user).Update("is_active", false).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdateUser changes a user's email, role, active flag, password and/or
+// scope.
+// The last active admin can neither be demoted nor deactivated.
+func UpdateUser(ref string, input UserInput) (*models.User, error) {
+	var user *models.User
+	var wasActive bool
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		if user, err = getUser(tx, ref); err != nil {
+			return err
+		}
+		if input.Username != "" && input.Username != user.Username {
+			return &ValidationError{fmt.Errorf("username cannot be changed")}
+		}
+
+		wasAdmin := user.Role == models.RoleAdmin && user.IsActive
+		wasActive = user.IsActive
+		if input.Role != nil {
+			user.Role = *input.Role
+		}
+		if err := applyInput(user, input); err != nil {
+			return &ValidationError{err}
+		}
+		if wasAdmin && (user.Role != models.RoleAdmin || !user.IsActive) {
+			if err := checkOtherAdmins(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		if err := checkUnique(tx, user); err != nil {
+			return err
+		}
+
+		return tx.Model(user).Select("Email", "Role", "IsActive", "Password", "Hosts", "LabelSelector").Updates(user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Deactivated users and those whose password was reset log in again
+	if wasActive && !user.IsActive || input.Password != "" {
+		if err := RevokeUserSessions(user.ID, ""); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// DeleteUser removes a user and its API keys for good, so that its name can
+// be reused, and revokes its sessions. The last active admin cannot be
+// deleted.
+func DeleteUser(ref string) error {
+	var user *models.User
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		if user, err = getUser(tx, ref); err != nil {
+			return err
+		}
+		if user.Role == models.RoleAdmin && user.IsActive {
+			if err := checkOtherAdmins(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.APIKey{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(user).Error
+	})
+	if err != nil {
+		return err
+	}
+	return RevokeUserSessions(user.ID, "")
+}
+
+// ChangePassword sets a user's own password after checking the current one
+// and revokes the user's sessions other than session, the one the password
+// was changed from
+func ChangePassword(user *models.User, current, password, session string) error {
+	if !user.CheckPassword(current) {
+		return ErrInvalidCredentials
+	}
+	if err := ValidatePassword(user.Username, password); err != nil {
+		return &ValidationError{err}
+	}
+	if err := user.SetPassword(password); err != nil {
+		return err
+	}
+	if err := database.GetDB().Model(user).Update("password", user.Password).Error; err != nil {
+		return err
+	}
+	return RevokeUserSessions(user.ID, session)
+}
+
+// Register creates a user of the configured registration role when
+// registration is enabled and the invite token, if one is required, matches
+func Register(input UserInput, invite string) (*models.User, error) {
+	if !settings.AllowRegistration {
+		return nil, ErrRegistrationDisabled
+	}
+	if settings.InviteToken != "" && subtle.ConstantTimeCompare([]byte(invite), []byte(settings.InviteToken)) != 1 {
+		return nil, ErrInvalidInvite
+	}
+
+	role := settings.RegistrationRole
+	active := true
+	input.Role = &role
+	input.IsActive = &active
+	return CreateUser(input)
+}
+
+// unknownUser has a password hash of the same cost as real users, checked
+// against for unknown usernames so that they are not rejected any faster
+// than wrong passwords
+var unknownUser = sync.OnceValue(func() models.User {
+	var user models.User
+	if err := user.SetPassword("unknown user"); err != nil {
+		log.Printf("Warning: Failed to hash the password of unknown users: %v", err)
+	}
+	return user
+})
+
+// Authenticate checks a username and password and records the login
+func Authenticate(username, password string) (*models.User, error) {
+	var user models.User
+	if err := database.GetDB().Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			dummy := unknownUser()
+			dummy.CheckPassword(password)
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	if !user.CheckPassword(password) {
+		return nil, ErrInvalidCredentials
+	}
+	if !user.IsActive {
+		return nil, ErrUserInactive
+	}
+
+	now := time.Now()
+	user.LastLoginAt = &now
+	if err := database.GetDB().Model(&user).Update("last_login_at", now).Error; err != nil {
+		log.Printf("Warning: Failed to record login of %s: %v", user.Username, err)
+	}
+	return &user, nil
+}
+
+// Bootstrap creates the first admin when the database has no users, with
+// the configured credentials or a generated password. The generated password
+// is printed once to stderr, never logged, and only when stderr is a
+// terminal; otherwise admin_password must be configured.
+func Bootstrap() error {
+	var count int64
+	if err := database.GetDB().Model(&models.User{}).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count users: %v", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	password := settings.AdminPassword
+	generated := password == ""
+	if generated {
+		if !isTerminal(os.Stderr) {
+			return fmt.Errorf("auth admin_password is required to create the first admin when stderr is not a terminal")
+		}
+		buf := make([]byte, 18)
+		if _, err := rand.Read(buf); err != nil {
+			return fmt.Errorf("failed to generate a password: %v", err)
+		}
+		password = base64.RawURLEncoding.EncodeToString(buf)
+	}
+
+	admin := models.RoleAdmin
+	if _, err := CreateUser(UserInput{Username: settings.AdminUsername, Password: password, Role: &admin}); err != nil {
+		return fmt.Errorf("failed to create admin user: %v", err)
+	}
+
+	log.Printf("Created admin user %q", settings.AdminUsername)
+	if generated {
+		fmt.Fprintf(os.Stderr, "Admin user %q has the password %s, change it after logging in\n", settings.AdminUsername, password)
+	}
+	return nil
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// applyInput validates and applies the email, active flag, password and
+// scope of input to user
+func applyInput(user *models.User, input UserInput) error {
+	if err := validateUsername(user.Username); err != nil {
+		return err
+	}
+	if !user.Role.Valid() {
+		return fmt.Errorf("unknown role %q", user.Role)
+	}
+	if input.Email != nil {
+		user.Email = nil
+		if email := strings.TrimSpace(*input.Email); email != "" {
+			if err := validateEmail(email); err != nil {
+				return err
+			}
+			user.Email = &email
+		}
+	}
+	if input.IsActive != nil {
+		user.IsActive = *input.IsActive
+	}
+	if input.Hosts != nil {
+		user.Hosts = *input.Hosts
+	}
+	if input.LabelSelector != nil {
+		user.LabelSelector = strings.TrimSpace(*input.LabelSelector)
+	}
+	if err := validateScope(user.Hosts, user.LabelSelector); err != nil {
+		return err
+	}
+	if input.Password != "" {
+		if err := ValidatePassword(user.Username, input.Password); err != nil {
+			return err
+		}
+		if err := user.SetPassword(input.Password); err != nil {
+			return fmt.Errorf("failed to hash password: %v", err)
+		}
+	}
+	if user.Password == "" {
+		return fmt.Errorf("password is required")
+	}
+	return nil
+}
+
+// checkUnique returns ErrUserExists when another user has the same username
+// or email
+func checkUnique(tx *gorm.DB, user *models.User) error {
+	query := tx.Unscoped().Model(&models.User{}).Where("id <> ?", user.ID)
+	if user.Email != nil {
+		query = query.Where("username = ? OR email = ?", user.Username, *user.Email)
+	} else {
+		query = query.Where("username = ?", user.Username)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUserExists
+	}
+	return nil
+}
+
+// checkOtherAdmins returns ErrLastAdmin unless another active admin exists
+func checkOtherAdmins(tx *gorm.DB, id uint) error {
+	var count int64
+	err := tx.Model(&models.User{}).
+		Where("role = ? AND is_active = ? AND id <> ?", models.RoleAdmin, true, id).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
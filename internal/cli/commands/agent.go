@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"containereye/internal/agent"
+	"github.com/spf13/cobra"
+)
+
+func NewAgentCommand() *cobra.Command {
+	config := agent.Config{}
+
+	cmd := &cobra.Command{
+		Use:   "agent",
+		Short: "Collect local container stats and push them to a server",
+		Long: `Run a push agent on a host the server cannot connect to. The agent
+collects the stats of the local Docker daemon, buffers them on disk while
+the server is unreachable and pushes them to the server's ingest endpoint.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if config.ServerURL == "" {
+				config.ServerURL = os.Getenv("CONTAINEREYE_API_URL")
+			}
+			if config.Token == "" {
+				config.Token = os.Getenv("CONTAINEREYE_TOKEN")
+			}
+			if config.Token == "" {
+				config.Token = os.Getenv("CONTAINEREYE_API_KEY")
+			}
+
+			a, err := agent.New(config)
+			if err != nil {
+				return fmt.Errorf("failed to start agent: %v", err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return a.Run(ctx)
+		},
+	}
+
+	cmd.Flags().StringVar(&config.ServerURL, "server", "", "Server URL (default $CONTAINEREYE_API_URL)")
+	cmd.Flags().StringVar(&config.Token, "token", "", "API key or token (default $CONTAINEREYE_TOKEN or $CONTAINEREYE_API_KEY)")
+	cmd.Flags().StringVar(&config.Host, "host", "", "Host name reported to the server (default the hostname)")
+	cmd.Flags().StringVar(&config.BufferDir, "buffer-dir", "/var/lib/containereye/agent", "Directory batches are buffered in while the server is unreachable")
+	cmd.Flags().DurationVar(&config.Interval, "interval", agent.DefaultInterval, "Collection interval")
+	cmd.Flags().IntVar(&config.MaxBuffered, "max-buffered", agent.DefaultMaxBuffered, "Maximum number of buffered batches")
+
+	return cmd
+}
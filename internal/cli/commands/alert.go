@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"containereye/internal/api/client"
+	"containereye/internal/models"
 	"github.com/spf13/cobra"
 )
 
@@ -21,14 +22,18 @@ func NewAlertCommand() *cobra.Command {
 	cmd.AddCommand(newAlertListCommand())
 	cmd.AddCommand(newAlertAcknowledgeCommand())
 	cmd.AddCommand(newAlertResolveCommand())
+	cmd.AddCommand(newAlertDeliveriesCommand())
 
 	return cmd
 }
 
 func newAlertListCommand() *cobra.Command {
 	var (
-		status string
-		level  string
+		opts   client.AlertListOptions
+		ruleID uint
+		from   string
+		to     string
+		all    bool
 	)
 
 	cmd := &cobra.Command{
@@ -41,32 +46,84 @@ func newAlertListCommand() *cobra.Command {
 				return fmt.Errorf("failed to create client: %v", err)
 			}
 
-			alerts, err := c.ListAlerts(status, level)
-			if err != nil {
-				return fmt.Errorf("failed to list alerts: %v", err)
+			opts.RuleID = ruleID
+			if from != "" {
+				t, err := time.Parse(time.RFC3339, from)
+				if err != nil {
+					return fmt.Errorf("invalid from time: %v", err)
+				}
+				opts.From = &t
+			}
+			if to != "" {
+				t, err := time.Parse(time.RFC3339, to)
+				if err != nil {
+					return fmt.Errorf("invalid to time: %v", err)
+				}
+				opts.To = &t
 			}
 
 			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-			fmt.Fprintln(w, "ID\tCONTAINER\tLEVEL\tMETRIC\tVALUE\tSTATUS\tTIME")
-			
-			for _, alert := range alerts {
-				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%.2f\t%s\t%s\n",
-					alert.ID,
-					alert.ContainerName,
-					alert.Level,
-					alert.Metric,
-					alert.Value,
-					alert.Status,
-					alert.StartTime.Format(time.RFC3339),
-				)
+			fmt.Fprintln(w, "ID\tHOST\tCONTAINER\tLEVEL\tMETRIC\tVALUE\tSTATUS\tTIME")
+
+			var shown int
+			var page *client.AlertPage
+			for {
+				page, err = c.ListAlerts(opts)
+				if err != nil {
+					return fmt.Errorf("failed to list alerts: %v", err)
+				}
+
+				for _, alert := range page.Alerts {
+					status := string(alert.Status)
+					if alert.SilenceID != nil {
+						status = fmt.Sprintf("%s (silenced by %d)", status, *alert.SilenceID)
+					}
+					fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%.2f\t%s\t%s\n",
+						alert.ID,
+						alert.HostID,
+						alert.ContainerName,
+						alert.Level,
+						alert.Metric,
+						alert.Value,
+						status,
+						alert.StartTime.Format(time.RFC3339),
+					)
+				}
+				shown += len(page.Alerts)
+
+				// Keep fetching pages only when asked to list everything
+				if !all || page.NextCursor == "" {
+					break
+				}
+				opts.Cursor = page.NextCursor
 			}
-			
-			return w.Flush()
+
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "\nShowing %d of %d alerts\n", shown, page.Total)
+			if !all && page.NextCursor != "" {
+				fmt.Fprintf(os.Stderr, "More results available, use --cursor %s or --all\n", page.NextCursor)
+			}
+			return nil
 		},
 	}
 
-	cmd.Flags().StringVar(&status, "status", "", "Filter by alert status (pending/active/acknowledged/resolved)")
-	cmd.Flags().StringVar(&level, "level", "", "Filter by alert level (info/warning/critical)")
+	cmd.Flags().StringVar(&opts.Status, "status", "", "Filter by alert status (pending/active/acknowledged/resolved)")
+	cmd.Flags().StringVar(&opts.Level, "level", "", "Filter by alert level (info/warning/critical)")
+	cmd.Flags().UintVar(&ruleID, "rule", 0, "Filter by rule ID")
+	cmd.Flags().StringVar(&opts.Host, "host", "", "Filter by host")
+	cmd.Flags().StringVar(&opts.ContainerID, "container-id", "", "Filter by container ID (prefix match)")
+	cmd.Flags().StringVar(&opts.ContainerName, "container", "", "Filter by container name")
+	cmd.Flags().StringVarP(&opts.Search, "search", "q", "", "Search alert messages")
+	cmd.Flags().UintVar(&opts.SilenceID, "silence", 0, "Only alerts suppressed by this silence")
+	cmd.Flags().StringVar(&from, "from", "", "Only alerts started at or after this time (RFC3339 format)")
+	cmd.Flags().StringVar(&to, "to", "", "Only alerts started at or before this time (RFC3339 format)")
+	cmd.Flags().StringVar(&opts.Sort, "sort", "desc", "Sort order by alert ID (asc/desc)")
+	cmd.Flags().IntVar(&opts.Limit, "limit", 50, "Number of alerts per page")
+	cmd.Flags().StringVar(&opts.Cursor, "cursor", "", "Resume listing from a cursor returned by a previous page")
+	cmd.Flags().BoolVar(&all, "all", false, "Fetch all pages")
 
 	return cmd
 }
@@ -123,3 +180,54 @@ func newAlertResolveCommand() *cobra.Command {
 	cmd.Flags().StringVar(&comment, "comment", "", "Add a comment to the resolution")
 	return cmd
 }
+
+func newAlertDeliveriesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "deliveries [alert_id]",
+		Short: "Show notification deliveries of an alert",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			deliveries, err := c.ListAlertDeliveries(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to get alert deliveries: %v", err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "CHANNEL\tEVENT\tATTEMPT\tRESULT\tTIME\tDURATION\tERROR")
+
+			for _, d := range deliveries {
+				if len(d.Attempts) == 0 {
+					fmt.Fprintf(w, "%s\t%s\t-\t%s\t%s\t-\t\n",
+						d.Channel, d.Event, d.Status, d.NextAttemptAt.Format(time.RFC3339))
+					continue
+				}
+				for _, a := range d.Attempts {
+					result := "FAILED"
+					if a.Success {
+						result = "SENT"
+					}
+					fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+						d.Channel, d.Event, a.Attempt, result,
+						a.CreatedAt.Format(time.RFC3339), a.Duration.Round(time.Millisecond), a.Error)
+				}
+				if d.Status == models.DeliveryStatusPending {
+					fmt.Fprintf(w, "%s\t%s\t-\tRETRY\t%s\t-\t\n",
+						d.Channel, d.Event, d.NextAttemptAt.Format(time.RFC3339))
+				}
+				if d.Status == models.DeliveryStatusDead {
+					fmt.Fprintf(w, "%s\t%s\t-\tDEAD\t\t-\tgave up after %d attempts\n",
+						d.Channel, d.Event, d.AttemptCount)
+				}
+			}
+
+			return w.Flush()
+		},
+	}
+
+	return cmd
+}
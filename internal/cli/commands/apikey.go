@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"containereye/internal/api/client"
+	"containereye/internal/models"
+	"github.com/spf13/cobra"
+)
+
+func NewAPIKeyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "apikey",
+		Short:   "API key commands",
+		Aliases: []string{"apikeys", "keys"},
+	}
+
+	// Add subcommands
+	cmd.AddCommand(newAPIKeyListCommand())
+	cmd.AddCommand(newAPIKeyCreateCommand())
+	cmd.AddCommand(newAPIKeyRevokeCommand())
+
+	return cmd
+}
+
+func newAPIKeyListCommand() *cobra.Command {
+	var (
+		all  bool
+		user string
+	)
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List your API keys",
+		Aliases: []string{"ls"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			var keys []models.APIKey
+			if all || user != "" {
+				keys, err = c.ListAllAPIKeys(user)
+			} else {
+				keys, err = c.ListAPIKeys()
+			}
+			if err != nil {
+				return fmt.Errorf("failed to list api keys: %v", err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tUSER\tPREFIX\tSCOPE\tEXPIRES\tLAST USED")
+			for _, key := range keys {
+				expires := "never"
+				if key.ExpiresAt != nil {
+					expires = key.ExpiresAt.Format(time.RFC3339)
+					if key.Expired(time.Now()) {
+						expires += " (expired)"
+					}
+				}
+				lastUsed := "-"
+				if key.LastUsedAt != nil {
+					lastUsed = key.LastUsedAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s...\t%s\t%s\t%s\n",
+					key.ID,
+					key.Name,
+					key.Username,
+					key.Prefix,
+					key.Scope,
+					expires,
+					lastUsed,
+				)
+			}
+
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "List the keys of all users (admin only)")
+	cmd.Flags().StringVar(&user, "user", "", "List the keys of a user (admin only)")
+
+	return cmd
+}
+
+func newAPIKeyCreateCommand() *cobra.Command {
+	var (
+		scope   string
+		expires time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "create [name]",
+		Short: "Create an API key, which is shown only once",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := &client.APIKeyRequest{Name: args[0]}
+			if scope != "" {
+				role := models.Role(strings.ToLower(scope))
+				if !role.ValidScope() {
+					return fmt.Errorf("invalid scope %q, expected admin, user, viewer or agent", scope)
+				}
+				req.Scope = role
+			}
+			if expires > 0 {
+				req.ExpiresIn = expires.String()
+			}
+
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			created, err := c.CreateAPIKey(req)
+			if err != nil {
+				return fmt.Errorf("failed to create api key: %v", err)
+			}
+
+			fmt.Printf("API key %s created with ID %d and scope %s\n", created.Name, created.ID, created.Scope)
+			fmt.Println("Store it now, it cannot be shown again:")
+			fmt.Println(created.Key)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&scope, "scope", "", "Role the key is limited to (admin/user/viewer), or agent for push agents (default your role)")
+	cmd.Flags().DurationVar(&expires, "expires", 0, "Lifetime of the key, e.g. 720h (default never expires)")
+
+	return cmd
+}
+
+func newAPIKeyRevokeCommand() *cobra.Command {
+	var anyUser bool
+
+	cmd := &cobra.Command{
+		Use:     "revoke [id]",
+		Short:   "Revoke an API key",
+		Aliases: []string{"rm", "delete"},
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			if err := c.RevokeAPIKey(args[0], anyUser); err != nil {
+				return fmt.Errorf("failed to revoke api key: %v", err)
+			}
+
+			fmt.Printf("API key %s revoked\n", args[0])
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&anyUser, "any-user", false, "Revoke a key of another user (admin only)")
+
+	return cmd
+}
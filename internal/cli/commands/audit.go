@@ -0,0 +1,177 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"containereye/internal/api/client"
+	"containereye/internal/models"
+	"github.com/spf13/cobra"
+)
+
+func NewAuditCommand() *cobra.Command {
+	var (
+		opts    client.AuditListOptions
+		from    string
+		to      string
+		all     bool
+		changes bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Show who changed what through the API",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			if from != "" {
+				t, err := time.Parse(time.RFC3339, from)
+				if err != nil {
+					return fmt.Errorf("invalid from time: %v", err)
+				}
+				opts.From = &t
+			}
+			if to != "" {
+				t, err := time.Parse(time.RFC3339, to)
+				if err != nil {
+					return fmt.Errorf("invalid to time: %v", err)
+				}
+				opts.To = &t
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "ID\tTIME\tUSER\tACTION\tTARGET\tSOURCE IP\tDETAIL")
+
+			var shown int
+			var page *client.AuditPage
+			for {
+				page, err = c.ListAudit(opts)
+				if err != nil {
+					return fmt.Errorf("failed to list audit log: %v", err)
+				}
+
+				for _, entry := range page.Entries {
+					user := entry.Username
+					if entry.APIKeyName != "" {
+						user = fmt.Sprintf("%s (key %s)", user, entry.APIKeyName)
+					}
+					target := entry.TargetType
+					if entry.TargetID != "" {
+						target += " " + entry.TargetID
+					}
+					fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+						entry.ID,
+						entry.CreatedAt.Format(time.RFC3339),
+						user,
+						entry.Action,
+						target,
+						entry.SourceIP,
+						auditDetail(&entry),
+					)
+					if changes {
+						for _, line := range auditChanges(entry.Changes) {
+							fmt.Fprintf(w, "\t\t\t\t\t\t  %s\n", line)
+						}
+					}
+				}
+				shown += len(page.Entries)
+
+				// Keep fetching pages only when asked to list everything
+				if !all || page.NextCursor == "" {
+					break
+				}
+				opts.Cursor = page.NextCursor
+			}
+
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "\nShowing %d of %d entries\n", shown, page.Total)
+			if !all && page.NextCursor != "" {
+				fmt.Fprintf(os.Stderr, "More results available, use --cursor %s or --all\n", page.NextCursor)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.User, "user", "", "Filter by username")
+	cmd.Flags().StringVar(&opts.Action, "action", "", "Filter by action, e.g. rule.disable, or by target with e.g. rule")
+	cmd.Flags().StringVar(&opts.TargetType, "target-type", "", "Filter by target type (rule/alert/user/apikey/role/silence/host)")
+	cmd.Flags().StringVar(&opts.TargetID, "target", "", "Filter by target ID or name")
+	cmd.Flags().UintVar(&opts.APIKeyID, "api-key", 0, "Filter by API key ID")
+	cmd.Flags().StringVar(&opts.SourceIP, "ip", "", "Filter by source IP")
+	cmd.Flags().StringVar(&from, "from", "", "Only entries at or after this time (RFC3339 format)")
+	cmd.Flags().StringVar(&to, "to", "", "Only entries at or before this time (RFC3339 format)")
+	cmd.Flags().StringVar(&opts.Sort, "sort", "desc", "Sort order by entry ID (asc/desc)")
+	cmd.Flags().IntVar(&opts.Limit, "limit", 50, "Number of entries per page")
+	cmd.Flags().StringVar(&opts.Cursor, "cursor", "", "Resume listing from a cursor returned by a previous page")
+	cmd.Flags().BoolVar(&all, "all", false, "Fetch all pages")
+	cmd.Flags().BoolVar(&changes, "changes", false, "Show the before and after value of every changed field")
+
+	return cmd
+}
+
+// auditDetail summarizes an entry as its detail or the changed fields
+func auditDetail(entry *models.AuditEntry) string {
+	if entry.Detail != "" {
+		return entry.Detail
+	}
+	if len(entry.Changes) == 0 {
+		return "-"
+	}
+	created, deleted := true, true
+	names := make([]string, 0, len(entry.Changes))
+	for name, change := range entry.Changes {
+		names = append(names, name)
+		created = created && change.Before == nil
+		deleted = deleted && change.After == nil
+	}
+	switch {
+	case created:
+		return "created"
+	case deleted:
+		return "deleted"
+	}
+	sort.Strings(names)
+	return "changed " + strings.Join(names, ",")
+}
+
+// auditChanges formats the changes of an entry as "field: before -> after"
+func auditChanges(changes map[string]models.AuditChange) []string {
+	names := make([]string, 0, len(changes))
+	for name := range changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		change := changes[name]
+		lines = append(lines, fmt.Sprintf("%s: %s -> %s", name, auditValue(change.Before), auditValue(change.After)))
+	}
+	return lines
+}
+
+func auditValue(v interface{}) string {
+	if v == nil {
+		return "-"
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -3,10 +3,12 @@ package commands
 import (
 	"fmt"
 	"os"
+	"strings"
 	"text/tabwriter"
 	"time"
 
 	"containereye/internal/api/client"
+	"containereye/internal/models"
 	"github.com/spf13/cobra"
 )
 
@@ -19,12 +21,15 @@ func NewContainerCommand() *cobra.Command {
 
 	// Add subcommands
 	cmd.AddCommand(newContainerListCommand())
+	cmd.AddCommand(newContainerShowCommand())
 	cmd.AddCommand(newContainerStatsCommand())
 
 	return cmd
 }
 
 func newContainerListCommand() *cobra.Command {
+	var opts client.ContainerListOptions
+
 	cmd := &cobra.Command{
 		Use:     "list",
 		Short:   "List all containers",
@@ -35,21 +40,29 @@ func newContainerListCommand() *cobra.Command {
 				return fmt.Errorf("failed to create client: %v", err)
 			}
 
-			containers, err := c.ListContainers()
+			containers, err := c.ListContainers(opts)
 			if err != nil {
 				return fmt.Errorf("failed to list containers: %v", err)
 			}
 
 			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-			fmt.Fprintln(w, "ID\tNAME\tIMAGE\tSTATUS\tCREATED")
+			fmt.Fprintln(w, "HOST\tID\tNAME\tIMAGE\tSTATE\tUPTIME\tRESTARTS\tCPU %\tMEM %")
 			
 			for _, container := range containers {
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
-					container.ContainerID[:12],
-					container.Name,
+				state := container.State
+				if container.Gone {
+					state = "gone"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%.2f\t%.2f\n",
+					container.HostID,
+					shortID(container.ContainerID),
+					strings.TrimPrefix(container.Name, "/"),
 					container.Image,
-					container.Status,
-					container.Created.Format(time.RFC3339),
+					state,
+					uptime(container),
+					container.RestartCount,
+					container.CPUPercent,
+					container.MemPercent,
 				)
 			}
 			
@@ -57,6 +70,79 @@ func newContainerListCommand() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().StringVar(&opts.Host, "host", "", "Filter by host")
+	cmd.Flags().StringVar(&opts.State, "state", "", "Filter by state (running/exited/paused/...)")
+	cmd.Flags().StringVar(&opts.Image, "image", "", "Filter by image (prefix match)")
+	cmd.Flags().StringVar(&opts.Name, "name", "", "Filter by name (substring match)")
+	cmd.Flags().BoolVar(&opts.IncludeGone, "all", false, "Include containers that were removed")
+
+	return cmd
+}
+
+// uptime returns how long a running container has been up, or "-"
+func uptime(container models.Container) string {
+	if container.Gone || container.State != "running" || container.StartedAt.IsZero() {
+		return "-"
+	}
+	return time.Since(container.StartedAt).Truncate(time.Second).String()
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func newContainerShowCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show [container_id]",
+		Short: "Show container details and health check results",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			container, err := c.GetContainer(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to get container: %v", err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintf(w, "Host:\t%s\n", container.HostID)
+			fmt.Fprintf(w, "ID:\t%s\n", container.ContainerID)
+			fmt.Fprintf(w, "Name:\t%s\n", strings.TrimPrefix(container.Name, "/"))
+			fmt.Fprintf(w, "Image:\t%s\n", container.Image)
+			fmt.Fprintf(w, "State:\t%s\n", container.State)
+			fmt.Fprintf(w, "Uptime:\t%s\n", uptime(*container))
+			fmt.Fprintf(w, "Restarts:\t%d\n", container.RestartCount)
+			fmt.Fprintf(w, "Exit Code:\t%d\n", container.ExitCode)
+			if container.Health != "" {
+				fmt.Fprintf(w, "Health:\t%s (%d failing probes)\n", container.Health, container.HealthFailingStreak)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			if len(container.HealthLog) > 0 {
+				fmt.Println("\nRecent health probes:")
+				w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+				fmt.Fprintln(w, "TIME\tEXIT CODE\tOUTPUT")
+				for _, probe := range container.HealthLog {
+					fmt.Fprintf(w, "%s\t%d\t%s\n",
+						probe.Start.Format(time.RFC3339),
+						probe.ExitCode,
+						strings.TrimSpace(probe.Output),
+					)
+				}
+				return w.Flush()
+			}
+			return nil
+		},
+	}
+
 	return cmd
 }
 
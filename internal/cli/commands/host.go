@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"containereye/internal/api/client"
+	"containereye/internal/models"
+	"github.com/spf13/cobra"
+)
+
+func NewHostCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "host",
+		Short:   "Monitored Docker host commands",
+		Aliases: []string{"hosts"},
+	}
+
+	// Add subcommands
+	cmd.AddCommand(newHostListCommand())
+	cmd.AddCommand(newHostAddCommand())
+	cmd.AddCommand(newHostRemoveCommand())
+
+	return cmd
+}
+
+func newHostListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List monitored hosts",
+		Aliases: []string{"ls"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			hosts, err := c.ListHosts()
+			if err != nil {
+				return fmt.Errorf("failed to list hosts: %v", err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "NAME\tENDPOINT\tENABLED\tSTATUS\tLAST SEEN\tERROR")
+			for _, host := range hosts {
+				endpoint := host.Endpoint
+				if endpoint == "" {
+					endpoint = "(environment)"
+				}
+				lastSeen := "-"
+				if host.LastSeen != nil {
+					lastSeen = host.LastSeen.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%v\t%s\t%s\t%s\n",
+					host.Name,
+					endpoint,
+					host.Enabled,
+					host.Status,
+					lastSeen,
+					host.LastError,
+				)
+			}
+
+			return w.Flush()
+		},
+	}
+
+	return cmd
+}
+
+func newHostAddCommand() *cobra.Command {
+	var host models.Host
+
+	cmd := &cobra.Command{
+		Use:   "add [name] [endpoint]",
+		Short: "Monitor a Docker host (unix://, tcp:// or ssh:// endpoint), or with --push a host running an agent",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if host.Push != (len(args) == 1) {
+				return fmt.Errorf("give an endpoint, or --push for a host running an agent")
+			}
+
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			host.Name = args[0]
+			if len(args) > 1 {
+				host.Endpoint = args[1]
+			}
+			host.Enabled = true
+			created, err := c.CreateHost(&host)
+			if err != nil {
+				return fmt.Errorf("failed to add host: %v", err)
+			}
+
+			fmt.Printf("Host %s added\n", created.Name)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&host.TLSCACert, "tls-ca-cert", "", "CA certificate verifying the daemon (path on the server)")
+	cmd.Flags().StringVar(&host.TLSCert, "tls-cert", "", "Client certificate (path on the server)")
+	cmd.Flags().StringVar(&host.TLSKey, "tls-key", "", "Client key (path on the server)")
+	cmd.Flags().BoolVar(&host.Push, "push", false, "The host pushes its stats with \"containereye agent\"")
+
+	return cmd
+}
+
+func newHostRemoveCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "remove [name]",
+		Short:   "Stop monitoring a host",
+		Aliases: []string{"rm", "delete"},
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			if err := c.DeleteHost(args[0]); err != nil {
+				return fmt.Errorf("failed to remove host: %v", err)
+			}
+
+			fmt.Printf("Host %s removed\n", args[0])
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"containereye/internal/api/client"
+	"github.com/spf13/cobra"
+)
+
+func NewLoginCommand() *cobra.Command {
+	var (
+		username string
+		password string
+		stdin    bool
+		url      string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Log in with a username and password and save the session for later commands",
+		Long: `Log in with a username and password. The session is saved in the user's
+config directory and its access token renewed automatically until the
+refresh token expires or the session is logged out.
+CONTAINEREYE_API_KEY takes precedence over the saved session when set.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			in := bufio.NewReader(os.Stdin)
+			if username == "" {
+				fmt.Fprint(os.Stderr, "Username: ")
+				line, err := in.ReadString('\n')
+				if err != nil && line == "" {
+					return fmt.Errorf("failed to read username: %v", err)
+				}
+				username = strings.TrimSpace(line)
+			}
+			if password == "" {
+				if !stdin {
+					fmt.Fprint(os.Stderr, "Password: ")
+				}
+				line, err := in.ReadString('\n')
+				if err != nil && line == "" {
+					return fmt.Errorf("failed to read password: %v", err)
+				}
+				password = strings.TrimRight(line, "\r\n")
+			}
+
+			session, err := client.Login(url, username, password)
+			if err != nil {
+				return fmt.Errorf("failed to log in: %v", err)
+			}
+
+			fmt.Printf("Logged in to %s as %s\n", session.URL, session.Username)
+			fmt.Printf("Session valid until %s\n", session.RefreshExpiresAt.Format(time.RFC3339))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&username, "username", "u", "", "Username (prompted when not given)")
+	cmd.Flags().StringVar(&password, "password", "", "Password (prompted when not given)")
+	cmd.Flags().BoolVar(&stdin, "password-stdin", false, "Read the password from stdin")
+	cmd.Flags().StringVar(&url, "url", "", "Server URL (default CONTAINEREYE_API_URL or http://localhost:8080)")
+	cmd.MarkFlagsMutuallyExclusive("password", "password-stdin")
+
+	return cmd
+}
+
+func NewLogoutCommand() *cobra.Command {
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "logout",
+		Short: "Revoke the saved session and remove it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, err := client.LoadSession()
+			if err != nil {
+				return err
+			}
+			if session == nil {
+				fmt.Println("Not logged in")
+				return nil
+			}
+
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+			if c.Session() == nil {
+				return fmt.Errorf("CONTAINEREYE_API_KEY is set, unset it to log out of the saved session")
+			}
+
+			if err := c.Logout(all); err != nil {
+				// The session is forgotten even when the server cannot be reached
+				if rmErr := client.RemoveSession(); rmErr != nil {
+					return rmErr
+				}
+				return fmt.Errorf("removed the saved session but failed to revoke it: %v", err)
+			}
+
+			if all {
+				fmt.Printf("Logged out %s from all sessions\n", session.Username)
+			} else {
+				fmt.Printf("Logged out %s\n", session.Username)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "Revoke every session of the user, on all machines")
+
+	return cmd
+}
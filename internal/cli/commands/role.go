@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"containereye/internal/api/client"
+	"containereye/internal/models"
+	"github.com/spf13/cobra"
+)
+
+func NewRoleCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "roles",
+		Short:   "Role permission commands (admin only)",
+		Aliases: []string{"role"},
+	}
+
+	// Add subcommands
+	cmd.AddCommand(newRoleListCommand())
+	cmd.AddCommand(newRolePermissionsCommand())
+	cmd.AddCommand(newRoleSetCommand())
+	cmd.AddCommand(newRoleResetCommand())
+
+	return cmd
+}
+
+func newRoleListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List roles with their permissions",
+		Aliases: []string{"ls"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			roles, err := c.ListRoles()
+			if err != nil {
+				return fmt.Errorf("failed to list roles: %v", err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "ROLE\tCUSTOM\tPERMISSIONS")
+			for _, role := range roles {
+				fmt.Fprintf(w, "%s\t%v\t%s\n", role.Role, role.Custom, joinPermissions(role.Permissions))
+			}
+
+			return w.Flush()
+		},
+	}
+
+	return cmd
+}
+
+func newRolePermissionsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "permissions",
+		Short: "List the permissions roles can be granted",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			perms, err := c.ListPermissions()
+			if err != nil {
+				return fmt.Errorf("failed to list permissions: %v", err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "PERMISSION\tDESCRIPTION")
+			for _, perm := range perms {
+				fmt.Fprintf(w, "%s\t%s\n", perm.Name, perm.Description)
+			}
+
+			return w.Flush()
+		},
+	}
+
+	return cmd
+}
+
+func newRoleSetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set [role] [permission...]",
+		Short: "Replace the permissions of the user or viewer role",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			role, err := parseRole(args[0])
+			if err != nil {
+				return err
+			}
+			perms := []models.Permission{}
+			for _, arg := range args[1:] {
+				for _, p := range strings.Split(arg, ",") {
+					if p = strings.TrimSpace(p); p != "" {
+						perms = append(perms, models.Permission(p))
+					}
+				}
+			}
+
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			updated, err := c.SetRolePermissions(*role, perms)
+			if err != nil {
+				return fmt.Errorf("failed to update role: %v", err)
+			}
+
+			fmt.Printf("Role %s now has: %s\n", updated.Role, joinPermissions(updated.Permissions))
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func newRoleResetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reset [role]",
+		Short: "Restore the default permissions of a role",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			role, err := parseRole(args[0])
+			if err != nil {
+				return err
+			}
+
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			if err := c.ResetRolePermissions(*role); err != nil {
+				return fmt.Errorf("failed to reset role: %v", err)
+			}
+
+			fmt.Printf("Role %s reset to its default permissions\n", *role)
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// NewWhoamiCommand shows the authenticated user and what it may do
+func NewWhoamiCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "whoami",
+		Short: "Show the authenticated user with its permissions and scope",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			me, err := c.GetCurrentUser()
+			if err != nil {
+				return fmt.Errorf("failed to get current user: %v", err)
+			}
+
+			fmt.Printf("User:        %s\n", me.User.Username)
+			fmt.Printf("Role:        %s\n", me.User.Role)
+			if me.APIKey != nil {
+				fmt.Printf("API key:     %s (scope %s)\n", me.APIKey.Name, me.APIKey.Scope)
+			}
+			fmt.Printf("Scope:       %s\n", userScope(&me.User))
+			fmt.Printf("Permissions: %s\n", joinPermissions(me.Permissions))
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func joinPermissions(perms []models.Permission) string {
+	if len(perms) == 0 {
+		return "-"
+	}
+	names := make([]string, len(perms))
+	for i, p := range perms {
+		names[i] = string(p)
+	}
+	return strings.Join(names, ",")
+}
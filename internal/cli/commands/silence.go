@@ -0,0 +1,373 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"containereye/internal/api/client"
+	"containereye/internal/models"
+	"github.com/spf13/cobra"
+)
+
+func NewSilenceCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "silence",
+		Short:   "Silence and maintenance window commands",
+		Aliases: []string{"silences", "s"},
+	}
+
+	// Add subcommands
+	cmd.AddCommand(newSilenceListCommand())
+	cmd.AddCommand(newSilenceShowCommand())
+	cmd.AddCommand(newSilenceCreateCommand())
+	cmd.AddCommand(newSilenceUpdateCommand())
+	cmd.AddCommand(newSilenceExpireCommand())
+
+	return cmd
+}
+
+// silenceFlags holds the matcher and timing flags shared by create and update
+type silenceFlags struct {
+	containerName string
+	containerID   string
+	image         string
+	labels        []string
+	rule          string
+	level         string
+	start         string
+	end           string
+	duration      time.Duration
+	schedule      string
+	window        time.Duration
+	timezone      string
+	comment       string
+}
+
+func (f *silenceFlags) bind(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.containerName, "container", "", "Container name pattern (glob)")
+	cmd.Flags().StringVar(&f.containerID, "container-id", "", "Container ID prefix or pattern")
+	cmd.Flags().StringVar(&f.image, "image", "", "Image pattern (glob)")
+	cmd.Flags().StringSliceVar(&f.labels, "label", nil, "Label matcher as key=pattern (repeatable)")
+	cmd.Flags().StringVar(&f.rule, "rule", "", "Rule name pattern (glob)")
+	cmd.Flags().StringVar(&f.level, "level", "", "Alert level (info/warning/critical)")
+	cmd.Flags().StringVar(&f.start, "start", "", "Start time (RFC3339 format, default now)")
+	cmd.Flags().StringVar(&f.end, "end", "", "End time (RFC3339 format)")
+	cmd.Flags().DurationVar(&f.duration, "duration", 0, "Silence for this long from the start, instead of --end")
+	cmd.Flags().StringVar(&f.schedule, "schedule", "", "Cron expression opening a recurring maintenance window")
+	cmd.Flags().DurationVar(&f.window, "window", 0, "Length of each maintenance window")
+	cmd.Flags().StringVar(&f.timezone, "timezone", "", "Time zone the schedule is evaluated in")
+	cmd.Flags().StringVar(&f.comment, "comment", "", "Reason for the silence")
+}
+
+// apply copies the flags that were set on the command line to the silence
+func (f *silenceFlags) apply(cmd *cobra.Command, silence *models.Silence) error {
+	changed := cmd.Flags().Changed
+
+	if changed("container") {
+		silence.ContainerName = f.containerName
+	}
+	if changed("container-id") {
+		silence.ContainerID = f.containerID
+	}
+	if changed("image") {
+		silence.Image = f.image
+	}
+	if changed("label") {
+		silence.Labels = make(map[string]string)
+		for _, l := range f.labels {
+			parts := strings.SplitN(l, "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return fmt.Errorf("invalid label matcher %q, expected key=pattern", l)
+			}
+			silence.Labels[parts[0]] = parts[1]
+		}
+	}
+	if changed("rule") {
+		silence.RuleName = f.rule
+	}
+	if changed("level") {
+		silence.Level = models.AlertLevel(strings.ToUpper(f.level))
+	}
+	if changed("start") {
+		t, err := time.Parse(time.RFC3339, f.start)
+		if err != nil {
+			return fmt.Errorf("invalid start time: %v", err)
+		}
+		silence.StartsAt = t
+	}
+	if changed("end") && changed("duration") {
+		return fmt.Errorf("--end and --duration cannot be used together")
+	}
+	if changed("end") {
+		if f.end == "" {
+			silence.EndsAt = nil
+		} else {
+			t, err := time.Parse(time.RFC3339, f.end)
+			if err != nil {
+				return fmt.Errorf("invalid end time: %v", err)
+			}
+			silence.EndsAt = &t
+		}
+	}
+	if changed("duration") {
+		if silence.StartsAt.IsZero() {
+			silence.StartsAt = time.Now()
+		}
+		end := silence.StartsAt.Add(f.duration)
+		silence.EndsAt = &end
+	}
+	if changed("schedule") {
+		silence.Schedule = f.schedule
+	}
+	if changed("window") {
+		silence.Duration = int(f.window / time.Second)
+	}
+	if changed("timezone") {
+		silence.Timezone = f.timezone
+	}
+	if changed("comment") {
+		silence.Comment = f.comment
+	}
+	return nil
+}
+
+func newSilenceListCommand() *cobra.Command {
+	var expired bool
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List silences",
+		Aliases: []string{"ls"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			silences, err := c.ListSilences(expired)
+			if err != nil {
+				return fmt.Errorf("failed to list silences: %v", err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "ID\tSTATE\tMATCHERS\tSTARTS\tENDS\tSCHEDULE\tCREATED BY\tCOMMENT")
+
+			for _, s := range silences {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					s.ID,
+					silenceState(&s),
+					formatMatchers(&s.Silence),
+					s.StartsAt.Format(time.RFC3339),
+					formatOptionalTime(s.EndsAt),
+					formatSchedule(&s),
+					s.CreatedBy,
+					s.Comment,
+				)
+			}
+
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().BoolVar(&expired, "expired", false, "Include expired silences")
+	return cmd
+}
+
+func newSilenceShowCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show [silence_id]",
+		Short: "Show a silence",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			s, err := c.GetSilence(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to get silence: %v", err)
+			}
+
+			printSilence(s)
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func newSilenceCreateCommand() *cobra.Command {
+	var flags silenceFlags
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a silence or recurring maintenance window",
+		Example: `  containereye silence create --container 'web-*' --duration 2h --comment "deploy"
+  containereye silence create --label com.docker.compose.project=shop \
+    --schedule "0 2 * * sun" --window 1h --comment "weekly maintenance"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			var silence models.Silence
+			if err := flags.apply(cmd, &silence); err != nil {
+				return err
+			}
+
+			created, err := c.CreateSilence(&silence)
+			if err != nil {
+				return fmt.Errorf("failed to create silence: %v", err)
+			}
+
+			fmt.Printf("Silence %d created\n", created.ID)
+			return nil
+		},
+	}
+
+	flags.bind(cmd)
+	return cmd
+}
+
+func newSilenceUpdateCommand() *cobra.Command {
+	var flags silenceFlags
+
+	cmd := &cobra.Command{
+		Use:   "update [silence_id]",
+		Short: "Update a silence",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			existing, err := c.GetSilence(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to get silence: %v", err)
+			}
+
+			silence := existing.Silence
+			if err := flags.apply(cmd, &silence); err != nil {
+				return err
+			}
+
+			if _, err := c.UpdateSilence(args[0], &silence); err != nil {
+				return fmt.Errorf("failed to update silence: %v", err)
+			}
+
+			fmt.Printf("Silence %s updated\n", args[0])
+			return nil
+		},
+	}
+
+	flags.bind(cmd)
+	return cmd
+}
+
+func newSilenceExpireCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "expire [silence_id]",
+		Short:   "End a silence now",
+		Aliases: []string{"rm", "delete"},
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			if err := c.ExpireSilence(args[0]); err != nil {
+				return fmt.Errorf("failed to expire silence: %v", err)
+			}
+
+			fmt.Printf("Silence %s expired\n", args[0])
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func printSilence(s *client.Silence) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%d\n", s.ID)
+	fmt.Fprintf(w, "State:\t%s\n", silenceState(s))
+	fmt.Fprintf(w, "Matchers:\t%s\n", formatMatchers(&s.Silence))
+	fmt.Fprintf(w, "Starts:\t%s\n", s.StartsAt.Format(time.RFC3339))
+	fmt.Fprintf(w, "Ends:\t%s\n", formatOptionalTime(s.EndsAt))
+	if s.IsRecurring() {
+		fmt.Fprintf(w, "Schedule:\t%s\n", formatSchedule(s))
+		fmt.Fprintf(w, "Next Window:\t%s\n", formatOptionalTime(s.NextWindow))
+	}
+	fmt.Fprintf(w, "Created By:\t%s\n", s.CreatedBy)
+	fmt.Fprintf(w, "Comment:\t%s\n", s.Comment)
+	w.Flush()
+}
+
+func silenceState(s *client.Silence) string {
+	now := time.Now()
+	switch {
+	case s.Active:
+		return "active"
+	case s.Expired(now):
+		return "expired"
+	case now.Before(s.StartsAt):
+		return "pending"
+	default:
+		// Scheduled silence between two maintenance windows
+		return "waiting"
+	}
+}
+
+func formatMatchers(s *models.Silence) string {
+	var matchers []string
+	if s.ContainerName != "" {
+		matchers = append(matchers, "container="+s.ContainerName)
+	}
+	if s.ContainerID != "" {
+		matchers = append(matchers, "container_id="+s.ContainerID)
+	}
+	if s.Image != "" {
+		matchers = append(matchers, "image="+s.Image)
+	}
+	keys := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		matchers = append(matchers, fmt.Sprintf("label:%s=%s", k, s.Labels[k]))
+	}
+	if s.RuleName != "" {
+		matchers = append(matchers, "rule="+s.RuleName)
+	}
+	if s.Level != "" {
+		matchers = append(matchers, "level="+string(s.Level))
+	}
+	return strings.Join(matchers, ",")
+}
+
+func formatSchedule(s *client.Silence) string {
+	if !s.IsRecurring() {
+		return "-"
+	}
+	schedule := fmt.Sprintf("%s for %s", s.Schedule, time.Duration(s.Duration)*time.Second)
+	if s.Timezone != "" {
+		schedule += " (" + s.Timezone + ")"
+	}
+	return schedule
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"fmt"
 	"os"
+	"strings"
 	"text/tabwriter"
 	"time"
 
@@ -61,14 +62,22 @@ func newStatsShowCommand() *cobra.Command {
 
 func newStatsHistoryCommand() *cobra.Command {
 	var (
-		from  string
-		to    string
-		limit int
+		from    string
+		to      string
+		limit   int
+		step    time.Duration
+		agg     string
+		metrics []string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "history [container_id]",
 		Short: "Show historical container statistics",
+		Long: `Show historical container statistics.
+
+With --step or --agg the statistics are aggregated by the server into
+points --step apart, e.g. --step 5m --agg max. --agg rate shows the
+per-second increase of the network and block I/O counters.`,
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			c, err := client.NewClient()
@@ -92,6 +101,16 @@ func newStatsHistoryCommand() *cobra.Command {
 				toTime = &t
 			}
 
+			if step > 0 || agg != "" {
+				return printStatsSeries(c, client.StatsQueryOptions{
+					ContainerID: args[0],
+					From:        fromTime,
+					To:          toTime,
+					Step:        step,
+					Aggregation: agg,
+				}, metrics)
+			}
+
 			stats, err := c.GetContainerStatsHistory(args[0], fromTime, toTime, limit)
 			if err != nil {
 				return fmt.Errorf("failed to get container stats history: %v", err)
@@ -118,10 +137,82 @@ func newStatsHistoryCommand() *cobra.Command {
 	cmd.Flags().StringVar(&from, "from", "", "Start time (RFC3339 format)")
 	cmd.Flags().StringVar(&to, "to", "", "End time (RFC3339 format)")
 	cmd.Flags().IntVar(&limit, "limit", 0, "Limit the number of records")
+	cmd.Flags().DurationVar(&step, "step", 0, "Aggregate into points this far apart, e.g. 5m")
+	cmd.Flags().StringVar(&agg, "agg", "", "Aggregation (avg/min/max/sum/p95/rate)")
+	cmd.Flags().StringSliceVar(&metrics, "metric", nil, "Metrics to aggregate, defaults to CPU and memory or, with --agg rate, network and block I/O")
 
 	return cmd
 }
 
+// printStatsSeries queries each metric and prints the aggregated points,
+// one row per container and timestamp
+func printStatsSeries(c *client.Client, opts client.StatsQueryOptions, metrics []string) error {
+	if len(metrics) == 0 {
+		metrics = []string{"cpu_percent", "memory_usage", "memory_percent"}
+		if opts.Aggregation == "rate" {
+			metrics = []string{"network_rx", "network_tx", "block_read", "block_write"}
+		}
+	}
+
+	type row struct {
+		name      string
+		timestamp time.Time
+		values    []string
+	}
+	var rows []*row
+	index := make(map[string]*row)
+	for i, metric := range metrics {
+		opts.Metric = metric
+		result, err := c.QueryStats(opts)
+		if err != nil {
+			return fmt.Errorf("failed to query %s: %v", metric, err)
+		}
+		for _, series := range result.Series {
+			for j, t := range result.Timestamps {
+				key := series.Labels["container_id"] + t.String()
+				r, ok := index[key]
+				if !ok {
+					r = &row{name: series.Labels["container_name"], timestamp: t, values: make([]string, len(metrics))}
+					for k := range r.values {
+						r.values[k] = "-"
+					}
+					index[key] = r
+					rows = append(rows, r)
+				}
+				if v := series.Values[j]; v != nil {
+					r.values[i] = formatSeriesValue(metric, opts.Aggregation, *v)
+				}
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	header := "TIMESTAMP\tNAME"
+	for _, metric := range metrics {
+		header += "\t" + strings.ToUpper(metric)
+	}
+	fmt.Fprintln(w, header)
+	for _, r := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.timestamp.Format(time.RFC3339), r.name, strings.Join(r.values, "\t"))
+	}
+	return w.Flush()
+}
+
+func formatSeriesValue(metric, agg string, v float64) string {
+	switch {
+	case agg == "rate":
+		return formatBytes(uint64(v)) + "/s"
+	case strings.HasSuffix(metric, "_percent"):
+		return fmt.Sprintf("%.2f%%", v)
+	case strings.HasSuffix(metric, "_rate"):
+		return formatBytes(uint64(v)) + "/s"
+	case metric == "pids":
+		return fmt.Sprintf("%.0f", v)
+	default:
+		return formatBytes(uint64(v))
+	}
+}
+
 func newStatsExportCommand() *cobra.Command {
 	var (
 		from   string
@@ -0,0 +1,334 @@
+package commands
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"containereye/internal/api/client"
+	"containereye/internal/models"
+	"github.com/spf13/cobra"
+)
+
+func NewUserCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "users",
+		Short:   "User management commands (admin only)",
+		Aliases: []string{"user"},
+	}
+
+	// Add subcommands
+	cmd.AddCommand(newUserListCommand())
+	cmd.AddCommand(newUserAddCommand())
+	cmd.AddCommand(newUserUpdateCommand())
+	cmd.AddCommand(newUserActiveCommand("activate", true))
+	cmd.AddCommand(newUserActiveCommand("deactivate", false))
+	cmd.AddCommand(newUserResetPasswordCommand())
+	cmd.AddCommand(newUserRemoveCommand())
+
+	return cmd
+}
+
+// passwordFlags reads a password from --password or stdin, or generates one
+type passwordFlags struct {
+	password string
+	stdin    bool
+}
+
+func (f *passwordFlags) bind(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.password, "password", "", "Password (generated and printed when not given)")
+	cmd.Flags().BoolVar(&f.stdin, "password-stdin", false, "Read the password from stdin")
+}
+
+// read returns the password and whether it was generated
+func (f *passwordFlags) read() (string, bool, error) {
+	if f.stdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", false, fmt.Errorf("failed to read password from stdin: %v", err)
+		}
+		return strings.TrimRight(line, "\r\n"), false, nil
+	}
+	if f.password != "" {
+		return f.password, false, nil
+	}
+
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", false, fmt.Errorf("failed to generate password: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), true, nil
+}
+
+func parseRole(s string) (*models.Role, error) {
+	role := models.Role(strings.ToLower(s))
+	if !role.Valid() {
+		return nil, fmt.Errorf("invalid role %q, expected admin, user or viewer", s)
+	}
+	return &role, nil
+}
+
+func newUserListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List users",
+		Aliases: []string{"ls"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			users, err := c.ListUsers()
+			if err != nil {
+				return fmt.Errorf("failed to list users: %v", err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "ID\tUSERNAME\tEMAIL\tROLE\tSCOPE\tACTIVE\tLAST LOGIN")
+			for _, user := range users {
+				email := user.EmailAddress()
+				if email == "" {
+					email = "-"
+				}
+				lastLogin := "-"
+				if user.LastLoginAt != nil {
+					lastLogin = user.LastLoginAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%v\t%s\n",
+					user.ID,
+					user.Username,
+					email,
+					user.Role,
+					userScope(&user),
+					user.IsActive,
+					lastLogin,
+				)
+			}
+
+			return w.Flush()
+		},
+	}
+
+	return cmd
+}
+
+// userScope describes the hosts and labels a user is restricted to
+func userScope(user *models.User) string {
+	var parts []string
+	if len(user.Hosts) > 0 {
+		parts = append(parts, "hosts="+strings.Join(user.Hosts, ","))
+	}
+	if user.LabelSelector != "" {
+		parts = append(parts, "labels="+user.LabelSelector)
+	}
+	if len(parts) == 0 {
+		return "all"
+	}
+	return strings.Join(parts, " ")
+}
+
+func newUserAddCommand() *cobra.Command {
+	var (
+		email    string
+		role     string
+		inactive bool
+		password passwordFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "add [username]",
+		Short: "Create a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := &client.UserRequest{Username: args[0]}
+			var err error
+			if req.Role, err = parseRole(role); err != nil {
+				return err
+			}
+			if email != "" {
+				req.Email = &email
+			}
+			if inactive {
+				active := false
+				req.IsActive = &active
+			}
+			pw, generated, err := password.read()
+			if err != nil {
+				return err
+			}
+			req.Password = pw
+
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			created, err := c.CreateUser(req)
+			if err != nil {
+				return fmt.Errorf("failed to create user: %v", err)
+			}
+
+			fmt.Printf("User %s created with role %s\n", created.Username, created.Role)
+			if generated {
+				fmt.Printf("Password: %s\n", pw)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&email, "email", "", "Email address")
+	cmd.Flags().StringVar(&role, "role", string(models.RoleViewer), "Role (admin/user/viewer)")
+	cmd.Flags().BoolVar(&inactive, "inactive", false, "Create the user deactivated")
+	password.bind(cmd)
+
+	return cmd
+}
+
+func newUserUpdateCommand() *cobra.Command {
+	var (
+		email, role, labels string
+		hosts               []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "update [user]",
+		Short: "Change a user's role, email address or scope (user is an ID or username)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := &client.UserRequest{}
+			if cmd.Flags().Changed("role") {
+				var err error
+				if req.Role, err = parseRole(role); err != nil {
+					return err
+				}
+			}
+			if cmd.Flags().Changed("email") {
+				req.Email = &email
+			}
+			if cmd.Flags().Changed("hosts") {
+				req.Hosts = &hosts
+			}
+			if cmd.Flags().Changed("labels") {
+				req.LabelSelector = &labels
+			}
+			if req.Role == nil && req.Email == nil && req.Hosts == nil && req.LabelSelector == nil {
+				return fmt.Errorf("nothing to update, set --role, --email, --hosts or --labels")
+			}
+
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			updated, err := c.UpdateUser(args[0], req)
+			if err != nil {
+				return fmt.Errorf("failed to update user: %v", err)
+			}
+
+			fmt.Printf("User %s updated\n", updated.Username)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&role, "role", "", "Role (admin/user/viewer)")
+	cmd.Flags().StringVar(&email, "email", "", "Email address, empty to remove it")
+	cmd.Flags().StringSliceVar(&hosts, "hosts", nil, "Host patterns the user is restricted to, empty to allow all hosts")
+	cmd.Flags().StringVar(&labels, "labels", "", "Container label selector the user is restricted to, e.g. team=web, empty to remove it")
+
+	return cmd
+}
+
+func newUserActiveCommand(use string, active bool) *cobra.Command {
+	short := "Allow a user to log in again"
+	if !active {
+		short = "Prevent a user from logging in"
+	}
+
+	cmd := &cobra.Command{
+		Use:   use + " [user]",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			updated, err := c.UpdateUser(args[0], &client.UserRequest{IsActive: &active})
+			if err != nil {
+				return fmt.Errorf("failed to %s user: %v", use, err)
+			}
+
+			fmt.Printf("User %s %sd\n", updated.Username, use)
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func newUserResetPasswordCommand() *cobra.Command {
+	var password passwordFlags
+
+	cmd := &cobra.Command{
+		Use:   "reset-password [user]",
+		Short: "Set a new password for a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pw, generated, err := password.read()
+			if err != nil {
+				return err
+			}
+
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			updated, err := c.UpdateUser(args[0], &client.UserRequest{Password: pw})
+			if err != nil {
+				return fmt.Errorf("failed to reset password: %v", err)
+			}
+
+			fmt.Printf("Password of %s reset\n", updated.Username)
+			if generated {
+				fmt.Printf("Password: %s\n", pw)
+			}
+			return nil
+		},
+	}
+
+	password.bind(cmd)
+
+	return cmd
+}
+
+func newUserRemoveCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "remove [user]",
+		Short:   "Delete a user",
+		Aliases: []string{"rm", "delete"},
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := client.NewClient()
+			if err != nil {
+				return fmt.Errorf("failed to create client: %v", err)
+			}
+
+			if err := c.DeleteUser(args[0]); err != nil {
+				return fmt.Errorf("failed to remove user: %v", err)
+			}
+
+			fmt.Printf("User %s removed\n", args[0])
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -2,67 +2,276 @@ package config
 
 import (
 	"fmt"
-	"os"
+	"net"
+	"strings"
+	"time"
+
+	"containereye/internal/alert"
+	"containereye/internal/auth"
+	"containereye/internal/database"
+	"containereye/internal/logging"
+	"containereye/internal/monitor"
+	"containereye/internal/notify"
+	"containereye/internal/retention"
 
 	"github.com/spf13/viper"
 )
 
+// EnvPrefix prefixes the environment variables overriding config keys,
+// e.g. CONTAINEREYE_SERVER_PORT for server.port
+const EnvPrefix = "CONTAINEREYE"
+
 type Config struct {
+	Server struct {
+		Host  string // Address to listen on, all interfaces when empty
+		Port  int
+		Debug bool // Run the HTTP router in debug mode
+		// TrustedProxies are the addresses or CIDR ranges of the reverse
+		// proxies whose X-Forwarded-For header names the client, none when
+		// empty
+		TrustedProxies []string `mapstructure:"trusted_proxies"`
+		// PublicMetrics serves /metrics without authentication
+		PublicMetrics bool `mapstructure:"public_metrics"`
+	}
 	Database struct {
-		Path string
+		Driver string // sqlite (default) or postgres
+		DSN    string // Connection string, the file path for sqlite
+		Path   string // SQLite file path, used when no dsn is set
 	}
-	Alert struct {
+	Monitor monitor.Config
+	Alert   struct {
 		Slack struct {
 			Token   string
 			Channel string
 		}
 		Email struct {
-			SMTPHost    string
-			SMTPPort    int
-			From        string
-			Password    string
-			ToReceivers []string
+			SMTPHost    string   `mapstructure:"smtp_host"`
+			SMTPPort    int      `mapstructure:"smtp_port"`
+			From        string   `mapstructure:"from"`
+			Password    string   `mapstructure:"password"`
+			ToReceivers []string `mapstructure:"to_receivers"`
 		}
+		// Handlers lists the notification channels alerts are sent to
+		Handlers []notify.HandlerConfig
+
+		// DefaultCooldown applies to rules without a cooldown period
+		DefaultCooldown    time.Duration `mapstructure:"default_cooldown"`
+		EscalationEnabled  bool          `mapstructure:"escalation_enabled"`
+		EscalationInterval time.Duration `mapstructure:"escalation_interval"`
+		// EscalationPolicies default to alert.DefaultEscalationPolicies when empty
+		EscalationPolicies []alert.EscalationPolicy `mapstructure:"escalation_policies"`
 	}
-	Server struct {
-		Port int
+	Notifications struct {
+		// Outbox tunes how queued notifications are delivered and retried
+		Outbox notify.OutboxConfig
 	}
+	Auth    auth.Config
+	Logging logging.Config
+	// Hosts lists the Docker hosts to monitor, the local daemon when empty
+	Hosts []monitor.HostConfig
+	// Retention bounds how long stats are kept at each resolution
+	Retention retention.Policy
+}
+
+// setDefaults registers every scalar setting with its default, which also
+// lets viper find its environment variable. A scalar setting missing here
+// cannot be overridden from the environment.
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("server.host", "")
+	v.SetDefault("server.port", 8080)
+	v.SetDefault("server.debug", false)
+	v.SetDefault("server.public_metrics", false)
+
+	v.SetDefault("database.driver", database.DefaultDriver)
+	v.SetDefault("database.dsn", "")
+	v.SetDefault("database.path", "data/containereye.db")
+
+	monitorDefaults := monitor.DefaultConfig()
+	v.SetDefault("monitor.interval", monitorDefaults.Interval)
+	v.SetDefault("monitor.batch_size", monitorDefaults.BatchSize)
+	v.SetDefault("monitor.max_concurrent", monitorDefaults.MaxConcurrent)
+	v.SetDefault("monitor.retry_attempts", monitorDefaults.RetryAttempts)
+	v.SetDefault("monitor.retry_delay", monitorDefaults.RetryDelay)
+
+	v.SetDefault("alert.slack.token", "")
+	v.SetDefault("alert.slack.channel", "")
+	v.SetDefault("alert.email.smtp_host", "")
+	v.SetDefault("alert.email.smtp_port", 0)
+	v.SetDefault("alert.email.from", "")
+	v.SetDefault("alert.email.password", "")
+	v.SetDefault("alert.default_cooldown", time.Duration(0))
+	v.SetDefault("alert.escalation_enabled", false)
+	v.SetDefault("alert.escalation_interval", time.Minute)
+
+	outboxDefaults := notify.DefaultOutboxConfig()
+	v.SetDefault("notifications.outbox.workers", outboxDefaults.Workers)
+	v.SetDefault("notifications.outbox.max_attempts", outboxDefaults.MaxAttempts)
+	v.SetDefault("notifications.outbox.base_backoff", outboxDefaults.BaseBackoff)
+	v.SetDefault("notifications.outbox.max_backoff", outboxDefaults.MaxBackoff)
+	v.SetDefault("notifications.outbox.poll_interval", outboxDefaults.PollInterval)
+	v.SetDefault("notifications.outbox.batch_size", outboxDefaults.BatchSize)
+	v.SetDefault("notifications.outbox.lease", outboxDefaults.Lease)
+
+	authDefaults := auth.DefaultConfig()
+	v.SetDefault("auth.jwt_secret", authDefaults.JWTSecret)
+	v.SetDefault("auth.jwt_key_id", authDefaults.JWTKeyID)
+	v.SetDefault("auth.token_expiry", authDefaults.TokenExpiry)
+	v.SetDefault("auth.refresh_token_expiry", authDefaults.RefreshTokenExpiry)
+	v.SetDefault("auth.allow_registration", authDefaults.AllowRegistration)
+	v.SetDefault("auth.invite_token", authDefaults.InviteToken)
+	v.SetDefault("auth.registration_role", authDefaults.RegistrationRole)
+	v.SetDefault("auth.min_password_length", authDefaults.MinPasswordLength)
+	v.SetDefault("auth.admin_username", authDefaults.AdminUsername)
+	v.SetDefault("auth.admin_password", authDefaults.AdminPassword)
+
+	loggingDefaults := logging.DefaultConfig()
+	v.SetDefault("logging.level", loggingDefaults.Level)
+	v.SetDefault("logging.format", loggingDefaults.Format)
+	v.SetDefault("logging.output", loggingDefaults.Output)
+
+	retentionDefaults := retention.DefaultPolicy()
+	v.SetDefault("retention.raw_days", retentionDefaults.RawDays)
+	v.SetDefault("retention.minute_days", retentionDefaults.MinuteDays)
+	v.SetDefault("retention.hour_days", retentionDefaults.HourDays)
+	v.SetDefault("retention.interval", retentionDefaults.Interval)
 }
 
-// LoadConfig loads the configuration from config.yaml
-func LoadConfig() *Config {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
+// LoadConfig reads the configuration from path, or from config.yaml in the
+// working directory when path is empty. Without a config file the defaults
+// apply. Every scalar setting can be overridden by a CONTAINEREYE_*
+// environment variable; list settings such as hosts, alert.handlers or
+// alert.email.to_receivers can only be set in the file. The result is
+// validated.
+func LoadConfig(path string) (*Config, error) {
+	v := viper.New()
+	setDefaults(v)
+
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	if path != "" {
+		v.SetConfigFile(path)
+	} else {
+		v.SetConfigName("config")
+		v.SetConfigType("yaml")
+		v.AddConfigPath(".")
+	}
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok || path != "" {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+	}
 
 	var config Config
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	return &config, nil
+}
 
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			// Config file not found, use default values
-			config.Database.Path = "data/containereye.db"
-			config.Server.Port = 8080
-			
-			// Create default config file
-			viper.Set("database.path", config.Database.Path)
-			viper.Set("server.port", config.Server.Port)
-			
-			// Ensure data directory exists
-			if err := os.MkdirAll("data", 0755); err != nil {
-				fmt.Printf("Warning: Failed to create data directory: %v\n", err)
-			}
-			
-			if err := viper.SafeWriteConfig(); err != nil {
-				fmt.Printf("Warning: Failed to write default config: %v\n", err)
+// Validate checks every setting but auth, which auth.Configure checks only
+// for the commands that use it, filling in the retention defaults
+func (c *Config) Validate() error {
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		return fmt.Errorf("server port must be between 1 and 65535")
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return fmt.Errorf("server trusted_proxies: %q is neither an IP address nor a CIDR range", proxy)
 			}
-		} else {
-			fmt.Printf("Error reading config file: %v\n", err)
 		}
-	} else {
-		if err := viper.Unmarshal(&config); err != nil {
-			fmt.Printf("Error unmarshaling config: %v\n", err)
+	}
+
+	db := c.DatabaseConfig()
+	if !contains(database.Drivers(), db.Driver) {
+		return fmt.Errorf("unknown database driver %q, available: %s", db.Driver, strings.Join(database.Drivers(), ", "))
+	}
+	if db.DSN == "" {
+		return fmt.Errorf("database dsn is required")
+	}
+
+	if err := c.Monitor.Validate(); err != nil {
+		return err
+	}
+	if c.Alert.DefaultCooldown < 0 {
+		return fmt.Errorf("alert default_cooldown must not be negative")
+	}
+	if c.Alert.EscalationInterval < 0 {
+		return fmt.Errorf("alert escalation_interval must not be negative")
+	}
+	if err := c.Notifications.Outbox.Validate(); err != nil {
+		return err
+	}
+	if err := c.Logging.Validate(); err != nil {
+		return err
+	}
+	return c.Retention.Validate()
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
 		}
 	}
+	return false
+}
+
+// Addr returns the address the API server listens on
+func (c *Config) Addr() string {
+	return fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Port)
+}
+
+// DatabaseConfig returns the configured database connection
+func (c *Config) DatabaseConfig() database.Config {
+	cfg := database.Config{Driver: c.Database.Driver, DSN: c.Database.DSN}
+	if cfg.DSN == "" {
+		cfg.DSN = c.Database.Path
+	}
+	return cfg
+}
+
+// AlertHandlers returns the configured notification channels. The legacy
+// alert.slack and alert.email sections are still honoured and turned into
+// handlers named "slack" and "email" unless a handler already uses the name.
+func (c *Config) AlertHandlers() []notify.HandlerConfig {
+	handlers := append([]notify.HandlerConfig{}, c.Alert.Handlers...)
+
+	taken := make(map[string]bool, len(handlers))
+	for _, h := range handlers {
+		taken[h.Name] = true
+	}
+
+	if c.Alert.Slack.Token != "" && !taken["slack"] {
+		handlers = append(handlers, notify.HandlerConfig{
+			Name: "slack",
+			Type: "slack",
+			Config: map[string]interface{}{
+				"token":   c.Alert.Slack.Token,
+				"channel": c.Alert.Slack.Channel,
+			},
+		})
+	}
+
+	if c.Alert.Email.SMTPHost != "" && !taken["email"] {
+		handlers = append(handlers, notify.HandlerConfig{
+			Name: "email",
+			Type: "email",
+			Config: map[string]interface{}{
+				"smtp_host":     c.Alert.Email.SMTPHost,
+				"smtp_port":     c.Alert.Email.SMTPPort,
+				"smtp_user":     c.Alert.Email.From,
+				"smtp_password": c.Alert.Email.Password,
+				"from":          c.Alert.Email.From,
+				"to":            c.Alert.Email.ToReceivers,
+			},
+		})
+	}
 
-	return &config
+	return handlers
 }
@@ -3,13 +3,13 @@ package database
 import (
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
-	"containereye/internal/models"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 var (
@@ -17,40 +17,87 @@ var (
 	once sync.Once
 )
 
-// Initialize initializes the database connection
-func Initialize(dbPath string) error {
-	var initErr error
+// DefaultDriver is used when no driver is configured
+const DefaultDriver = "sqlite"
+
+// Config selects the database driver and what it connects to
+type Config struct {
+	Driver string `mapstructure:"driver"` // sqlite or postgres
+	DSN    string `mapstructure:"dsn"`    // File path for sqlite, connection string for postgres
+}
+
+// Dialector opens the gorm dialector of a driver for a DSN
+type Dialector func(dsn string) (gorm.Dialector, error)
+
+var drivers = make(map[string]Dialector)
+
+// RegisterDriver makes a database driver available under its names
+func RegisterDriver(open Dialector, names ...string) {
+	for _, name := range names {
+		drivers[name] = open
+	}
+}
+
+// Drivers returns the names of the registered drivers
+func Drivers() []string {
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open connects to the database without changing its schema
+func Open(cfg Config) error {
+	var openErr error
 	once.Do(func() {
-		// Ensure the directory exists
-		dir := filepath.Dir(dbPath)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			initErr = fmt.Errorf("failed to create database directory: %v", err)
+		driver := cfg.Driver
+		if driver == "" {
+			driver = DefaultDriver
+		}
+		open, ok := drivers[driver]
+		if !ok {
+			openErr = fmt.Errorf("unknown database driver %q, available: %s", driver, strings.Join(Drivers(), ", "))
+			return
+		}
+		if cfg.DSN == "" {
+			openErr = fmt.Errorf("database dsn is required")
 			return
 		}
 
-		var err error
-		db, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+		dialector, err := open(cfg.DSN)
 		if err != nil {
-			initErr = fmt.Errorf("failed to connect to database: %v", err)
+			openErr = err
 			return
 		}
-
-		// Auto migrate the schema
-		if err := db.AutoMigrate(
-			&models.Container{},
-			&models.ContainerStats{},
-			&models.Alert{},
-			&models.AlertRule{},
-			&models.User{},
-		); err != nil {
-			initErr = fmt.Errorf("failed to migrate database: %v", err)
+		// Query errors and slow queries go to the configured log output
+		db, err = gorm.Open(dialector, &gorm.Config{
+			Logger: logger.New(log.New(log.Writer(), "", 0), logger.Config{
+				SlowThreshold:             200 * time.Millisecond,
+				LogLevel:                  logger.Warn,
+				IgnoreRecordNotFoundError: true,
+			}),
+		})
+		if err != nil {
+			openErr = fmt.Errorf("failed to connect to database: %v", err)
 			return
 		}
-
-		log.Printf("Database initialized at %s", dbPath)
+		log.Printf("Connected to %s database", driver)
 	})
 
-	return initErr
+	return openErr
+}
+
+// Initialize connects to the database and applies the pending migrations
+func Initialize(cfg Config) error {
+	if err := Open(cfg); err != nil {
+		return err
+	}
+	if err := MigrateUp(0); err != nil {
+		return fmt.Errorf("failed to migrate database: %v", err)
+	}
+	return nil
 }
 
 // GetDB returns the database instance
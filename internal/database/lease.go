@@ -0,0 +1,65 @@
+package database
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Lease is held by one server at a time until it expires, so that servers
+// sharing a database run a background job such as the escalator once
+type Lease struct {
+	Name      string    `gorm:"primaryKey"`
+	Owner     string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+}
+
+// InstanceID identifies this server process as the owner of leases and of
+// the notification deliveries it claims
+var InstanceID = newInstanceID()
+
+func newInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(buf))
+}
+
+// AcquireLease takes the named lease for ttl, or renews it when this
+// server already holds it, and reports whether this server holds it now.
+// Holders renew the lease well within ttl; when a holder stops, another
+// server takes over once the lease has expired.
+func AcquireLease(db *gorm.DB, name string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	lease := Lease{Name: name, Owner: InstanceID, ExpiresAt: now.Add(ttl)}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&lease)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return true, nil
+	}
+
+	result = db.Model(&Lease{}).
+		Where("name = ? AND (owner = ? OR expires_at < ?)", name, InstanceID, now).
+		Updates(map[string]interface{}{"owner": InstanceID, "expires_at": lease.ExpiresAt})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ReleaseLease gives up the named lease if this server holds it, so that
+// another server can take over without waiting for it to expire
+func ReleaseLease(db *gorm.DB, name string) error {
+	return db.Where("name = ? AND owner = ?", name, InstanceID).Delete(&Lease{}).Error
+}
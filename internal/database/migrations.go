@@ -0,0 +1,324 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SchemaMigration records an applied migration
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// Migration is a reversible schema change. Migrations are applied in
+// version order, each in its own transaction, and recorded in the
+// schema_migrations table.
+//
+// Migrations work on the snapshots in schema.go rather than the models. The
+// baseline also upgrades databases created before migrations were
+// versioned, whose tables may already have columns of later migrations, so
+// migrations check for what they add before adding it.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// MigrationState is a known migration and when it was applied, if it was
+type MigrationState struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// baselineTables are the tables of the baseline migration, in creation order
+var baselineTables = []interface{}{
+	&baselineContainer{},
+	&baselineContainerStats{},
+	&baselineAlert{},
+	&baselineAlertRule{},
+	&baselineUser{},
+	&baselineNotificationDelivery{},
+	&baselineDeliveryAttempt{},
+	&baselineAlertEscalation{},
+	&baselineSilence{},
+	&baselineContainerEvent{},
+	&baselineHost{},
+}
+
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(baselineTables...)
+		},
+		Down: func(tx *gorm.DB) error {
+			for i := len(baselineTables) - 1; i >= 0; i-- {
+				if err := tx.Migrator().DropTable(baselineTables[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 2,
+		Name:    "stats_rollups",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&statsRollupV2{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&statsRollupV2{})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "container_labels",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&containerLabelsV3{}, "Labels") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&containerLabelsV3{}, "Labels")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&containerLabelsV3{}, "Labels")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "user_accounts",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			// The unique index of the unused api_key column allowed a single
+			// user without a key, and empty emails collided the same way.
+			// The column is left in place, SQLite would rebuild the table.
+			if m.HasIndex(&legacyUserAPIKey{}, "ApiKey") {
+				if err := m.DropIndex(&legacyUserAPIKey{}, "ApiKey"); err != nil {
+					return err
+				}
+			}
+			if err := tx.Exec("UPDATE users SET email = NULL WHERE email = ''").Error; err != nil {
+				return err
+			}
+			if m.HasColumn(&userLoginV4{}, "LastLoginAt") {
+				return nil
+			}
+			return m.AddColumn(&userLoginV4{}, "LastLoginAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			// last_login_at is kept for the same reason, earlier versions
+			// ignore it
+			m := tx.Migrator()
+			if !m.HasColumn(&legacyUserAPIKey{}, "ApiKey") {
+				if err := m.AddColumn(&legacyUserAPIKey{}, "ApiKey"); err != nil {
+					return err
+				}
+			}
+			return m.CreateIndex(&legacyUserAPIKey{}, "ApiKey")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "api_keys",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&apiKeyV5{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&apiKeyV5{})
+		},
+	},
+	{
+		Version: 6,
+		Name:    "permissions",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&rolePermissionsV6{}); err != nil {
+				return err
+			}
+			m := tx.Migrator()
+			for _, field := range []string{"Hosts", "LabelSelector"} {
+				if m.HasColumn(&userScopeV6{}, field) {
+					continue
+				}
+				if err := m.AddColumn(&userScopeV6{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			// The user scope columns are kept like last_login_at
+			return tx.Migrator().DropTable(&rolePermissionsV6{})
+		},
+	},
+	{
+		Version: 7,
+		Name:    "audit_log",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&auditEntryV7{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&auditEntryV7{})
+		},
+	},
+	{
+		Version: 8,
+		Name:    "sessions",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&refreshTokenV8{}, &revokedTokenV8{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&refreshTokenV8{}, &revokedTokenV8{})
+		},
+	},
+	{
+		Version: 9,
+		Name:    "leases",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&leaseV9{}); err != nil {
+				return err
+			}
+			m := tx.Migrator()
+			for _, field := range []string{"ClaimedBy", "LeaseUntil"} {
+				if m.HasColumn(&deliveryClaimV9{}, field) {
+					continue
+				}
+				if err := m.AddColumn(&deliveryClaimV9{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			// The claim columns are kept like last_login_at
+			return tx.Migrator().DropTable(&leaseV9{})
+		},
+	},
+	{
+		Version: 10,
+		Name:    "stats_labels",
+		Up: func(tx *gorm.DB) error {
+			// Labels are kept with the container, not with every sample.
+			// Both SQLite and PostgreSQL drop the column in place, where
+			// the migrator would copy the whole table on SQLite.
+			if !tx.Migrator().HasColumn(&statsLabelsV10{}, "Labels") {
+				return nil
+			}
+			return tx.Exec("ALTER TABLE container_stats DROP COLUMN labels").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&statsLabelsV10{}, "Labels") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&statsLabelsV10{}, "Labels")
+		},
+	},
+}
+
+// appliedMigrations returns the applied migrations by version, creating
+// the schema_migrations table if needed
+func appliedMigrations() (map[int]SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+
+	var rows []SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+
+	latest := migrations[len(migrations)-1].Version
+	for version := range applied {
+		if version > latest {
+			return nil, fmt.Errorf("database schema version %d is newer than the latest known migration %d", version, latest)
+		}
+	}
+	return applied, nil
+}
+
+// MigrateUp applies the pending migrations up to and including version
+// target, all of them when target is 0
+func MigrateUp(target int) error {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if target > 0 && m.Version > target {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		m := m
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d %s failed: %v", m.Version, m.Name, err)
+		}
+		log.Printf("Applied migration %d %s", m.Version, m.Name)
+	}
+	return nil
+}
+
+// MigrateDown reverts the last steps applied migrations
+func MigrateDown(steps int) error {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return fmt.Errorf("reverting migration %d %s failed: %v", m.Version, m.Name, err)
+		}
+		log.Printf("Reverted migration %d %s", m.Version, m.Name)
+		steps--
+	}
+	return nil
+}
+
+// MigrationStatus lists the known migrations and when they were applied
+func MigrationStatus() ([]MigrationState, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, len(migrations))
+	for i, m := range migrations {
+		states[i] = MigrationState{Version: m.Version, Name: m.Name}
+		if row, ok := applied[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			states[i].AppliedAt = &appliedAt
+		}
+	}
+	return states, nil
+}
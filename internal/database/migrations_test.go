@@ -0,0 +1,203 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"containereye/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// postgresDSNEnv names a PostgreSQL database the tests also run against,
+// e.g. "host=localhost user=postgres dbname=postgres sslmode=disable". Each
+// run works in a schema of its own that is dropped afterwards.
+const postgresDSNEnv = "CONTAINEREYE_TEST_POSTGRES_DSN"
+
+// migratedModels are the models whose tables the migrations create
+var migratedModels = []interface{}{
+	&models.Container{}, &models.ContainerStats{}, &models.StatsRollup{},
+	&models.Alert{}, &models.AlertRule{}, &models.AlertEscalation{},
+	&models.NotificationDelivery{}, &models.DeliveryAttempt{},
+	&models.Silence{}, &models.ContainerEvent{}, &models.Host{},
+	&models.User{}, &models.APIKey{}, &models.RolePermissions{},
+	&models.AuditEntry{}, &models.RefreshToken{}, &models.RevokedToken{},
+	&Lease{},
+}
+
+// forEachDriver runs the test against SQLite and, when configured, against
+// PostgreSQL, with the package database pointing at an empty database
+func forEachDriver(t *testing.T, test func(t *testing.T)) {
+	t.Run("sqlite", func(t *testing.T) {
+		useDB(t, "sqlite", filepath.Join(t.TempDir(), "containereye.db"), "")
+		test(t)
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		dsn := os.Getenv(postgresDSNEnv)
+		if dsn == "" {
+			t.Skipf("%s is not set", postgresDSNEnv)
+		}
+		useDB(t, "postgres", dsn, fmt.Sprintf("containereye_test_%d", time.Now().UnixNano()))
+		test(t)
+	})
+}
+
+// useDB connects the package database for the duration of the test. With
+// a schema, the test works in that schema on a single connection.
+func useDB(t *testing.T, driver, dsn, schemaName string) {
+	t.Helper()
+
+	dialector, err := drivers[driver](dsn)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", driver, err)
+	}
+	conn, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to connect to %s: %v", driver, err)
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if schemaName != "" {
+		sqlDB.SetMaxOpenConns(1)
+		if err := conn.Exec("CREATE SCHEMA " + schemaName).Error; err != nil {
+			t.Fatalf("failed to create schema: %v", err)
+		}
+		if err := conn.Exec("SET search_path TO " + schemaName).Error; err != nil {
+			t.Fatalf("failed to select schema: %v", err)
+		}
+	}
+
+	previous := db
+	db = conn
+	t.Cleanup(func() {
+		db = previous
+		if schemaName != "" {
+			if err := conn.Exec("DROP SCHEMA " + schemaName + " CASCADE").Error; err != nil {
+				t.Errorf("failed to drop schema: %v", err)
+			}
+		}
+		sqlDB.Close()
+	})
+}
+
+// checkModelColumns fails the test for every column of the current models
+// that the migrations did not create
+func checkModelColumns(t *testing.T) {
+	t.Helper()
+	m := db.Migrator()
+	for _, model := range migratedModels {
+		s, err := schema.Parse(model, &sync.Map{}, db.NamingStrategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !m.HasTable(s.Table) {
+			t.Errorf("table %s is missing", s.Table)
+			continue
+		}
+		for _, field := range s.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			if !m.HasColumn(model, field.DBName) {
+				t.Errorf("column %s.%s is missing", s.Table, field.DBName)
+			}
+		}
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		if err := MigrateUp(0); err != nil {
+			t.Fatalf("migrating up: %v", err)
+		}
+		checkModelColumns(t)
+		if db.Migrator().HasColumn(&statsLabelsV10{}, "Labels") {
+			t.Error("column container_stats.labels is left after migrating up")
+		}
+
+		// The models are usable on the migrated schema
+		user := models.User{Username: "admin", Password: "x", Role: "admin", IsActive: true}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatalf("creating a user: %v", err)
+		}
+		stats := models.ContainerStats{HostID: "local", ContainerID: "abc", Timestamp: time.Now()}
+		if err := db.Create(&stats).Error; err != nil {
+			t.Fatalf("creating container stats: %v", err)
+		}
+
+		if err := MigrateDown(len(migrations)); err != nil {
+			t.Fatalf("migrating down: %v", err)
+		}
+		for _, model := range migratedModels {
+			if db.Migrator().HasTable(model) {
+				t.Errorf("table of %T is left after migrating down", model)
+			}
+		}
+
+		states, err := MigrationStatus()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, state := range states {
+			if state.AppliedAt != nil {
+				t.Errorf("migration %d is still applied", state.Version)
+			}
+		}
+
+		if err := MigrateUp(0); err != nil {
+			t.Fatalf("migrating up again: %v", err)
+		}
+		checkModelColumns(t)
+	})
+}
+
+func TestLease(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		if err := MigrateUp(0); err != nil {
+			t.Fatalf("migrating up: %v", err)
+		}
+
+		self := InstanceID
+		defer func() { InstanceID = self }()
+
+		acquire := func(owner string, want bool) {
+			t.Helper()
+			InstanceID = owner
+			held, err := AcquireLease(db, "job", time.Minute)
+			if err != nil {
+				t.Fatalf("acquiring as %s: %v", owner, err)
+			}
+			if held != want {
+				t.Fatalf("acquiring as %s held = %v, want %v", owner, held, want)
+			}
+		}
+
+		acquire("a", true)
+		acquire("a", true) // Renewed by its holder
+		acquire("b", false)
+
+		// An expired lease is taken over
+		if err := db.Model(&Lease{}).Where("name = ?", "job").
+			Update("expires_at", time.Now().Add(-time.Second)).Error; err != nil {
+			t.Fatal(err)
+		}
+		acquire("b", true)
+		acquire("a", false)
+
+		// A released lease is free at once
+		InstanceID = "b"
+		if err := ReleaseLease(db, "job"); err != nil {
+			t.Fatal(err)
+		}
+		acquire("a", true)
+	})
+}
@@ -0,0 +1,17 @@
+package database
+
+import (
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func init() {
+	RegisterDriver(openPostgres, "postgres", "postgresql")
+}
+
+// openPostgres connects with a DSN such as
+// "host=db user=containereye password=secret dbname=containereye sslmode=disable"
+// or "postgres://containereye:secret@db:5432/containereye"
+func openPostgres(dsn string) (gorm.Dialector, error) {
+	return postgres.Open(dsn), nil
+}
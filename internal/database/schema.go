@@ -0,0 +1,394 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The migrations create and change tables through these snapshots of the
+// models as they were when each migration was written, never through the
+// models themselves: a model keeps changing, a released migration must
+// not. A model change that touches the schema needs a migration of its own
+// with a snapshot of what it adds. Serialized fields are plain text columns.
+
+// baselineContainer is the containers table of migration 1. Labels were
+// added by migration 3.
+type baselineContainer struct {
+	gorm.Model
+	HostID              string `gorm:"index"`
+	ContainerID         string `gorm:"uniqueIndex"`
+	Name                string
+	Image               string
+	State               string
+	Status              string
+	Created             time.Time
+	StartedAt           time.Time
+	LastSeen            time.Time
+	Gone                bool
+	RestartCount        int
+	ExitCode            int
+	Health              string
+	HealthFailingStreak int
+	HealthLog           string
+	CPUPercent          float64
+	MemPercent          float64
+}
+
+func (baselineContainer) TableName() string { return "containers" }
+
+// baselineContainerStats is the container_stats table of migration 1.
+// Labels were dropped by migration 10.
+type baselineContainerStats struct {
+	gorm.Model
+	HostID              string `gorm:"index"`
+	ContainerID         string `gorm:"index"`
+	ContainerName       string
+	Image               string
+	Labels              string
+	Timestamp           time.Time
+	CPUPercent          float64
+	CPUSystemUsage      uint64
+	CPUUsage            float64
+	MemoryUsage         uint64
+	MemoryLimit         uint64
+	MemoryPercent       float64
+	NetworkRx           uint64
+	NetworkTx           uint64
+	NetworkTotal        uint64
+	NetworkRxRate       float64
+	NetworkTxRate       float64
+	NetworkRate         float64
+	NetworkRxPacketRate float64
+	NetworkTxPacketRate float64
+	NetworkRxErrorRate  float64
+	NetworkTxErrorRate  float64
+	BlockRead           uint64
+	BlockWrite          uint64
+	DiskIOTotal         uint64
+	BlockReadRate       float64
+	BlockWriteRate      float64
+	DiskIORate          float64
+	PIDs                uint64
+	Health              string
+	HealthFailingStreak int
+	HealthOutput        string
+}
+
+func (baselineContainerStats) TableName() string { return "container_stats" }
+
+type baselineAlert struct {
+	gorm.Model
+	Fingerprint     string `gorm:"index"`
+	RuleID          uint
+	RuleName        string
+	HostID          string `gorm:"index"`
+	ContainerID     string
+	ContainerName   string
+	Image           string
+	Labels          string
+	Metric          string
+	Threshold       float64
+	CurrentValue    float64
+	Level           string
+	Message         string
+	Status          string
+	StartTime       time.Time
+	EndTime         time.Time
+	Value           float64
+	AcknowledgedBy  string
+	AcknowledgedAt  time.Time
+	ResolvedBy      string
+	ResolvedAt      time.Time
+	LastSeenAt      time.Time
+	OccurrenceCount int
+	EscalationStep  int
+	LastEscalatedAt *time.Time
+	SilenceID       *uint `gorm:"index"`
+}
+
+func (baselineAlert) TableName() string { return "alerts" }
+
+type baselineAlertRule struct {
+	gorm.Model
+	Name           string `gorm:"uniqueIndex;not null"`
+	Description    string
+	Host           string
+	ContainerID    string
+	ContainerName  string
+	Image          string
+	ComposeProject string
+	ComposeService string
+	LabelSelector  string
+	Expression     string
+	Event          string
+	EventWindow    int
+	Metric         string  `gorm:"not null"`
+	Operator       string  `gorm:"not null"`
+	Threshold      float64 `gorm:"not null"`
+	Duration       int     `gorm:"not null"`
+	CooldownPeriod int
+	RecoveryPeriod int
+	Level          string `gorm:"not null"`
+	IsEnabled      bool   `gorm:"default:true"`
+	LastTriggered  *time.Time
+	LastChecked    *time.Time
+	TriggerCount   int `gorm:"default:0"`
+	ResolvedCount  int `gorm:"default:0"`
+}
+
+func (baselineAlertRule) TableName() string { return "alert_rules" }
+
+type baselineUser struct {
+	gorm.Model
+	Username string `gorm:"uniqueIndex;not null"`
+	Password string `gorm:"not null"`
+	Role     string `gorm:"not null"`
+	Email    string `gorm:"uniqueIndex"`
+	ApiKey   string `gorm:"uniqueIndex"`
+	IsActive bool   `gorm:"default:true"`
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineNotificationDelivery struct {
+	gorm.Model
+	AlertID       uint `gorm:"index"`
+	Channel       string
+	Recipients    string
+	Event         string
+	Status        string `gorm:"index"`
+	AlertSnapshot string
+	AttemptCount  int
+	NextAttemptAt time.Time `gorm:"index"`
+	LastError     string
+	DeliveredAt   *time.Time
+}
+
+func (baselineNotificationDelivery) TableName() string { return "notification_deliveries" }
+
+type baselineDeliveryAttempt struct {
+	gorm.Model
+	DeliveryID uint `gorm:"index"`
+	Attempt    int
+	Success    bool
+	Error      string
+	Duration   time.Duration
+}
+
+func (baselineDeliveryAttempt) TableName() string { return "delivery_attempts" }
+
+type baselineAlertEscalation struct {
+	gorm.Model
+	AlertID  uint `gorm:"index"`
+	Policy   string
+	Step     int
+	Repeat   int
+	Channels string
+	Users    string
+}
+
+func (baselineAlertEscalation) TableName() string { return "alert_escalations" }
+
+type baselineSilence struct {
+	gorm.Model
+	ContainerName string
+	ContainerID   string
+	Image         string
+	Labels        string
+	RuleName      string
+	Level         string
+	StartsAt      time.Time
+	EndsAt        *time.Time
+	Schedule      string
+	Duration      int
+	Timezone      string
+	CreatedBy     string
+	Comment       string
+}
+
+func (baselineSilence) TableName() string { return "silences" }
+
+type baselineContainerEvent struct {
+	gorm.Model
+	HostID        string `gorm:"index"`
+	ContainerID   string `gorm:"index"`
+	ContainerName string
+	Image         string
+	Type          string `gorm:"index"`
+	ExitCode      *int
+	HealthStatus  string
+	Labels        string
+	Time          time.Time `gorm:"index"`
+}
+
+func (baselineContainerEvent) TableName() string { return "container_events" }
+
+type baselineHost struct {
+	gorm.Model
+	Name      string `gorm:"uniqueIndex;not null"`
+	Endpoint  string
+	TLSCACert string
+	TLSCert   string
+	TLSKey    string
+	Enabled   bool
+	Push      bool
+	Static    bool
+	Status    string
+	LastError string
+	LastSeen  *time.Time
+}
+
+func (baselineHost) TableName() string { return "hosts" }
+
+// metricSummary is a metric's columns in a stats rollup
+type metricSummary struct {
+	Min float64
+	Avg float64
+	Max float64
+	P95 float64
+}
+
+// statsRollupV2 is the stats_rollups table of migration 2
+type statsRollupV2 struct {
+	ID            uint   `gorm:"primarykey"`
+	HostID        string `gorm:"index"`
+	ContainerID   string `gorm:"uniqueIndex:idx_stats_rollup_bucket"`
+	ContainerName string
+	Image         string
+	Resolution    int       `gorm:"uniqueIndex:idx_stats_rollup_bucket;index"`
+	Timestamp     time.Time `gorm:"uniqueIndex:idx_stats_rollup_bucket;index"`
+	Samples       int
+
+	CPUPercent     metricSummary `gorm:"embedded;embeddedPrefix:cpu_percent_"`
+	MemoryUsage    metricSummary `gorm:"embedded;embeddedPrefix:memory_usage_"`
+	MemoryPercent  metricSummary `gorm:"embedded;embeddedPrefix:memory_percent_"`
+	NetworkRxRate  metricSummary `gorm:"embedded;embeddedPrefix:network_rx_rate_"`
+	NetworkTxRate  metricSummary `gorm:"embedded;embeddedPrefix:network_tx_rate_"`
+	NetworkRate    metricSummary `gorm:"embedded;embeddedPrefix:network_rate_"`
+	BlockReadRate  metricSummary `gorm:"embedded;embeddedPrefix:block_read_rate_"`
+	BlockWriteRate metricSummary `gorm:"embedded;embeddedPrefix:block_write_rate_"`
+	DiskIORate     metricSummary `gorm:"embedded;embeddedPrefix:disk_io_rate_"`
+	PIDs           metricSummary `gorm:"embedded;embeddedPrefix:pids_"`
+
+	MemoryLimit  uint64
+	NetworkRx    uint64
+	NetworkTx    uint64
+	NetworkTotal uint64
+	BlockRead    uint64
+	BlockWrite   uint64
+	DiskIOTotal  uint64
+
+	CompactedThrough time.Time `gorm:"index"`
+}
+
+func (statsRollupV2) TableName() string { return "stats_rollups" }
+
+// containerLabelsV3 is the column added to containers by migration 3
+type containerLabelsV3 struct {
+	Labels string
+}
+
+func (containerLabelsV3) TableName() string { return "containers" }
+
+// legacyUserAPIKey is the api_key column of users created before migration 4
+type legacyUserAPIKey struct {
+	ApiKey string `gorm:"uniqueIndex"`
+}
+
+func (legacyUserAPIKey) TableName() string { return "users" }
+
+// userLoginV4 is the column added to users by migration 4
+type userLoginV4 struct {
+	LastLoginAt *time.Time
+}
+
+func (userLoginV4) TableName() string { return "users" }
+
+type apiKeyV5 struct {
+	gorm.Model
+	UserID     uint   `gorm:"index;not null"`
+	Name       string `gorm:"not null"`
+	Prefix     string `gorm:"not null"`
+	Hash       string `gorm:"uniqueIndex;not null"`
+	Scope      string `gorm:"not null"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+}
+
+func (apiKeyV5) TableName() string { return "api_keys" }
+
+type rolePermissionsV6 struct {
+	Role        string `gorm:"primaryKey"`
+	Permissions string
+	UpdatedAt   time.Time
+}
+
+func (rolePermissionsV6) TableName() string { return "role_permissions" }
+
+// userScopeV6 are the columns added to users by migration 6
+type userScopeV6 struct {
+	Hosts         string
+	LabelSelector string
+}
+
+func (userScopeV6) TableName() string { return "users" }
+
+type auditEntryV7 struct {
+	gorm.Model
+	UserID     *uint  `gorm:"index"`
+	Username   string `gorm:"index"`
+	APIKeyID   *uint
+	APIKeyName string
+	Action     string `gorm:"index;not null"`
+	TargetType string `gorm:"index"`
+	TargetID   string `gorm:"index"`
+	Changes    string
+	Detail     string
+	SourceIP   string
+}
+
+func (auditEntryV7) TableName() string { return "audit_entries" }
+
+type refreshTokenV8 struct {
+	gorm.Model
+	UserID    uint      `gorm:"index;not null"`
+	SessionID string    `gorm:"index;not null"`
+	Hash      string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"index"`
+	UsedAt    *time.Time
+}
+
+func (refreshTokenV8) TableName() string { return "refresh_tokens" }
+
+type revokedTokenV8 struct {
+	ID        string    `gorm:"primaryKey"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
+
+func (revokedTokenV8) TableName() string { return "revoked_tokens" }
+
+type leaseV9 struct {
+	Name      string    `gorm:"primaryKey"`
+	Owner     string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+}
+
+func (leaseV9) TableName() string { return "leases" }
+
+// deliveryClaimV9 are the columns added to notification_deliveries by
+// migration 9
+type deliveryClaimV9 struct {
+	ClaimedBy  string
+	LeaseUntil *time.Time
+}
+
+func (deliveryClaimV9) TableName() string { return "notification_deliveries" }
+
+// statsLabelsV10 is the column dropped from container_stats by migration 10
+type statsLabelsV10 struct {
+	Labels string
+}
+
+func (statsLabelsV10) TableName() string { return "container_stats" }
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func init() {
+	RegisterDriver(openSQLite, "sqlite", "sqlite3")
+}
+
+// openSQLite opens the database file at dsn, creating its directory
+func openSQLite(dsn string) (gorm.Dialector, error) {
+	if err := os.MkdirAll(filepath.Dir(dsn), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %v", err)
+	}
+	return sqlite.Open(dsn), nil
+}
@@ -0,0 +1,116 @@
+// Package logging configures where and how the server logs
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Config selects the log level, format and destination
+type Config struct {
+	Level  string `mapstructure:"level"`  // debug, info, warn or error
+	Format string `mapstructure:"format"` // text or json
+	Output string `mapstructure:"output"` // stdout, stderr or a file path
+}
+
+// DefaultConfig returns the logging used when none is configured
+func DefaultConfig() Config {
+	return Config{Level: "info", Format: "text", Output: "stdout"}
+}
+
+var levels = map[string]slog.Level{
+	"debug":   slog.LevelDebug,
+	"info":    slog.LevelInfo,
+	"warn":    slog.LevelWarn,
+	"warning": slog.LevelWarn,
+	"error":   slog.LevelError,
+}
+
+// Validate checks the level and format
+func (c Config) Validate() error {
+	if _, ok := levels[strings.ToLower(c.Level)]; !ok {
+		return fmt.Errorf("logging level must be debug, info, warn or error")
+	}
+	switch strings.ToLower(c.Format) {
+	case "text", "json":
+	default:
+		return fmt.Errorf("logging format must be text or json")
+	}
+	if c.Output == "" {
+		return fmt.Errorf("logging output is required")
+	}
+	return nil
+}
+
+// Setup sends the standard logger through a handler of the configured
+// format and level. It returns a writer for other libraries' log lines,
+// such as the HTTP request log, which are logged at info level.
+func Setup(c Config) (io.Writer, error) {
+	var out io.Writer
+	switch c.Output {
+	case "stdout":
+		out = os.Stdout
+	case "stderr":
+		out = os.Stderr
+	default:
+		f, err := os.OpenFile(c.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %v", err)
+		}
+		out = f
+	}
+
+	opts := &slog.HandlerOptions{Level: levels[strings.ToLower(c.Level)]}
+	var handler slog.Handler = slog.NewTextHandler(out, opts)
+	if strings.ToLower(c.Format) == "json" {
+		handler = slog.NewJSONHandler(out, opts)
+	}
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+
+	// The code logs through the standard logger, its lines are leveled by
+	// how they start, e.g. "Error ..." or "Warning: ..."
+	log.SetFlags(0)
+	log.SetOutput(lineWriter{logger: logger, leveled: true})
+
+	return lineWriter{logger: logger}, nil
+}
+
+// lineWriter logs every line written to it
+type lineWriter struct {
+	logger  *slog.Logger
+	leveled bool
+}
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		level := slog.LevelInfo
+		if w.leveled {
+			level = levelOf(line)
+		}
+		w.logger.Log(context.Background(), level, line)
+	}
+	return len(p), nil
+}
+
+// levelOf guesses the level of a log line from its first word
+func levelOf(line string) slog.Level {
+	lower := strings.ToLower(line)
+	switch {
+	case strings.HasPrefix(lower, "error"), strings.HasPrefix(lower, "failed"):
+		return slog.LevelError
+	case strings.HasPrefix(lower, "warning"):
+		return slog.LevelWarn
+	case strings.HasPrefix(lower, "debug"):
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
@@ -24,10 +24,14 @@ const (
 
 type Alert struct {
 	gorm.Model
+	Fingerprint     string      `json:"fingerprint" gorm:"index"` // Identifies the (rule, container) pair the alert belongs to
 	RuleID          uint        `json:"rule_id"`
 	RuleName        string      `json:"rule_name"`
+	HostID          string      `json:"host_id" gorm:"index"`
 	ContainerID     string      `json:"container_id"`
 	ContainerName   string      `json:"container_name"`
+	Image           string      `json:"image,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty" gorm:"serializer:json"`
 	Metric          string      `json:"metric"`
 	Threshold       float64     `json:"threshold"`
 	CurrentValue    float64     `json:"current_value"`
@@ -41,4 +45,25 @@ type Alert struct {
 	AcknowledgedAt  time.Time   `json:"acknowledged_at,omitempty"`
 	ResolvedBy      string      `json:"resolved_by,omitempty"`
 	ResolvedAt      time.Time   `json:"resolved_at,omitempty"`
+	LastSeenAt      time.Time   `json:"last_seen_at"`      // Last time the condition was observed violating
+	OccurrenceCount int         `json:"occurrence_count"`  // Number of evaluations that observed the violation
+	EscalationStep  int         `json:"escalation_step"`   // Number of escalation policy steps fired so far
+	LastEscalatedAt *time.Time  `json:"last_escalated_at,omitempty"`
+	SilenceID       *uint       `json:"silence_id,omitempty" gorm:"index"` // Silence that suppressed the alert's notifications
+}
+
+// AlertEscalation records an escalation step that fired for an alert
+type AlertEscalation struct {
+	gorm.Model
+	AlertID  uint   `json:"alert_id" gorm:"index"`
+	Policy   string `json:"policy"`
+	Step     int    `json:"step"`   // 1-based index of the step in the policy
+	Repeat   int    `json:"repeat"` // 0 for the first firing, then incremented per repeat
+	Channels string `json:"channels"`
+	Users    string `json:"users"`
+}
+
+// IsOpen reports whether the alert has not been resolved yet
+func (a *Alert) IsOpen() bool {
+	return a.Status != AlertStatusResolved
 }
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKey authenticates automation and the CLI as its user. Only the SHA-256
+// hash of the key is stored; Prefix holds its first characters so that a
+// key can be recognized in listings. A key acts with its Scope, the role it
+// is limited to, or the user's role if that is lower. Revoked keys are
+// soft deleted.
+type APIKey struct {
+	gorm.Model
+	UserID     uint       `json:"user_id" gorm:"index;not null"`
+	Username   string     `json:"username" gorm:"-"`
+	Name       string     `json:"name" gorm:"not null"`
+	Prefix     string     `json:"prefix" gorm:"not null"`
+	Hash       string     `json:"-" gorm:"uniqueIndex;not null"`
+	Scope      Role       `json:"scope" gorm:"not null"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Expired reports whether the key has expired at now
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
@@ -0,0 +1,27 @@
+package models
+
+import "gorm.io/gorm"
+
+// AuditEntry records a change made through the API: who made it, from
+// where, and how the target changed. Failed logins are recorded as well,
+// with the attempted username and the reason in Detail.
+type AuditEntry struct {
+	gorm.Model
+	UserID     *uint                  `json:"user_id,omitempty" gorm:"index"`
+	Username   string                 `json:"username" gorm:"index"`
+	APIKeyID   *uint                  `json:"api_key_id,omitempty"`
+	APIKeyName string                 `json:"api_key_name,omitempty"`
+	Action     string                 `json:"action" gorm:"index;not null"` // e.g. rule.disable or alert.resolve
+	TargetType string                 `json:"target_type" gorm:"index"`
+	TargetID   string                 `json:"target_id" gorm:"index"`
+	Changes    map[string]AuditChange `json:"changes,omitempty" gorm:"serializer:json"` // Changed fields by JSON name
+	Detail     string                 `json:"detail,omitempty"`
+	SourceIP   string                 `json:"source_ip"`
+}
+
+// AuditChange is the value of a field before and after a change, nil when
+// the field was not set
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
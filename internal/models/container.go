@@ -9,14 +9,22 @@ import (
 // Container represents a Docker container
 type Container struct {
 	gorm.Model
+	HostID        string `json:"host_id" gorm:"index"`
 	ContainerID   string `gorm:"uniqueIndex" json:"id"`
 	Name          string `json:"name"`
 	Image         string `json:"image"`
+	Labels        map[string]string `json:"labels,omitempty" gorm:"serializer:json"`
 	State         string `json:"state"`
 	Status        string `json:"status"`
 	Created       time.Time `json:"created"`
+	StartedAt     time.Time `json:"started_at"`
 	LastSeen      time.Time `json:"last_seen"`
+	Gone          bool `json:"gone"` // Removed from Docker, kept for its history
 	RestartCount  int `json:"restart_count"`
+	ExitCode      int `json:"exit_code"`
+	Health        string `json:"health,omitempty"` // Health check status, empty without a health check
+	HealthFailingStreak int `json:"health_failing_streak"`
+	HealthLog     []HealthProbe `json:"health_log,omitempty" gorm:"serializer:json"` // Most recent probes, oldest first
 	CPUPercent    float64 `json:"cpu_percent"`
 	MemPercent    float64 `json:"mem_percent"`
 	LastStats     ContainerStats `gorm:"foreignKey:ContainerID;references:ContainerID" json:"last_stats"`
@@ -26,8 +34,13 @@ type Container struct {
 // ContainerStats represents container resource usage statistics
 type ContainerStats struct {
 	gorm.Model
+	HostID        string    `json:"host_id" gorm:"index"`
 	ContainerID   string    `json:"container_id" gorm:"index"`
 	ContainerName string    `json:"container_name"`
+	Image         string    `json:"image"`
+	// Labels select the container for rules and silences. They are kept
+	// in the inventory, not with every sample, and the column is unused.
+	Labels        map[string]string `json:"labels,omitempty" gorm:"-"`
 	Timestamp     time.Time `json:"timestamp"`
 	
 	// CPU Statistics
@@ -44,12 +57,40 @@ type ContainerStats struct {
 	NetworkRx    uint64 `json:"network_rx"`     // Network received bytes
 	NetworkTx    uint64 `json:"network_tx"`     // Network transmitted bytes
 	NetworkTotal uint64 `json:"network_total"`  // Total network I/O
+
+	// Network Rates (per second, derived from the previous sample)
+	NetworkRxRate       float64 `json:"network_rx_rate"`        // Received bytes/s
+	NetworkTxRate       float64 `json:"network_tx_rate"`        // Transmitted bytes/s
+	NetworkRate         float64 `json:"network_rate"`           // Total network bytes/s
+	NetworkRxPacketRate float64 `json:"network_rx_packet_rate"` // Received packets/s
+	NetworkTxPacketRate float64 `json:"network_tx_packet_rate"` // Transmitted packets/s
+	NetworkRxErrorRate  float64 `json:"network_rx_error_rate"`  // Receive errors/s
+	NetworkTxErrorRate  float64 `json:"network_tx_error_rate"`  // Transmit errors/s
 	
 	// Disk I/O Statistics
 	BlockRead    uint64 `json:"block_read"`    // Block IO read bytes
 	BlockWrite   uint64 `json:"block_write"`   // Block IO write bytes
 	DiskIOTotal  uint64 `json:"disk_io_total"` // Total disk I/O
+
+	// Disk I/O Rates (per second, derived from the previous sample)
+	BlockReadRate  float64 `json:"block_read_rate"`  // Block IO read bytes/s
+	BlockWriteRate float64 `json:"block_write_rate"` // Block IO write bytes/s
+	DiskIORate     float64 `json:"disk_io_rate"`     // Total disk I/O bytes/s
 	
 	// Process Statistics
 	PIDs         uint64 `json:"pids"`          // Number of processes
+
+	// Health Check, empty without a HEALTHCHECK
+	Health              string `json:"health,omitempty"`       // starting, healthy or unhealthy
+	HealthFailingStreak int    `json:"health_failing_streak"`  // Consecutive failed probes
+	HealthOutput        string `json:"health_output,omitempty"` // Output of the last probe
+	HealthLog           []HealthProbe `json:"health_log,omitempty" gorm:"-"` // Passed on to the inventory, not stored with the stats
+}
+
+// HealthProbe is the result of one health check probe
+type HealthProbe struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	ExitCode int       `json:"exit_code"`
+	Output   string    `json:"output"`
 }
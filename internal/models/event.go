@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EventType is a container lifecycle event recorded from the Docker events API
+type EventType string
+
+const (
+	EventStart        EventType = "start"
+	EventStop         EventType = "stop"
+	EventDie          EventType = "die"
+	EventOOM          EventType = "oom"
+	EventRestart      EventType = "restart" // Restart by the restart policy or "docker restart"
+	EventHealthStatus EventType = "health_status"
+)
+
+// ContainerEvent is a lifecycle event of a container
+type ContainerEvent struct {
+	gorm.Model
+	HostID        string            `json:"host_id" gorm:"index"`
+	ContainerID   string            `json:"container_id" gorm:"index"`
+	ContainerName string            `json:"container_name"`
+	Image         string            `json:"image"`
+	Type          EventType         `json:"type" gorm:"index"`
+	ExitCode      *int              `json:"exit_code,omitempty"`                     // Set for die events
+	HealthStatus  string            `json:"health_status,omitempty"`                 // Set for health_status events
+	Labels        map[string]string `json:"labels,omitempty" gorm:"serializer:json"` // Container labels at the time of the event
+	Time          time.Time         `json:"time" gorm:"index"`
+}
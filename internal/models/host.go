@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LocalHostID is the host of the Docker daemon configured through the
+// DOCKER_* environment, monitored when no hosts are configured
+const LocalHostID = "local"
+
+// HostStatus is the connection state of a monitored Docker host
+type HostStatus string
+
+const (
+	HostStatusUnknown HostStatus = ""
+	HostStatusUp      HostStatus = "UP"
+	HostStatusDown    HostStatus = "DOWN"
+)
+
+// Host is a Docker endpoint monitored by the server. Its Name is the host ID
+// stored on the stats, containers and alerts collected from it.
+//
+// Endpoint is a Docker host URL: unix:///var/run/docker.sock,
+// tcp://host:2376 (with the TLS files for a TLS protected daemon) or
+// ssh://user@host, which runs "docker system dial-stdio" over ssh. An empty
+// endpoint uses the DOCKER_* environment.
+type Host struct {
+	gorm.Model
+	Name      string     `json:"name" gorm:"uniqueIndex;not null"`
+	Endpoint  string     `json:"endpoint"`
+	TLSCACert string     `json:"tls_ca_cert,omitempty"` // Path of the CA certificate verifying the daemon
+	TLSCert   string     `json:"tls_cert,omitempty"`    // Path of the client certificate
+	TLSKey    string     `json:"tls_key,omitempty"`     // Path of the client key
+	Enabled   bool       `json:"enabled"`
+	Push      bool       `json:"push"`   // Stats are pushed by an agent, the server does not connect
+	Static    bool       `json:"static"` // Defined in the config file, read-only through the API
+	Status    HostStatus `json:"status"`
+	LastError string     `json:"last_error,omitempty"`
+	LastSeen  *time.Time `json:"last_seen,omitempty"` // Last successful collection
+}
+
+// IngestBatch is a collection pushed by an agent: the stats of the running
+// containers and the inventory of all containers on the agent's host
+type IngestBatch struct {
+	Host        string            `json:"host"`
+	CollectedAt time.Time         `json:"collected_at"`
+	Stats       []*ContainerStats `json:"stats"`
+	Containers  []Container       `json:"containers"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending DeliveryStatus = "PENDING" // Waiting for its next attempt
+	DeliveryStatusSending DeliveryStatus = "SENDING" // Claimed by a worker until its lease expires
+	DeliveryStatusSent    DeliveryStatus = "SENT"
+	DeliveryStatusDead    DeliveryStatus = "DEAD" // Gave up after the maximum number of attempts
+)
+
+type DeliveryEvent string
+
+const (
+	DeliveryEventFiring   DeliveryEvent = "firing"
+	DeliveryEventResolved DeliveryEvent = "resolved"
+)
+
+// NotificationDelivery is an outbox entry: one notification of an alert to
+// one channel, retried until it is sent or dead-lettered.
+type NotificationDelivery struct {
+	gorm.Model
+	AlertID       uint              `json:"alert_id" gorm:"index"`
+	Channel       string            `json:"channel"`
+	Recipients    string            `json:"recipients,omitempty"` // Comma separated, overrides the channel's own recipients
+	Event         DeliveryEvent     `json:"event"`
+	Status        DeliveryStatus    `json:"status" gorm:"index"`
+	AlertSnapshot string            `json:"-"` // Alert as JSON at the time it was queued
+	AttemptCount  int               `json:"attempt_count"`
+	NextAttemptAt time.Time         `json:"next_attempt_at" gorm:"index"`
+	LastError     string            `json:"last_error,omitempty"`
+	DeliveredAt   *time.Time        `json:"delivered_at,omitempty"`
+	ClaimedBy     string            `json:"claimed_by,omitempty"` // Server sending the delivery while SENDING
+	LeaseUntil    *time.Time        `json:"-"`                    // When another server may take a SENDING delivery over
+	Attempts      []DeliveryAttempt `json:"attempts,omitempty" gorm:"foreignKey:DeliveryID"`
+}
+
+// DeliveryAttempt records the outcome of a single send of a delivery
+type DeliveryAttempt struct {
+	gorm.Model
+	DeliveryID uint          `json:"delivery_id" gorm:"index"`
+	Attempt    int           `json:"attempt"`
+	Success    bool          `json:"success"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Permission is an action a role may perform through the API
+type Permission string
+
+const (
+	PermContainersRead Permission = "containers:read" // Inventory, stats, events and queries
+	PermAlertsRead     Permission = "alerts:read"     // Alerts, their deliveries and escalations
+	PermAlertsWrite    Permission = "alerts:write"    // Raise alerts through the API
+	PermAlertsAck      Permission = "alerts:ack"      // Acknowledge and resolve alerts
+	PermSilencesRead   Permission = "silences:read"
+	PermSilencesWrite  Permission = "silences:write"
+	PermHostsRead      Permission = "hosts:read"
+	PermHostsWrite     Permission = "hosts:write"
+	PermStatsIngest    Permission = "stats:ingest" // Push stats as an agent
+	PermRulesRead      Permission = "rules:read"
+	PermRulesWrite     Permission = "rules:write"
+	PermUsersAdmin     Permission = "users:admin" // Users, their API keys and the role permissions
+	PermAuditRead      Permission = "audit:read"
+)
+
+// RolePermissions replaces the default permissions of a role. The admin
+// role always has every permission.
+type RolePermissions struct {
+	Role        Role         `json:"role" gorm:"primaryKey"`
+	Permissions []Permission `json:"permissions" gorm:"serializer:json"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
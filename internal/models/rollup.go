@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+// MetricSummary summarizes the samples of one metric within a rollup bucket
+type MetricSummary struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+	P95 float64 `json:"p95"`
+}
+
+// StatsRollup summarizes the stats of a container over one bucket of
+// Resolution seconds starting at Timestamp. Raw stats are rolled up into
+// 1-minute and 1-hour buckets before they expire, see the retention package.
+type StatsRollup struct {
+	ID            uint      `json:"id" gorm:"primarykey"`
+	HostID        string    `json:"host_id" gorm:"index"`
+	ContainerID   string    `json:"container_id" gorm:"uniqueIndex:idx_stats_rollup_bucket"`
+	ContainerName string    `json:"container_name"`
+	Image         string    `json:"image"`
+	Resolution    int       `json:"resolution" gorm:"uniqueIndex:idx_stats_rollup_bucket;index"` // Bucket size in seconds
+	Timestamp     time.Time `json:"timestamp" gorm:"uniqueIndex:idx_stats_rollup_bucket;index"`  // Start of the bucket
+	Samples       int       `json:"samples"`
+
+	CPUPercent     MetricSummary `json:"cpu_percent" gorm:"embedded;embeddedPrefix:cpu_percent_"`
+	MemoryUsage    MetricSummary `json:"memory_usage" gorm:"embedded;embeddedPrefix:memory_usage_"`
+	MemoryPercent  MetricSummary `json:"memory_percent" gorm:"embedded;embeddedPrefix:memory_percent_"`
+	NetworkRxRate  MetricSummary `json:"network_rx_rate" gorm:"embedded;embeddedPrefix:network_rx_rate_"`
+	NetworkTxRate  MetricSummary `json:"network_tx_rate" gorm:"embedded;embeddedPrefix:network_tx_rate_"`
+	NetworkRate    MetricSummary `json:"network_rate" gorm:"embedded;embeddedPrefix:network_rate_"`
+	BlockReadRate  MetricSummary `json:"block_read_rate" gorm:"embedded;embeddedPrefix:block_read_rate_"`
+	BlockWriteRate MetricSummary `json:"block_write_rate" gorm:"embedded;embeddedPrefix:block_write_rate_"`
+	DiskIORate     MetricSummary `json:"disk_io_rate" gorm:"embedded;embeddedPrefix:disk_io_rate_"`
+	PIDs           MetricSummary `json:"pids" gorm:"embedded;embeddedPrefix:pids_"`
+
+	// Counters and limits as of the last sample in the bucket
+	MemoryLimit  uint64 `json:"memory_limit"`
+	NetworkRx    uint64 `json:"network_rx"`
+	NetworkTx    uint64 `json:"network_tx"`
+	NetworkTotal uint64 `json:"network_total"`
+	BlockRead    uint64 `json:"block_read"`
+	BlockWrite   uint64 `json:"block_write"`
+	DiskIOTotal  uint64 `json:"disk_io_total"`
+
+	// Raw stats created up to this time are included, the compaction
+	// resumes from here
+	CompactedThrough time.Time `json:"-" gorm:"index"`
+}
+
+// Stats returns the bucket as container stats holding the average of each
+// metric, so that rollups can be served where raw stats are expected
+func (r *StatsRollup) Stats() ContainerStats {
+	return ContainerStats{
+		HostID:         r.HostID,
+		ContainerID:    r.ContainerID,
+		ContainerName:  r.ContainerName,
+		Image:          r.Image,
+		Timestamp:      r.Timestamp,
+		CPUPercent:     r.CPUPercent.Avg,
+		MemoryUsage:    uint64(r.MemoryUsage.Avg),
+		MemoryLimit:    r.MemoryLimit,
+		MemoryPercent:  r.MemoryPercent.Avg,
+		NetworkRx:      r.NetworkRx,
+		NetworkTx:      r.NetworkTx,
+		NetworkTotal:   r.NetworkTotal,
+		NetworkRxRate:  r.NetworkRxRate.Avg,
+		NetworkTxRate:  r.NetworkTxRate.Avg,
+		NetworkRate:    r.NetworkRate.Avg,
+		BlockRead:      r.BlockRead,
+		BlockWrite:     r.BlockWrite,
+		DiskIOTotal:    r.DiskIOTotal,
+		BlockReadRate:  r.BlockReadRate.Avg,
+		BlockWriteRate: r.BlockWriteRate.Avg,
+		DiskIORate:     r.DiskIORate.Avg,
+		PIDs:           uint64(r.PIDs.Avg),
+	}
+}
@@ -22,19 +22,48 @@ const (
 	MetricMemoryUsage Metric = "memory_percent"
 	MetricDiskIO      Metric = "disk_io_total"
 	MetricNetworkIO   Metric = "network_total"
+
+	// Rate metrics, computed per second between consecutive samples
+	MetricDiskIORate          Metric = "disk_io_rate"
+	MetricDiskReadRate        Metric = "block_read_rate"
+	MetricDiskWriteRate       Metric = "block_write_rate"
+	MetricNetworkIORate       Metric = "network_rate"
+	MetricNetworkRxRate       Metric = "network_rx_rate"
+	MetricNetworkTxRate       Metric = "network_tx_rate"
+	MetricNetworkRxPacketRate Metric = "network_rx_packet_rate"
+	MetricNetworkTxPacketRate Metric = "network_tx_packet_rate"
+	MetricNetworkRxErrorRate  Metric = "network_rx_error_rate"
+	MetricNetworkTxErrorRate  Metric = "network_tx_error_rate"
+
+	// Health check metrics, zero for containers without a HEALTHCHECK
+	MetricHealthUnhealthy     Metric = "health_unhealthy" // 1 while the container is unhealthy
+	MetricHealthFailingStreak Metric = "health_failing_streak"
 )
 
+// AlertRule checks a metric against a threshold on the containers it
+// targets. Name, image and compose patterns are shell globs, or regular
+// expressions when prefixed with "re:", matching the whole value; empty
+// selectors match any container.
 type AlertRule struct {
 	gorm.Model
 	Name           string    `json:"name" gorm:"uniqueIndex;not null"`
 	Description    string    `json:"description"`
-	ContainerID    string    `json:"container_id"`    // Optional, specific container
+	Host           string    `json:"host"`            // Optional, host name pattern
+	ContainerID    string    `json:"container_id"`    // Optional, specific container (full or short ID)
 	ContainerName  string    `json:"container_name"`  // Optional, container name pattern
+	Image          string    `json:"image"`           // Optional, image reference pattern
+	ComposeProject string    `json:"compose_project"` // Optional, Docker Compose project pattern
+	ComposeService string    `json:"compose_service"` // Optional, Docker Compose service pattern
+	LabelSelector  string    `json:"label_selector"`  // Optional, e.g. "env=prod,tier in (web,api)"
+	Expression     string    `json:"expression,omitempty"` // Optional, condition used instead of metric/operator/threshold
+	Event          EventType `json:"event,omitempty"`        // Optional, counts these container events instead of reading a metric
+	EventWindow    int       `json:"event_window,omitempty"` // In seconds, how far back events are counted
 	Metric         Metric    `json:"metric" gorm:"not null"`
 	Operator       Operator  `json:"operator" gorm:"not null"`
 	Threshold      float64   `json:"threshold" gorm:"not null"`
 	Duration       int       `json:"duration" gorm:"not null"` // In seconds
 	CooldownPeriod int       `json:"cooldown_period"` // In seconds, minimum time between alerts
+	RecoveryPeriod int       `json:"recovery_period"` // In seconds, how long the condition must clear before auto-resolving
 	Level          AlertLevel `json:"level" gorm:"not null"`
 	IsEnabled      bool      `json:"is_enabled" gorm:"default:true"`
 	LastTriggered  *time.Time `json:"last_triggered"`
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Series is the aggregated metric of one group. Values are aligned with the
+// result's timestamps and null for steps without stats.
+type Series struct {
+	Labels map[string]string `json:"labels"`
+	Values []*float64        `json:"values"`
+}
+
+// SeriesResult is the answer to a series query, see GET /api/v1/query
+type SeriesResult struct {
+	Metric      string      `json:"metric"`
+	Aggregation string      `json:"aggregation"`
+	GroupBy     string      `json:"group_by"`
+	Resolution  string      `json:"resolution"`
+	Step        int64       `json:"step"` // In seconds
+	Timestamps  []time.Time `json:"timestamps"`
+	Series      []Series    `json:"series"`
+}
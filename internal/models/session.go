@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken renews the access tokens of a login session. Refresh tokens
+// rotate: each one is used once, and presenting a used token again revokes
+// its session, as it was likely stolen. Only the SHA-256 hash of the token
+// is stored. Tokens of revoked sessions are soft deleted.
+type RefreshToken struct {
+	gorm.Model
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	SessionID string     `json:"session_id" gorm:"index;not null"`
+	Hash      string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// RevokedToken is the ID of an access token, or of a session whose access
+// tokens are all revoked. It is kept until ExpiresAt, when the tokens it
+// covers have expired anyway.
+type RevokedToken struct {
+	ID        string    `gorm:"primaryKey"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
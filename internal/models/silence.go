@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Silence suppresses notifications for the alerts it matches while it is
+// active. Matched alerts are still recorded and carry the silence's ID.
+//
+// Pattern fields use shell glob syntax (e.g. "web-*"); empty fields match
+// anything, but at least one matcher must be set. A silence with a Schedule
+// is a recurring maintenance window: it is active for Duration seconds each
+// time the cron expression fires, between StartsAt and EndsAt.
+type Silence struct {
+	gorm.Model
+	ContainerName string            `json:"container_name,omitempty"`                // Glob matched against the name, with or without leading "/"
+	ContainerID   string            `json:"container_id,omitempty"`                  // Glob or ID prefix
+	Image         string            `json:"image,omitempty"`                         // Glob matched against the image reference
+	Labels        map[string]string `json:"labels,omitempty" gorm:"serializer:json"` // All labels must match, values are globs
+	RuleName      string            `json:"rule_name,omitempty"`                     // Glob matched against the rule name
+	Level         AlertLevel        `json:"level,omitempty"`
+	StartsAt      time.Time         `json:"starts_at"`
+	EndsAt        *time.Time        `json:"ends_at,omitempty"`  // Required unless Schedule is set
+	Schedule      string            `json:"schedule,omitempty"` // Cron expression opening a maintenance window
+	Duration      int               `json:"duration,omitempty"` // Length of each maintenance window in seconds
+	Timezone      string            `json:"timezone,omitempty"` // Location the schedule is evaluated in, defaults to local time
+	CreatedBy     string            `json:"created_by"`
+	Comment       string            `json:"comment"`
+}
+
+// IsRecurring reports whether the silence is a scheduled maintenance window
+func (s *Silence) IsRecurring() bool {
+	return s.Schedule != ""
+}
+
+// Expired reports whether the silence can no longer become active
+func (s *Silence) Expired(now time.Time) bool {
+	return s.EndsAt != nil && !now.Before(*s.EndsAt)
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -11,16 +13,66 @@ const (
 	RoleAdmin  Role = "admin"
 	RoleUser   Role = "user"
 	RoleViewer Role = "viewer"
+
+	// RoleAgent is only a scope of API keys, limiting push agents to
+	// ingesting stats. No user has this role.
+	RoleAgent Role = "agent"
 )
 
+// Valid reports whether r is one of the known roles
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleUser, RoleViewer:
+		return true
+	}
+	return false
+}
+
+// ValidScope reports whether r is a role or the agent scope
+func (r Role) ValidScope() bool {
+	return r.Valid() || r == RoleAgent
+}
+
+// Includes reports whether r grants at least the privileges of other
+func (r Role) Includes(other Role) bool {
+	return r.rank() >= other.rank()
+}
+
+func (r Role) rank() int {
+	switch r {
+	case RoleAdmin:
+		return 3
+	case RoleUser:
+		return 2
+	case RoleViewer:
+		return 1
+	}
+	return 0
+}
+
+// User is an account of the API. Email is optional and, when set, unique.
+//
+// Hosts and LabelSelector restrict the user to the containers of the hosts
+// matching one of the patterns and to the containers whose labels match the
+// selector; either applies only when set.
 type User struct {
 	gorm.Model
-	Username     string `gorm:"uniqueIndex;not null" json:"username"`
-	Password     string `gorm:"not null" json:"-"`
-	Role         Role   `gorm:"not null" json:"role"`
-	Email        string `gorm:"uniqueIndex" json:"email"`
-	ApiKey       string `gorm:"uniqueIndex" json:"-"`
-	IsActive     bool   `gorm:"default:true" json:"is_active"`
+	Username      string     `gorm:"uniqueIndex;not null" json:"username"`
+	Password      string     `gorm:"not null" json:"-"`
+	Role          Role       `gorm:"not null" json:"role"`
+	Email         *string    `gorm:"uniqueIndex" json:"email,omitempty"`
+	IsActive      bool       `gorm:"default:true" json:"is_active"`
+	LastLoginAt   *time.Time `json:"last_login_at,omitempty"`
+	Hosts         []string   `gorm:"serializer:json" json:"hosts,omitempty"`
+	LabelSelector string     `json:"label_selector,omitempty"`
+}
+
+// EmailAddress returns the user's email address, empty when none is set
+func (u *User) EmailAddress() string {
+	if u.Email == nil {
+		return ""
+	}
+	return *u.Email
 }
 
 func (u *User) SetPassword(password string) error {
@@ -36,16 +88,3 @@ func (u *User) CheckPassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 	return err == nil
 }
-
-func (u *User) HasPermission(action string) bool {
-	switch u.Role {
-	case RoleAdmin:
-		return true
-	case RoleUser:
-		return action != "manage_users" && action != "system_config"
-	case RoleViewer:
-		return action == "view_containers" || action == "view_alerts"
-	default:
-		return false
-	}
-}
@@ -3,9 +3,12 @@ package monitor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"containereye/internal/alert"
@@ -18,25 +21,34 @@ import (
 	"gorm.io/gorm"
 )
 
-const (
-	maxConcurrentCollections = 10
-	maxBatchSize            = 100
-	retryAttempts          = 3
-	retryDelay             = 5 * time.Second
-)
+const maxCollectBackoff = 10 * time.Minute
 
+// Collector polls the containers of one Docker host
 type Collector struct {
+	hostID       string
 	dockerClient *client.Client
+	events      *EventWatcher
 	ctx         context.Context
 	ruleManager *alert.RuleManager
-	interval    time.Duration
+	config      Config
 	mutex       sync.RWMutex
 	containers  map[string]*models.ContainerStats
+	samples     map[string]*counterSample
+	samplesMutex sync.Mutex
+	known       map[string]models.Container // Inventory rows as of the last collection
+	inventoryMutex sync.Mutex
 	stopChan    chan struct{}
 	sem         *semaphore.Weighted
 	metrics     *CollectorMetrics
+	leading     atomic.Bool // Holds the host's collector lease
+	lastBatch   time.Time   // Collection time of the last pushed batch applied
+	pushMutex   sync.Mutex  // Orders the inventory updates of pushed batches
 }
 
+// errHostUnreachable marks collection errors caused by the Docker daemon
+// not answering, which back off the collection loop
+var errHostUnreachable = errors.New("host unreachable")
+
 type CollectorMetrics struct {
 	mutex               sync.RWMutex
 	totalCollections    uint64
@@ -45,52 +57,115 @@ type CollectorMetrics struct {
 	batchSize          int
 }
 
-func NewCollector(ruleManager *alert.RuleManager, interval time.Duration) (*Collector, error) {
-	ctx := context.Background()
-	cli, err := client.NewClientWithOpts(client.FromEnv)
+func NewCollector(host *models.Host, ruleManager *alert.RuleManager, config Config) (*Collector, error) {
+	cli, err := newDockerClient(host)
 	if err != nil {
 		return nil, err
 	}
 	
+	c := newCollector(host.Name, ruleManager, config)
+	c.dockerClient = cli
+	c.events = NewEventWatcher(host.Name, cli, ruleManager)
+	c.events.changed = c.forgetInventory
+	c.events.active = c.leading.Load
+	return c, nil
+}
+
+func newCollector(hostID string, ruleManager *alert.RuleManager, config Config) *Collector {
 	return &Collector{
-		dockerClient: cli,
-		ctx:         ctx,
+		hostID:      hostID,
+		ctx:         context.Background(),
 		ruleManager: ruleManager,
-		interval:    interval,
+		config:      config,
 		containers:  make(map[string]*models.ContainerStats),
+		samples:     make(map[string]*counterSample),
+		known:       make(map[string]models.Container),
 		stopChan:    make(chan struct{}),
-		sem:         semaphore.NewWeighted(maxConcurrentCollections),
-		metrics:     &CollectorMetrics{batchSize: maxBatchSize},
-	}, nil
+		sem:         semaphore.NewWeighted(int64(config.MaxConcurrent)),
+		metrics:     &CollectorMetrics{batchSize: config.BatchSize},
+	}
 }
 
-func (c *Collector) Start() error {
-	ticker := time.NewTicker(c.interval)
-	defer ticker.Stop()
-
-	// Initial collection
-	if err := c.collect(); err != nil {
-		return err
-	}
+// Start collects in the background every interval. While the host cannot
+// be reached, collections are retried with an exponential backoff. When
+// several servers share the database, only the one holding the host's
+// collector lease collects and records its events; the others take over
+// once it stops renewing the lease.
+func (c *Collector) Start() {
+	// Subscribe first so nothing that happens during the first collection is missed
+	c.events.Start()
 
 	go func() {
+		lease := "collector:" + c.hostID
+		delay, backoff := time.Duration(0), c.config.Interval
 		for {
 			select {
-			case <-ticker.C:
-				if err := c.collect(); err != nil {
-					fmt.Printf("Error collecting stats: %v\n", err)
-				}
+			case <-time.After(delay):
 			case <-c.stopChan:
+				c.leading.Store(false)
+				if err := database.ReleaseLease(database.GetDB(), lease); err != nil {
+					log.Printf("Error releasing the collector lease of host %s: %v", c.hostID, err)
+				}
 				return
 			}
+
+			held, err := database.AcquireLease(database.GetDB(), lease, 3*c.config.Interval)
+			if err != nil {
+				log.Printf("Error acquiring the collector lease of host %s: %v", c.hostID, err)
+			}
+			c.leading.Store(held)
+			if !held {
+				delay, backoff = c.config.Interval, c.config.Interval
+				continue
+			}
+
+			err = c.collect()
+			c.updateHostStatus(err)
+			if errors.Is(err, errHostUnreachable) {
+				log.Printf("Host %s is unreachable, retrying in %s: %v", c.hostID, backoff, err)
+				delay = backoff
+				if backoff *= 2; backoff > maxCollectBackoff {
+					backoff = maxCollectBackoff
+				}
+				continue
+			}
+			if err != nil {
+				log.Printf("Error collecting stats on host %s: %v", c.hostID, err)
+			}
+			delay, backoff = c.config.Interval, c.config.Interval
 		}
 	}()
-
-	return nil
 }
 
 func (c *Collector) Stop() {
 	close(c.stopChan)
+	// Collectors of push hosts have no Docker connection
+	if c.dockerClient == nil {
+		return
+	}
+	c.events.Stop()
+	c.dockerClient.Close()
+}
+
+// HostID returns the name of the host the collector monitors
+func (c *Collector) HostID() string {
+	return c.hostID
+}
+
+// updateHostStatus records whether the host could be reached
+func (c *Collector) updateHostStatus(err error) {
+	updates := map[string]interface{}{"status": models.HostStatusUp, "last_error": ""}
+	if err != nil {
+		updates["last_error"] = err.Error()
+	}
+	if errors.Is(err, errHostUnreachable) {
+		updates["status"] = models.HostStatusDown
+	} else {
+		updates["last_seen"] = time.Now()
+	}
+	if dbErr := database.GetDB().Model(&models.Host{}).Where("name = ?", c.hostID).Updates(updates).Error; dbErr != nil {
+		log.Printf("Error updating status of host %s: %v", c.hostID, dbErr)
+	}
 }
 
 func (c *Collector) collect() error {
@@ -101,14 +176,26 @@ func (c *Collector) collect() error {
 		c.metrics.mutex.Unlock()
 	}()
 
-	containers, err := c.dockerClient.ContainerList(c.ctx, types.ContainerListOptions{})
+	// Stopped containers are listed too for the inventory
+	all, err := c.dockerClient.ContainerList(c.ctx, types.ContainerListOptions{All: true})
 	if err != nil {
 		c.metrics.mutex.Lock()
 		c.metrics.failedCollections++
 		c.metrics.mutex.Unlock()
-		return fmt.Errorf("failed to list containers: %v", err)
+		return fmt.Errorf("%w: failed to list containers: %v", errHostUnreachable, err)
 	}
 
+	containers := make([]types.Container, 0, len(all))
+	for _, container := range all {
+		if container.State == "running" {
+			containers = append(containers, container)
+		}
+	}
+
+	// Forget rate baselines and stats of containers that have gone away
+	c.pruneSamples(containers)
+	c.pruneContainers(containers)
+
 	// Create batches of containers
 	batches := make([][]types.Container, 0)
 	for i := 0; i < len(containers); i += c.metrics.batchSize {
@@ -141,6 +228,7 @@ func (c *Collector) collect() error {
 					errChan <- fmt.Errorf("error collecting stats for container %s: %v", container.ID, err)
 					continue
 				}
+				applyListInfo(stat, container)
 				stats = append(stats, stat)
 			}
 
@@ -169,13 +257,19 @@ func (c *Collector) collect() error {
 	close(errChan)
 
 	// Collect all errors
-	var errors []error
+	var errs []error
 	for err := range errChan {
-		errors = append(errors, err)
+		errs = append(errs, err)
 	}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("collection errors: %v", errors)
+	if gone, err := storeInventory(c.hostID, c.inventory(all)); err != nil {
+		errs = append(errs, fmt.Errorf("error updating container inventory: %v", err))
+	} else if err := c.ruleManager.CloseContainerAlerts(gone); err != nil {
+		errs = append(errs, fmt.Errorf("error resolving alerts of gone containers: %v", err))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("collection errors: %v", errs)
 	}
 
 	c.metrics.mutex.Lock()
@@ -190,15 +284,15 @@ func (c *Collector) collect() error {
 
 func (c *Collector) collectContainerStatsWithRetry(containerID string) (*models.ContainerStats, error) {
 	var lastErr error
-	for attempt := 0; attempt < retryAttempts; attempt++ {
+	for attempt := 0; attempt < c.config.RetryAttempts; attempt++ {
 		stats, err := c.collectContainerStats(containerID)
 		if err == nil {
 			return stats, nil
 		}
 		lastErr = err
-		time.Sleep(retryDelay)
+		time.Sleep(c.config.RetryDelay)
 	}
-	return nil, fmt.Errorf("failed after %d attempts: %v", retryAttempts, lastErr)
+	return nil, fmt.Errorf("failed after %d attempts: %v", c.config.RetryAttempts, lastErr)
 }
 
 func (c *Collector) batchInsertStats(stats []*models.ContainerStats) error {
@@ -224,10 +318,61 @@ func (c *Collector) adjustBatchSize() {
 	}
 	
 	// If average processing time is low, increase batch size
-	if avgProcessingTime < 1.0 && c.metrics.batchSize < maxBatchSize {
+	if avgProcessingTime < 1.0 && c.metrics.batchSize < c.config.BatchSize {
 		c.metrics.batchSize = c.metrics.batchSize * 12 / 10
-		if c.metrics.batchSize > maxBatchSize {
-			c.metrics.batchSize = maxBatchSize
+		if c.metrics.batchSize > c.config.BatchSize {
+			c.metrics.batchSize = c.config.BatchSize
+		}
+	}
+}
+
+// CollectorStats is a snapshot of a collector's internal metrics
+type CollectorStats struct {
+	HostID              string
+	TotalCollections    uint64
+	FailedCollections   uint64
+	TotalProcessingTime time.Duration
+	BatchSize           int
+}
+
+func (c *Collector) Stats() CollectorStats {
+	c.metrics.mutex.RLock()
+	defer c.metrics.mutex.RUnlock()
+
+	return CollectorStats{
+		HostID:              c.hostID,
+		TotalCollections:    c.metrics.totalCollections,
+		FailedCollections:   c.metrics.failedCollections,
+		TotalProcessingTime: c.metrics.totalProcessingTime,
+		BatchSize:           c.metrics.batchSize,
+	}
+}
+
+// LatestStats returns the most recent stats of every running container
+func (c *Collector) LatestStats() []*models.ContainerStats {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	stats := make([]*models.ContainerStats, 0, len(c.containers))
+	for _, stat := range c.containers {
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+// pruneContainers drops the cached stats of containers that are no longer
+// running
+func (c *Collector) pruneContainers(running []types.Container) {
+	ids := make(map[string]struct{}, len(running))
+	for _, container := range running {
+		ids[container.ID] = struct{}{}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for id := range c.containers {
+		if _, ok := ids[id]; !ok {
+			delete(c.containers, id)
 		}
 	}
 }
@@ -242,7 +387,7 @@ func (c *Collector) GetMetrics() map[string]interface{} {
 		"avg_processing_time":   c.metrics.totalProcessingTime.Seconds() / float64(c.metrics.totalCollections),
 		"current_batch_size":    c.metrics.batchSize,
 		"goroutines":           runtime.NumGoroutine(),
-		"max_concurrent_colls": maxConcurrentCollections,
+		"max_concurrent_colls": c.config.MaxConcurrent,
 	}
 }
 
@@ -268,6 +413,7 @@ func (c *Collector) CollectContainerStats() ([]*models.ContainerStats, error) {
 		if err != nil {
 			continue
 		}
+		applyListInfo(stat, container)
 		stats = append(stats, stat)
 	}
 
@@ -317,7 +463,8 @@ func (c *Collector) collectContainerStats(containerID string) (*models.Container
 	networkRx := calculateNetworkRx(stats.Networks)
 	networkTx := calculateNetworkTx(stats.Networks)
 
-	return &models.ContainerStats{
+	stat := &models.ContainerStats{
+		HostID:        c.hostID,
 		ContainerID:   containerID,
 		ContainerName: info.Name,
 		Timestamp:     time.Now(),
@@ -331,7 +478,59 @@ func (c *Collector) collectContainerStats(containerID string) (*models.Container
 		BlockRead:     diskRead,
 		BlockWrite:    diskWrite,
 		DiskIOTotal:   diskRead + diskWrite,
-	}, nil
+	}
+
+	// Image and labels for rule targeting, replaced by the ContainerList
+	// entry when the container was found through a listing
+	if info.Config != nil {
+		stat.Image = info.Config.Image
+		stat.Labels = info.Config.Labels
+	}
+
+	if info.State != nil && info.State.Health != nil {
+		applyHealth(stat, info.State.Health)
+	}
+
+	// Derive per-second rates from the previous sample's counters
+	var startedAt string
+	if info.State != nil {
+		startedAt = info.State.StartedAt
+	}
+	c.updateRates(stat, stats, startedAt)
+
+	return stat, nil
+}
+
+// applyListInfo copies the image reference and labels reported by
+// ContainerList, which rules and silences select containers by.
+func applyListInfo(stat *models.ContainerStats, container types.Container) {
+	if container.Image != "" {
+		stat.Image = container.Image
+	}
+	if container.Labels != nil {
+		stat.Labels = container.Labels
+	}
+}
+
+// applyHealth copies the health check status and probe results
+func applyHealth(stat *models.ContainerStats, health *types.Health) {
+	stat.Health = health.Status
+	stat.HealthFailingStreak = health.FailingStreak
+	stat.HealthLog = make([]models.HealthProbe, 0, len(health.Log))
+	for _, probe := range health.Log {
+		if probe == nil {
+			continue
+		}
+		stat.HealthLog = append(stat.HealthLog, models.HealthProbe{
+			Start:    probe.Start,
+			End:      probe.End,
+			ExitCode: probe.ExitCode,
+			Output:   probe.Output,
+		})
+	}
+	if n := len(stat.HealthLog); n > 0 {
+		stat.HealthOutput = stat.HealthLog[n-1].Output
+	}
 }
 
 func calculateCPUPercentUnix(stats types.StatsJSON) float64 {
@@ -0,0 +1,43 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+)
+
+// Config tunes how the collectors poll their hosts
+type Config struct {
+	Interval      time.Duration `mapstructure:"interval"`
+	BatchSize     int           `mapstructure:"batch_size"`     // Largest number of containers stored per batch
+	MaxConcurrent int           `mapstructure:"max_concurrent"` // Containers whose stats are read at the same time
+	RetryAttempts int           `mapstructure:"retry_attempts"` // Attempts at reading the stats of a container
+	RetryDelay    time.Duration `mapstructure:"retry_delay"`
+}
+
+// DefaultConfig returns the collector settings used when none are configured
+func DefaultConfig() Config {
+	return Config{
+		Interval:      30 * time.Second,
+		BatchSize:     100,
+		MaxConcurrent: 10,
+		RetryAttempts: 3,
+		RetryDelay:    5 * time.Second,
+	}
+}
+
+// Validate checks that the settings are usable
+func (c Config) Validate() error {
+	switch {
+	case c.Interval < time.Second:
+		return fmt.Errorf("monitor interval must be at least 1s")
+	case c.BatchSize < 1:
+		return fmt.Errorf("monitor batch_size must be positive")
+	case c.MaxConcurrent < 1:
+		return fmt.Errorf("monitor max_concurrent must be positive")
+	case c.RetryAttempts < 1:
+		return fmt.Errorf("monitor retry_attempts must be positive")
+	case c.RetryDelay < 0:
+		return fmt.Errorf("monitor retry_delay must not be negative")
+	}
+	return nil
+}
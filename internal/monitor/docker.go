@@ -0,0 +1,142 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"sync"
+	"time"
+
+	"containereye/internal/models"
+
+	"github.com/docker/docker/client"
+)
+
+// newDockerClient connects to the Docker endpoint of a host
+func newDockerClient(host *models.Host) (*client.Client, error) {
+	opts := []client.Opt{client.WithAPIVersionNegotiation()}
+
+	if host.Endpoint == "" {
+		return client.NewClientWithOpts(append(opts, client.FromEnv)...)
+	}
+
+	u, err := url.Parse(host.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %q: %v", host.Endpoint, err)
+	}
+	switch u.Scheme {
+	case "unix", "npipe":
+		opts = append(opts, client.WithHost(host.Endpoint))
+	case "tcp":
+		opts = append(opts, client.WithHost(host.Endpoint))
+		if host.TLSCACert != "" || host.TLSCert != "" {
+			opts = append(opts, client.WithTLSClientConfig(host.TLSCACert, host.TLSCert, host.TLSKey))
+		}
+	case "ssh":
+		// The host name is only used for the request URLs, connections go
+		// through the ssh command
+		opts = append(opts,
+			client.WithHost("http://docker.example.com"),
+			client.WithDialContext(sshDialer(u)),
+		)
+	default:
+		return nil, fmt.Errorf("unsupported endpoint scheme %q", u.Scheme)
+	}
+	return client.NewClientWithOpts(opts...)
+}
+
+// ValidateHost checks a host's name and endpoint
+func ValidateHost(host *models.Host) error {
+	if host.Name == "" {
+		return fmt.Errorf("host name is required")
+	}
+	if host.Push && host.Endpoint != "" {
+		return fmt.Errorf("push hosts have no endpoint")
+	}
+	if host.Endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(host.Endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint %q: %v", host.Endpoint, err)
+	}
+	switch u.Scheme {
+	case "unix", "npipe", "tcp":
+	case "ssh":
+		if u.Hostname() == "" {
+			return fmt.Errorf("ssh endpoint %q has no host", host.Endpoint)
+		}
+	default:
+		return fmt.Errorf("unsupported endpoint scheme %q", u.Scheme)
+	}
+	if (host.TLSCert == "") != (host.TLSKey == "") {
+		return fmt.Errorf("tls_cert and tls_key must be set together")
+	}
+	return nil
+}
+
+// sshDialer returns a dialer running "docker system dial-stdio" on the
+// remote host, like the docker CLI does for ssh:// hosts. Authentication
+// is left to the ssh client configuration (keys, agent, known_hosts).
+func sshDialer(u *url.URL) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	args := []string{"-o", "BatchMode=yes"}
+	if u.User != nil {
+		args = append(args, "-l", u.User.Username())
+	}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	args = append(args, "--", u.Hostname(), "docker", "system", "dial-stdio")
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		cmd := exec.Command("ssh", args...)
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("failed to run ssh: %v", err)
+		}
+		return &commandConn{cmd: cmd, stdin: stdin, stdout: stdout}, nil
+	}
+}
+
+// commandConn is a net.Conn over the stdin and stdout of a command
+type commandConn struct {
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	stdout    io.Reader
+	closeOnce sync.Once
+}
+
+func (c *commandConn) Read(p []byte) (int, error)  { return c.stdout.Read(p) }
+func (c *commandConn) Write(p []byte) (int, error) { return c.stdin.Write(p) }
+
+func (c *commandConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.stdin.Close()
+		c.cmd.Process.Kill()
+		c.cmd.Wait()
+	})
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr  { return dummyAddr{} }
+func (c *commandConn) RemoteAddr() net.Addr { return dummyAddr{} }
+
+// Deadlines are not supported by pipes, requests are bounded by their context
+func (c *commandConn) SetDeadline(t time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type dummyAddr struct{}
+
+func (dummyAddr) Network() string { return "ssh" }
+func (dummyAddr) String() string  { return "ssh" }
@@ -0,0 +1,273 @@
+package monitor
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"containereye/internal/alert"
+	"containereye/internal/database"
+	"containereye/internal/models"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	eventReconnectDelay    = time.Second
+	maxEventReconnectDelay = time.Minute
+)
+
+// watchedActions are the Docker container actions recorded as events
+var watchedActions = []models.EventType{
+	models.EventStart,
+	models.EventStop,
+	models.EventDie,
+	models.EventOOM,
+	models.EventRestart,
+	models.EventHealthStatus,
+}
+
+// Attributes Docker sets on container events besides the container labels
+var eventAttributes = map[string]bool{"name": true, "image": true, "exitCode": true, "signal": true, "execDuration": true}
+
+// EventWatcher subscribes to the Docker events API, records container
+// lifecycle events, keeps the container inventory current and evaluates
+// event rules as events arrive.
+type EventWatcher struct {
+	hostID       string
+	dockerClient *client.Client
+	ruleManager  *alert.RuleManager
+	ctx          context.Context
+	cancel       context.CancelFunc
+	done         chan struct{}
+	lastEvent    time.Time
+	changed      func(containerID string) // Called after an event changed a container
+	active       func() bool              // Reports whether this server records the host's events
+}
+
+func NewEventWatcher(hostID string, dockerClient *client.Client, ruleManager *alert.RuleManager) *EventWatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &EventWatcher{
+		hostID:       hostID,
+		dockerClient: dockerClient,
+		ruleManager:  ruleManager,
+		ctx:          ctx,
+		cancel:       cancel,
+		done:         make(chan struct{}),
+	}
+}
+
+// Start subscribes in the background, reconnecting with backoff when the
+// stream breaks and resuming from the last event seen.
+func (w *EventWatcher) Start() {
+	go func() {
+		defer close(w.done)
+
+		delay := eventReconnectDelay
+		for {
+			connected := time.Now()
+			err := w.watch()
+			if w.ctx.Err() != nil {
+				return
+			}
+			log.Printf("Docker event stream of host %s interrupted: %v", w.hostID, err)
+
+			// A stream that stayed up for a while starts the backoff over
+			if time.Since(connected) > maxEventReconnectDelay {
+				delay = eventReconnectDelay
+			}
+			select {
+			case <-time.After(delay):
+			case <-w.ctx.Done():
+				return
+			}
+			delay *= 2
+			if delay > maxEventReconnectDelay {
+				delay = maxEventReconnectDelay
+			}
+		}
+	}()
+}
+
+func (w *EventWatcher) Stop() {
+	w.cancel()
+	<-w.done
+}
+
+func (w *EventWatcher) watch() error {
+	args := filters.NewArgs(filters.Arg("type", string(events.ContainerEventType)))
+	for _, action := range watchedActions {
+		args.Add("event", string(action))
+	}
+
+	opts := types.EventsOptions{Filters: args}
+	if !w.lastEvent.IsZero() {
+		opts.Since = strconv.FormatInt(w.lastEvent.Unix(), 10)
+	}
+
+	messages, errs := w.dockerClient.Events(w.ctx, opts)
+	for {
+		select {
+		case msg := <-messages:
+			event := parseEvent(msg)
+			if event == nil {
+				continue
+			}
+			event.HostID = w.hostID
+			// Resuming with Since repeats the events of the last second
+			if !event.Time.After(w.lastEvent) && w.isRecorded(event) {
+				continue
+			}
+			w.lastEvent = event.Time
+			// Another server holding the host's lease records it
+			if w.active != nil && !w.active() {
+				continue
+			}
+			if err := w.handle(event); err != nil {
+				log.Printf("Error handling %s event for container %s: %v", event.Type, event.ContainerName, err)
+			}
+		case err := <-errs:
+			return err
+		}
+	}
+}
+
+// parseEvent converts a Docker event message, or returns nil for actions
+// that are not recorded.
+func parseEvent(msg events.Message) *models.ContainerEvent {
+	action := msg.Action
+	var health string
+	// Health events carry the status in the action, e.g. "health_status: healthy"
+	if i := strings.Index(action, ":"); i >= 0 {
+		health = strings.TrimSpace(action[i+1:])
+		action = action[:i]
+	}
+
+	eventType := models.EventType(action)
+	known := false
+	for _, a := range watchedActions {
+		if a == eventType {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return nil
+	}
+
+	attrs := msg.Actor.Attributes
+	event := &models.ContainerEvent{
+		ContainerID:   msg.Actor.ID,
+		ContainerName: "/" + attrs["name"],
+		Image:         attrs["image"],
+		Type:          eventType,
+		HealthStatus:  health,
+		Labels:        eventLabels(attrs),
+		Time:          time.Unix(0, msg.TimeNano),
+	}
+	if msg.TimeNano == 0 {
+		event.Time = time.Unix(msg.Time, 0)
+	}
+	if code, err := strconv.Atoi(attrs["exitCode"]); err == nil {
+		event.ExitCode = &code
+	}
+	return event
+}
+
+func (w *EventWatcher) isRecorded(event *models.ContainerEvent) bool {
+	var count int64
+	database.GetDB().Model(&models.ContainerEvent{}).
+		Where("host_id = ? AND container_id = ? AND type = ? AND time = ?", event.HostID, event.ContainerID, event.Type, event.Time).
+		Count(&count)
+	return count > 0
+}
+
+// handle records the event, updates the container row and evaluates the
+// event rules for the container.
+func (w *EventWatcher) handle(event *models.ContainerEvent) error {
+	db := database.GetDB()
+
+	// The collector may insert the container at the same time
+	container := models.Container{HostID: event.HostID, ContainerID: event.ContainerID, Name: event.ContainerName, Image: event.Image}
+	if err := db.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "container_id"}},
+		DoNothing: true,
+	}).Create(&container).Error; err != nil {
+		return err
+	}
+	if err := db.Where("container_id = ?", event.ContainerID).First(&container).Error; err != nil {
+		return err
+	}
+
+	recorded := []*models.ContainerEvent{event}
+	updates := map[string]interface{}{"last_seen": event.Time}
+	switch event.Type {
+	case models.EventStart, models.EventRestart:
+		updates["state"] = "running"
+		updates["gone"] = false
+		// The restart count is only known to the daemon
+		if info, err := w.dockerClient.ContainerInspect(w.ctx, event.ContainerID); err == nil {
+			updates["status"] = info.State.Status
+			updates["restart_count"] = info.RestartCount
+			if t, err := time.Parse(time.RFC3339Nano, info.State.StartedAt); err == nil {
+				updates["started_at"] = t
+			}
+			if event.Type == models.EventStart && info.RestartCount > container.RestartCount {
+				// Restart policy restarts only show up as die followed by start
+				restart := *event
+				restart.Type = models.EventRestart
+				recorded = append(recorded, &restart)
+			}
+		}
+	case models.EventStop:
+		updates["state"] = "exited"
+	case models.EventDie:
+		updates["state"] = "exited"
+		if event.ExitCode != nil {
+			updates["exit_code"] = *event.ExitCode
+		}
+	case models.EventHealthStatus:
+		updates["health"] = event.HealthStatus
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, e := range recorded {
+			if err := tx.Create(e).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&container).Updates(updates).Error
+	})
+	if err != nil {
+		return err
+	}
+	if w.changed != nil {
+		w.changed(event.ContainerID)
+	}
+
+	for _, e := range recorded {
+		if err := w.ruleManager.EvaluateEvent(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventLabels returns the container labels Docker includes in the event
+// attributes.
+func eventLabels(attrs map[string]string) map[string]string {
+	labels := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		if !eventAttributes[k] {
+			labels[k] = v
+		}
+	}
+	return labels
+}
@@ -0,0 +1,326 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"containereye/internal/alert"
+	"containereye/internal/database"
+	"containereye/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrHostNotFound is returned when a host does not exist
+	ErrHostNotFound = errors.New("host not found")
+	// ErrStaticHost is returned when changing a host defined in the config file
+	ErrStaticHost = errors.New("host is defined in the config file")
+	// ErrNotPushHost is returned when stats are pushed for a host the server
+	// connects to itself
+	ErrNotPushHost = errors.New("host is not a push host")
+	// ErrHostDisabled is returned when stats are pushed for a disabled host
+	ErrHostDisabled = errors.New("host is disabled")
+)
+
+// HostConfig describes one entry of the hosts config list
+type HostConfig struct {
+	Name      string `mapstructure:"name" yaml:"name"`
+	Endpoint  string `mapstructure:"endpoint" yaml:"endpoint"`
+	TLSCACert string `mapstructure:"tls_ca_cert" yaml:"tls_ca_cert"`
+	TLSCert   string `mapstructure:"tls_cert" yaml:"tls_cert"`
+	TLSKey    string `mapstructure:"tls_key" yaml:"tls_key"`
+	Push      bool   `mapstructure:"push" yaml:"push"` // Stats are pushed by "containereye agent"
+}
+
+// HostManager runs one collector per enabled host. Hosts from the config
+// file are stored alongside the hosts added through the API.
+type HostManager struct {
+	ruleManager *alert.RuleManager
+	config      Config
+	mutex       sync.Mutex
+	collectors  map[string]*Collector
+}
+
+func NewHostManager(ruleManager *alert.RuleManager, config Config) *HostManager {
+	return &HostManager{
+		ruleManager: ruleManager,
+		config:      config,
+		collectors:  make(map[string]*Collector),
+	}
+}
+
+// Start stores the configured hosts and starts collecting from every
+// enabled host. Without configured hosts the local Docker daemon is
+// monitored.
+func (m *HostManager) Start(configured []HostConfig) error {
+	if err := syncStaticHosts(configured); err != nil {
+		return fmt.Errorf("failed to store configured hosts: %v", err)
+	}
+
+	hosts, err := m.ListHosts()
+	if err != nil {
+		return err
+	}
+	for i := range hosts {
+		// Push hosts get a collector once their agent pushes
+		if !hosts[i].Enabled || hosts[i].Push {
+			continue
+		}
+		// A host that cannot be set up must not keep the others from running
+		if err := m.startCollector(&hosts[i]); err != nil {
+			log.Printf("Failed to start collector for host %s: %v", hosts[i].Name, err)
+		}
+	}
+	return nil
+}
+
+func (m *HostManager) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for name, collector := range m.collectors {
+		collector.Stop()
+		delete(m.collectors, name)
+	}
+}
+
+// syncStaticHosts replaces the hosts from a previous config file with the
+// configured ones
+func syncStaticHosts(configured []HostConfig) error {
+	hosts := make([]models.Host, 0, len(configured))
+	for _, hc := range configured {
+		hosts = append(hosts, models.Host{
+			Name:      hc.Name,
+			Endpoint:  hc.Endpoint,
+			TLSCACert: hc.TLSCACert,
+			TLSCert:   hc.TLSCert,
+			TLSKey:    hc.TLSKey,
+			Push:      hc.Push,
+		})
+	}
+	if len(hosts) == 0 {
+		hosts = append(hosts, models.Host{Name: models.LocalHostID})
+	}
+
+	names := make([]string, 0, len(hosts))
+	for i := range hosts {
+		if err := ValidateHost(&hosts[i]); err != nil {
+			return err
+		}
+		names = append(names, hosts[i].Name)
+	}
+
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("static = ? AND name NOT IN ?", true, names).Delete(&models.Host{}).Error; err != nil {
+			return err
+		}
+		for _, host := range hosts {
+			var existing models.Host
+			err := tx.Where("name = ?", host.Name).First(&existing).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			// Keep the status of a host that was already known
+			existing.Name = host.Name
+			existing.Endpoint = host.Endpoint
+			existing.TLSCACert = host.TLSCACert
+			existing.TLSCert = host.TLSCert
+			existing.TLSKey = host.TLSKey
+			existing.Push = host.Push
+			existing.Enabled = true
+			existing.Static = true
+			if err := tx.Save(&existing).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *HostManager) startCollector(host *models.Host) error {
+	collector, err := NewCollector(host, m.ruleManager, m.config)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if old, ok := m.collectors[host.Name]; ok {
+		old.Stop()
+	}
+	m.collectors[host.Name] = collector
+	collector.Start()
+	return nil
+}
+
+func (m *HostManager) stopCollector(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if collector, ok := m.collectors[name]; ok {
+		collector.Stop()
+		delete(m.collectors, name)
+	}
+}
+
+// Collector returns the collector of a host, or nil if the host is not
+// being monitored
+func (m *HostManager) Collector(name string) *Collector {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.collectors[name]
+}
+
+// Collectors returns the running collectors, ordered by host
+func (m *HostManager) Collectors() []*Collector {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	collectors := make([]*Collector, 0, len(m.collectors))
+	for _, collector := range m.collectors {
+		collectors = append(collectors, collector)
+	}
+	sort.Slice(collectors, func(i, j int) bool {
+		return collectors[i].hostID < collectors[j].hostID
+	})
+	return collectors
+}
+
+func (m *HostManager) ListHosts() ([]models.Host, error) {
+	var hosts []models.Host
+	if err := database.GetDB().Order("name").Find(&hosts).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch hosts: %v", err)
+	}
+	return hosts, nil
+}
+
+func (m *HostManager) GetHost(name string) (*models.Host, error) {
+	var host models.Host
+	if err := database.GetDB().Where("name = ?", name).First(&host).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrHostNotFound
+		}
+		return nil, err
+	}
+	return &host, nil
+}
+
+// CreateHost stores a host and starts monitoring it if it is enabled
+func (m *HostManager) CreateHost(host *models.Host) error {
+	if err := ValidateHost(host); err != nil {
+		return err
+	}
+	host.Static = false
+	host.Status = models.HostStatusUnknown
+	if err := database.GetDB().Create(host).Error; err != nil {
+		return err
+	}
+	if !host.Enabled || host.Push {
+		return nil
+	}
+	return m.startCollector(host)
+}
+
+// UpdateHost changes the endpoint of a host and reconnects to it. The name
+// cannot change as it identifies the host's data.
+func (m *HostManager) UpdateHost(host *models.Host) error {
+	existing, err := m.GetHost(host.Name)
+	if err != nil {
+		return err
+	}
+	if existing.Static {
+		return ErrStaticHost
+	}
+	if err := ValidateHost(host); err != nil {
+		return err
+	}
+
+	existing.Endpoint = host.Endpoint
+	existing.TLSCACert = host.TLSCACert
+	existing.TLSCert = host.TLSCert
+	existing.TLSKey = host.TLSKey
+	existing.Push = host.Push
+	existing.Enabled = host.Enabled
+	if err := database.GetDB().Save(existing).Error; err != nil {
+		return err
+	}
+	*host = *existing
+
+	m.stopCollector(host.Name)
+	if !host.Enabled || host.Push {
+		return nil
+	}
+	return m.startCollector(host)
+}
+
+// DeleteHost stops monitoring a host. Its containers are kept as gone.
+func (m *HostManager) DeleteHost(name string) error {
+	host, err := m.GetHost(name)
+	if err != nil {
+		return err
+	}
+	if host.Static {
+		return ErrStaticHost
+	}
+
+	m.stopCollector(name)
+	var gone []string
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		// Deleted for good so the name can be used again
+		if err := tx.Unscoped().Delete(host).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Container{}).Where("host_id = ? AND gone = ?", name, false).
+			Pluck("container_id", &gone).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Container{}).Where("host_id = ?", name).
+			Updates(map[string]interface{}{"gone": true, "cpu_percent": 0, "mem_percent": 0}).Error
+	})
+	if err != nil {
+		return err
+	}
+	return m.ruleManager.CloseContainerAlerts(gone)
+}
+
+// Ingest stores a batch pushed by the agent of a host. Unknown hosts are
+// registered as push hosts on their first push when register is set, and
+// are otherwise rejected until they are added.
+func (m *HostManager) Ingest(batch *models.IngestBatch, register bool) error {
+	host, err := m.GetHost(batch.Host)
+	if errors.Is(err, ErrHostNotFound) && !register {
+		return fmt.Errorf("%w: add %s as a push host first", ErrHostNotFound, batch.Host)
+	}
+	if errors.Is(err, ErrHostNotFound) {
+		host = &models.Host{Name: batch.Host, Push: true, Enabled: true}
+		if err := ValidateHost(host); err != nil {
+			return err
+		}
+		err = database.GetDB().Create(host).Error
+	}
+	if err != nil {
+		return err
+	}
+	if !host.Push {
+		return ErrNotPushHost
+	}
+	if !host.Enabled {
+		return ErrHostDisabled
+	}
+
+	m.mutex.Lock()
+	collector, ok := m.collectors[host.Name]
+	if !ok {
+		collector = newPushCollector(host.Name, m.ruleManager, m.config)
+		m.collectors[host.Name] = collector
+	}
+	m.mutex.Unlock()
+
+	err = collector.Ingest(batch)
+	collector.updateHostStatus(err)
+	return err
+}
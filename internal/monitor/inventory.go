@@ -0,0 +1,171 @@
+package monitor
+
+import (
+	"time"
+
+	"containereye/internal/database"
+	"containereye/internal/models"
+
+	"github.com/docker/docker/api/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// inventory builds the inventory rows of the listed containers, running or
+// not. Restart count, start time, exit code and health are only known from
+// an inspect, which is skipped while a container's state stays the same.
+func (c *Collector) inventory(containers []types.Container) []models.Container {
+	c.inventoryMutex.Lock()
+	defer c.inventoryMutex.Unlock()
+
+	now := time.Now()
+	rows := make([]models.Container, 0, len(containers))
+	listed := make(map[string]struct{}, len(containers))
+	for _, container := range containers {
+		listed[container.ID] = struct{}{}
+
+		row, ok := c.known[container.ID]
+		refresh := !ok || row.State != container.State || row.StartedAt.IsZero()
+
+		row.HostID = c.hostID
+		row.ContainerID = container.ID
+		if len(container.Names) > 0 {
+			row.Name = container.Names[0]
+		}
+		row.Image = container.Image
+		row.Labels = container.Labels
+		row.State = container.State
+		row.Status = container.Status
+		row.Created = time.Unix(container.Created, 0)
+		row.LastSeen = now
+		row.Gone = false
+
+		c.mutex.RLock()
+		stats, running := c.containers[container.ID]
+		c.mutex.RUnlock()
+		if running && container.State == "running" {
+			row.CPUPercent = stats.CPUPercent
+			row.MemPercent = stats.MemoryPercent
+			row.Health = stats.Health
+			row.HealthFailingStreak = stats.HealthFailingStreak
+			row.HealthLog = stats.HealthLog
+		} else {
+			row.CPUPercent = 0
+			row.MemPercent = 0
+		}
+
+		if refresh {
+			c.inspectInventory(&row)
+		}
+		c.known[container.ID] = row
+		rows = append(rows, row)
+	}
+
+	for id := range c.known {
+		if _, ok := listed[id]; !ok {
+			delete(c.known, id)
+		}
+	}
+	return rows
+}
+
+// forgetInventory makes the next collection inspect a container again,
+// after an event changed what an inspect reports
+func (c *Collector) forgetInventory(containerID string) {
+	c.inventoryMutex.Lock()
+	defer c.inventoryMutex.Unlock()
+	delete(c.known, containerID)
+}
+
+// inspectInventory fills the inventory fields that ContainerList does not
+// report. Errors are ignored, the next state change retries.
+func (c *Collector) inspectInventory(row *models.Container) {
+	info, err := c.dockerClient.ContainerInspect(c.ctx, row.ContainerID)
+	if err != nil {
+		return
+	}
+
+	row.RestartCount = info.RestartCount
+	if info.State == nil {
+		return
+	}
+	row.ExitCode = info.State.ExitCode
+	if t, err := time.Parse(time.RFC3339Nano, info.State.StartedAt); err == nil {
+		row.StartedAt = t
+	}
+	// Stopped containers keep the health they had when they stopped
+	if info.State.Health != nil {
+		var stat models.ContainerStats
+		applyHealth(&stat, info.State.Health)
+		row.Health = stat.Health
+		row.HealthFailingStreak = stat.HealthFailingStreak
+		row.HealthLog = stat.HealthLog
+	}
+}
+
+// storeInventory upserts the inventory rows of a host and marks the host's
+// containers that are no longer listed as gone. Container IDs are unique
+// across hosts: a container stored under another host, e.g. one kept as
+// gone after its daemon was removed and re-added under a new name, moves to
+// the host that lists it. Rows are upserted on the container ID, as the
+// event watcher may insert a new container at the same time. It returns the
+// IDs of the containers it marked gone.
+func storeInventory(hostID string, rows []models.Container) ([]string, error) {
+	db := database.GetDB()
+
+	ids := make([]string, len(rows))
+	for i := range rows {
+		ids[i] = rows[i].ContainerID
+	}
+	query := db.Where("host_id = ?", hostID)
+	if len(ids) > 0 {
+		query = query.Or("container_id IN ?", ids)
+	}
+	var known []models.Container
+	if err := query.Find(&known).Error; err != nil {
+		return nil, err
+	}
+	existing := make(map[string]*models.Container, len(known))
+	for i := range known {
+		existing[known[i].ContainerID] = &known[i]
+	}
+
+	listed := make(map[string]struct{}, len(rows))
+	var gone []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		upserts := make([]models.Container, len(rows))
+		for i, row := range rows {
+			row.HostID = hostID
+			row.Model = gorm.Model{}
+			upserts[i] = row
+			listed[row.ContainerID] = struct{}{}
+		}
+		if len(upserts) > 0 {
+			if err := tx.Omit(clause.Associations).Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "container_id"}},
+				UpdateAll: true,
+			}).CreateInBatches(upserts, 100).Error; err != nil {
+				return err
+			}
+		}
+
+		for id, row := range existing {
+			if _, ok := listed[id]; ok || row.Gone || row.HostID != hostID {
+				continue
+			}
+			if err := tx.Model(row).Updates(map[string]interface{}{
+				"gone":        true,
+				"cpu_percent": 0,
+				"mem_percent": 0,
+			}).Error; err != nil {
+				return err
+			}
+			gone = append(gone, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return gone, nil
+}
@@ -0,0 +1,117 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"containereye/internal/alert"
+	"containereye/internal/models"
+
+	"github.com/docker/docker/api/types"
+)
+
+// newPushCollector returns a collector for a host whose stats are pushed by
+// an agent. It has no Docker connection and only ingests batches.
+func newPushCollector(hostID string, ruleManager *alert.RuleManager, config Config) *Collector {
+	return newCollector(hostID, ruleManager, config)
+}
+
+// Snapshot collects the stats of the running containers and the inventory
+// of all containers without storing them, for an agent to push to the
+// server.
+func (c *Collector) Snapshot() (*models.IngestBatch, error) {
+	all, err := c.dockerClient.ContainerList(c.ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %v", err)
+	}
+
+	running := make([]types.Container, 0, len(all))
+	for _, container := range all {
+		if container.State == "running" {
+			running = append(running, container)
+		}
+	}
+	c.pruneSamples(running)
+	c.pruneContainers(running)
+
+	batch := &models.IngestBatch{
+		Host:        c.hostID,
+		CollectedAt: time.Now(),
+		Stats:       make([]*models.ContainerStats, 0, len(running)),
+	}
+	for _, container := range running {
+		stat, err := c.collectContainerStatsWithRetry(container.ID)
+		if err != nil {
+			continue
+		}
+		applyListInfo(stat, container)
+		batch.Stats = append(batch.Stats, stat)
+
+		c.mutex.Lock()
+		c.containers[stat.ContainerID] = stat
+		c.mutex.Unlock()
+	}
+
+	batch.Containers = c.inventory(all)
+	return batch, nil
+}
+
+// Ingest stores a batch pushed by an agent the same way as locally
+// collected stats and evaluates the rules against it. Only a failure to
+// store the stats is returned, so the agent does not push them twice. A
+// batch collected before the last one applied, e.g. one the agent retried,
+// does not replace the newer latest stats and inventory.
+func (c *Collector) Ingest(batch *models.IngestBatch) error {
+	for _, stat := range batch.Stats {
+		stat.ID = 0
+		stat.HostID = c.hostID
+	}
+
+	if len(batch.Stats) > 0 {
+		if err := c.batchInsertStats(batch.Stats); err != nil {
+			return fmt.Errorf("error inserting stats batch: %v", err)
+		}
+	}
+
+	// The batch holds every running container of the host
+	containers := make(map[string]*models.ContainerStats, len(batch.Stats))
+	for _, stat := range batch.Stats {
+		containers[stat.ContainerID] = stat
+	}
+
+	c.mutex.Lock()
+	latest := !batch.CollectedAt.Before(c.lastBatch)
+	if latest {
+		c.containers = containers
+		c.lastBatch = batch.CollectedAt
+	}
+	c.mutex.Unlock()
+
+	for _, stat := range batch.Stats {
+		if err := c.ruleManager.EvaluateRules(stat); err != nil {
+			log.Printf("Error evaluating rules for container %s on host %s: %v", stat.ContainerID, c.hostID, err)
+		}
+	}
+
+	if !latest {
+		return nil
+	}
+
+	c.pushMutex.Lock()
+	defer c.pushMutex.Unlock()
+	// A newer batch may have been applied while the rules were evaluated
+	c.mutex.RLock()
+	superseded := batch.CollectedAt.Before(c.lastBatch)
+	c.mutex.RUnlock()
+	if superseded {
+		return nil
+	}
+	gone, err := storeInventory(c.hostID, batch.Containers)
+	if err != nil {
+		log.Printf("Error updating container inventory of host %s: %v", c.hostID, err)
+	} else if err := c.ruleManager.CloseContainerAlerts(gone); err != nil {
+		log.Printf("Error resolving alerts of gone containers on host %s: %v", c.hostID, err)
+	}
+	return nil
+}
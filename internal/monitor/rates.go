@@ -0,0 +1,117 @@
+package monitor
+
+import (
+	"time"
+
+	"containereye/internal/models"
+
+	"github.com/docker/docker/api/types"
+)
+
+// counterSample holds the cumulative counters of the previous sample of a
+// container so that per-second rates can be derived from the next one.
+type counterSample struct {
+	timestamp  time.Time
+	startedAt  string
+	rxBytes    uint64
+	txBytes    uint64
+	rxPackets  uint64
+	txPackets  uint64
+	rxErrors   uint64
+	txErrors   uint64
+	blockRead  uint64
+	blockWrite uint64
+}
+
+func newCounterSample(stats types.StatsJSON, startedAt string, blockRead, blockWrite uint64) *counterSample {
+	sample := &counterSample{
+		timestamp:  stats.Read,
+		startedAt:  startedAt,
+		blockRead:  blockRead,
+		blockWrite: blockWrite,
+	}
+	if sample.timestamp.IsZero() {
+		sample.timestamp = time.Now()
+	}
+	for _, network := range stats.Networks {
+		sample.rxBytes += network.RxBytes
+		sample.txBytes += network.TxBytes
+		sample.rxPackets += network.RxPackets
+		sample.txPackets += network.TxPackets
+		sample.rxErrors += network.RxErrors
+		sample.txErrors += network.TxErrors
+	}
+	return sample
+}
+
+// isResetFrom reports whether the counters went backwards since prev, which
+// happens when a container is restarted and its cgroup counters start over.
+func (s *counterSample) isResetFrom(prev *counterSample) bool {
+	if s.startedAt != prev.startedAt {
+		return true
+	}
+	return s.rxBytes < prev.rxBytes || s.txBytes < prev.txBytes ||
+		s.rxPackets < prev.rxPackets || s.txPackets < prev.txPackets ||
+		s.rxErrors < prev.rxErrors || s.txErrors < prev.txErrors ||
+		s.blockRead < prev.blockRead || s.blockWrite < prev.blockWrite
+}
+
+// applyRates fills the rate fields of stat from the delta between prev and
+// cur. Rates are left at zero for the first sample of a container and after
+// a counter reset, since there is no meaningful baseline to compare against.
+func applyRates(stat *models.ContainerStats, prev, cur *counterSample) {
+	if prev == nil || cur.isResetFrom(prev) {
+		return
+	}
+
+	elapsed := cur.timestamp.Sub(prev.timestamp).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	rate := func(current, previous uint64) float64 {
+		return float64(current-previous) / elapsed
+	}
+
+	stat.NetworkRxRate = rate(cur.rxBytes, prev.rxBytes)
+	stat.NetworkTxRate = rate(cur.txBytes, prev.txBytes)
+	stat.NetworkRate = stat.NetworkRxRate + stat.NetworkTxRate
+	stat.NetworkRxPacketRate = rate(cur.rxPackets, prev.rxPackets)
+	stat.NetworkTxPacketRate = rate(cur.txPackets, prev.txPackets)
+	stat.NetworkRxErrorRate = rate(cur.rxErrors, prev.rxErrors)
+	stat.NetworkTxErrorRate = rate(cur.txErrors, prev.txErrors)
+
+	stat.BlockReadRate = rate(cur.blockRead, prev.blockRead)
+	stat.BlockWriteRate = rate(cur.blockWrite, prev.blockWrite)
+	stat.DiskIORate = stat.BlockReadRate + stat.BlockWriteRate
+}
+
+// updateRates records the counters of the current sample and applies the
+// rates computed against the previous sample of the same container.
+func (c *Collector) updateRates(stat *models.ContainerStats, stats types.StatsJSON, startedAt string) {
+	cur := newCounterSample(stats, startedAt, stat.BlockRead, stat.BlockWrite)
+
+	c.samplesMutex.Lock()
+	prev := c.samples[stat.ContainerID]
+	c.samples[stat.ContainerID] = cur
+	c.samplesMutex.Unlock()
+
+	applyRates(stat, prev, cur)
+}
+
+// pruneSamples drops the previous samples of containers that are no longer
+// running so the map does not grow with every container ever seen.
+func (c *Collector) pruneSamples(containers []types.Container) {
+	running := make(map[string]struct{}, len(containers))
+	for _, container := range containers {
+		running[container.ID] = struct{}{}
+	}
+
+	c.samplesMutex.Lock()
+	defer c.samplesMutex.Unlock()
+	for id := range c.samples {
+		if _, ok := running[id]; !ok {
+			delete(c.samples, id)
+		}
+	}
+}
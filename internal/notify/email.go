@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+
+	"containereye/internal/models"
+	"gopkg.in/gomail.v2"
+)
+
+// EmailNotifier sends alerts as plain text mails over SMTP
+type EmailNotifier struct {
+	name   string
+	dialer *gomail.Dialer
+	From   string
+	To     []string
+}
+
+func NewEmailNotifier(name string, dialer *gomail.Dialer, from string, to []string) *EmailNotifier {
+	return &EmailNotifier{
+		name:   name,
+		dialer: dialer,
+		From:   from,
+		To:     to,
+	}
+}
+
+// newEmailFromConfig builds an email notifier from a handler config
+func newEmailFromConfig(name string, config map[string]interface{}) (Notifier, error) {
+	host := configString(config, "smtp_host")
+	if host == "" {
+		return nil, fmt.Errorf("smtp_host is required")
+	}
+
+	port, err := configInt(config, "smtp_port", 587)
+	if err != nil {
+		return nil, err
+	}
+
+	from := configString(config, "from")
+	if from == "" {
+		return nil, fmt.Errorf("from is required")
+	}
+
+	to := configStrings(config, "to")
+	if len(to) == 0 {
+		return nil, fmt.Errorf("at least one recipient is required in to")
+	}
+
+	dialer := gomail.NewDialer(host, port,
+		configString(config, "smtp_user"), configString(config, "smtp_password"))
+
+	return NewEmailNotifier(name, dialer, from, to), nil
+}
+
+func (e *EmailNotifier) Name() string {
+	return e.name
+}
+
+func (e *EmailNotifier) Notify(alert *models.Alert) error {
+	return e.NotifyRecipients(alert, e.To)
+}
+
+// NotifyRecipients sends the alert to the given addresses instead of the
+// configured recipients.
+func (e *EmailNotifier) NotifyRecipients(alert *models.Alert, recipients []string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", e.From)
+	m.SetHeader("To", recipients...)
+
+	subject := "Container Alert: " + string(alert.Level)
+	if alert.Status == models.AlertStatusResolved {
+		subject = "Container Alert Resolved: " + string(alert.Level)
+	}
+	m.SetHeader("Subject", subject)
+
+	body := fmt.Sprintf(`
+		Container: %s
+		Alert Level: %s
+		Metric: %s
+		Current Value: %.2f
+		Threshold: %.2f
+		Message: %s
+		Status: %s
+		Time: %s
+	`, alert.ContainerName, alert.Level, alert.Metric,
+		alert.CurrentValue, alert.Threshold, alert.Message,
+		alert.Status, time.Now().Format(time.RFC3339))
+
+	m.SetBody("text/plain", body)
+
+	if err := e.dialer.DialAndSend(m); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"containereye/internal/models"
+)
+
+// Notifier delivers an alert to a single notification channel
+type Notifier interface {
+	// Name returns the configured name of the channel
+	Name() string
+	// Notify sends the alert in its current state (firing or resolved)
+	// once. The outbox retries failures unless they are permanent, see
+	// Permanent.
+	Notify(alert *models.Alert) error
+}
+
+// PermanentError is a delivery failure that would fail again on retry,
+// e.g. the endpoint rejecting the request. The outbox dead-letters such
+// deliveries at once.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent marks err as not worth retrying
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// IsRetryable reports whether a delivery that failed with err may succeed
+// on a later attempt
+func IsRetryable(err error) bool {
+	var permanent *PermanentError
+	return !errors.As(err, &permanent)
+}
+
+// RetryPolicy replaces the outbox retry limits for the deliveries of a
+// channel. Zero fields keep the outbox defaults.
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts before a delivery is dead-lettered
+	BaseBackoff time.Duration // Delay before the first retry, doubled per attempt
+}
+
+// RetryNotifier is implemented by channels that can be configured with
+// retry limits of their own, e.g. webhooks.
+type RetryNotifier interface {
+	Notifier
+	RetryPolicy() RetryPolicy
+}
+
+// RecipientNotifier is implemented by channels that can address a message
+// to recipients other than the configured ones, e.g. email.
+type RecipientNotifier interface {
+	Notifier
+	NotifyRecipients(alert *models.Alert, recipients []string) error
+}
+
+// HandlerConfig describes one entry of the alert.handlers config list
+type HandlerConfig struct {
+	Name   string                 `mapstructure:"name" yaml:"name"`
+	Type   string                 `mapstructure:"type" yaml:"type"`
+	Config map[string]interface{} `mapstructure:"config" yaml:"config"`
+}
+
+// Factory builds a notifier of one type from its handler config
+type Factory func(name string, config map[string]interface{}) (Notifier, error)
+
+var (
+	factoriesMutex sync.RWMutex
+	factories      = map[string]Factory{
+		"slack":   newSlackFromConfig,
+		"email":   newEmailFromConfig,
+		"webhook": newWebhookFromConfig,
+	}
+)
+
+// RegisterType makes a notifier type available to handler configs
+func RegisterType(handlerType string, factory Factory) {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+	factories[handlerType] = factory
+}
+
+// Types returns the registered notifier types in sorted order
+func Types() []string {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+
+	types := make([]string, 0, len(factories))
+	for t := range factories {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Registry holds the notifiers built from configuration, in config order
+type Registry struct {
+	notifiers []Notifier
+	byName    map[string]Notifier
+}
+
+// NewRegistry builds a notifier for every handler config entry. Names must
+// be unique since other parts of the system refer to channels by name.
+func NewRegistry(handlers []HandlerConfig) (*Registry, error) {
+	r := &Registry{byName: make(map[string]Notifier)}
+
+	for i, h := range handlers {
+		if h.Name == "" {
+			return nil, fmt.Errorf("alert handler #%d: name is required", i+1)
+		}
+
+		factoriesMutex.RLock()
+		factory, ok := factories[h.Type]
+		factoriesMutex.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("alert handler %q: unknown type %q", h.Name, h.Type)
+		}
+
+		n, err := factory(h.Name, h.Config)
+		if err != nil {
+			return nil, fmt.Errorf("alert handler %q: %v", h.Name, err)
+		}
+
+		if err := r.Add(n); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Add registers an already constructed notifier
+func (r *Registry) Add(n Notifier) error {
+	if _, exists := r.byName[n.Name()]; exists {
+		return fmt.Errorf("duplicate alert handler name %q", n.Name())
+	}
+	r.notifiers = append(r.notifiers, n)
+	r.byName[n.Name()] = n
+	return nil
+}
+
+// Get returns the notifier with the given name
+func (r *Registry) Get(name string) (Notifier, bool) {
+	n, ok := r.byName[name]
+	return n, ok
+}
+
+// Notifiers returns all registered notifiers
+func (r *Registry) Notifiers() []Notifier {
+	return r.notifiers
+}
+
+// Config value helpers. Values come from YAML via viper, so numbers may be
+// decoded as int or float64 and lists as []interface{}.
+
+func configString(config map[string]interface{}, key string) string {
+	v, ok := config[key]
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func configInt(config map[string]interface{}, key string, def int) (int, error) {
+	v, ok := config[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case int64:
+		return int(n), nil
+	case float64:
+		return int(n), nil
+	case string:
+		i, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, fmt.Errorf("%s must be a number: %v", key, err)
+		}
+		return i, nil
+	default:
+		return 0, fmt.Errorf("%s must be a number", key)
+	}
+}
+
+func configStrings(config map[string]interface{}, key string) []string {
+	v, ok := config[key]
+	if !ok || v == nil {
+		return nil
+	}
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		out := make([]string, 0, len(list))
+		for _, item := range list {
+			out = append(out, fmt.Sprintf("%v", item))
+		}
+		return out
+	case string:
+		return []string{list}
+	default:
+		return nil
+	}
+}
+
+func configStringMap(config map[string]interface{}, key string) map[string]string {
+	v, ok := config[key]
+	if !ok || v == nil {
+		return nil
+	}
+	out := make(map[string]string)
+	switch m := v.(type) {
+	case map[string]interface{}:
+		for k, val := range m {
+			out[k] = fmt.Sprintf("%v", val)
+		}
+	case map[interface{}]interface{}:
+		for k, val := range m {
+			out[fmt.Sprintf("%v", k)] = fmt.Sprintf("%v", val)
+		}
+	case map[string]string:
+		for k, val := range m {
+			out[k] = val
+		}
+	}
+	return out
+}
+
+func configDuration(config map[string]interface{}, key string, def time.Duration) (time.Duration, error) {
+	v := configString(config, key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration like \"10s\": %v", key, err)
+	}
+	return d, nil
+}
@@ -0,0 +1,399 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"containereye/internal/database"
+	"containereye/internal/models"
+	"gorm.io/gorm"
+)
+
+// OutboxConfig controls how queued notifications are delivered
+type OutboxConfig struct {
+	Workers      int           `mapstructure:"workers"`       // Number of concurrent senders
+	MaxAttempts  int           `mapstructure:"max_attempts"`  // Attempts before a delivery is dead-lettered
+	BaseBackoff  time.Duration `mapstructure:"base_backoff"`  // Delay before the first retry, doubled per attempt
+	MaxBackoff   time.Duration `mapstructure:"max_backoff"`   // Upper bound for the retry delay
+	PollInterval time.Duration `mapstructure:"poll_interval"` // How often the table is scanned for due deliveries
+	BatchSize    int           `mapstructure:"batch_size"`    // Maximum deliveries claimed per scan
+	Lease        time.Duration `mapstructure:"lease"`         // How long a claim lasts before another server may take the delivery over
+}
+
+// DefaultOutboxConfig returns the outbox settings used when none are
+// configured
+func DefaultOutboxConfig() OutboxConfig {
+	return OutboxConfig{
+		Workers:      4,
+		MaxAttempts:  5,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   30 * time.Minute,
+		PollInterval: 5 * time.Second,
+		BatchSize:    50,
+		Lease:        5 * time.Minute,
+	}
+}
+
+// Validate checks that the settings are usable
+func (c OutboxConfig) Validate() error {
+	switch {
+	case c.Workers < 1:
+		return fmt.Errorf("notifications outbox workers must be positive")
+	case c.MaxAttempts < 1:
+		return fmt.Errorf("notifications outbox max_attempts must be positive")
+	case c.BaseBackoff <= 0:
+		return fmt.Errorf("notifications outbox base_backoff must be positive")
+	case c.MaxBackoff < c.BaseBackoff:
+		return fmt.Errorf("notifications outbox max_backoff must not be less than base_backoff")
+	case c.PollInterval <= 0:
+		return fmt.Errorf("notifications outbox poll_interval must be positive")
+	case c.BatchSize < 1:
+		return fmt.Errorf("notifications outbox batch_size must be positive")
+	case c.Lease < time.Minute:
+		return fmt.Errorf("notifications outbox lease must be at least 1m, longer than a delivery takes")
+	}
+	return nil
+}
+
+// Outbox persists notifications in the database and delivers them from a
+// worker pool, so a failing channel neither loses the notification nor
+// holds up the code that raised the alert. Servers sharing the database
+// share the queue: each delivery is claimed by one server for the lease
+// and only taken over by another once the lease has expired.
+type Outbox struct {
+	db        *gorm.DB
+	notifiers *Registry
+	config    OutboxConfig
+	jobs      chan *models.NotificationDelivery
+	wake      chan struct{}
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+}
+
+func NewOutbox(db *gorm.DB, notifiers *Registry, config OutboxConfig) *Outbox {
+	return &Outbox{
+		db:        db,
+		notifiers: notifiers,
+		config:    config,
+		jobs:      make(chan *models.NotificationDelivery),
+		wake:      make(chan struct{}, 1),
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Notifiers returns the registry deliveries are sent through
+func (o *Outbox) Notifiers() *Registry {
+	return o.notifiers
+}
+
+// Enqueue queues the alert for delivery to the given channels, or to every
+// configured channel when none are given. Pass a transaction as db to make
+// the deliveries part of the same commit as the alert change.
+func (o *Outbox) Enqueue(db *gorm.DB, alert *models.Alert, channels ...string) error {
+	if len(channels) == 0 {
+		for _, n := range o.notifiers.Notifiers() {
+			channels = append(channels, n.Name())
+		}
+	}
+	if len(channels) == 0 {
+		return nil
+	}
+
+	snapshot, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %v", err)
+	}
+
+	event := models.DeliveryEventFiring
+	if alert.Status == models.AlertStatusResolved {
+		event = models.DeliveryEventResolved
+	}
+
+	now := time.Now()
+	deliveries := make([]models.NotificationDelivery, 0, len(channels))
+	for _, channel := range channels {
+		deliveries = append(deliveries, models.NotificationDelivery{
+			AlertID:       alert.ID,
+			Channel:       channel,
+			Event:         event,
+			Status:        models.DeliveryStatusPending,
+			AlertSnapshot: string(snapshot),
+			NextAttemptAt: now,
+		})
+	}
+
+	if err := db.Create(&deliveries).Error; err != nil {
+		return fmt.Errorf("failed to queue notifications: %v", err)
+	}
+
+	// Let the dispatcher pick the new deliveries up without waiting a tick
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// EnqueueTo queues the alert for delivery to specific recipients through a
+// channel that supports addressing them, see RecipientNotifier.
+func (o *Outbox) EnqueueTo(db *gorm.DB, alert *models.Alert, channel string, recipients []string) error {
+	snapshot, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %v", err)
+	}
+
+	delivery := models.NotificationDelivery{
+		AlertID:       alert.ID,
+		Channel:       channel,
+		Recipients:    strings.Join(recipients, ","),
+		Event:         models.DeliveryEventFiring,
+		Status:        models.DeliveryStatusPending,
+		AlertSnapshot: string(snapshot),
+		NextAttemptAt: time.Now(),
+	}
+	if err := db.Create(&delivery).Error; err != nil {
+		return fmt.Errorf("failed to queue notification: %v", err)
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Start launches the dispatcher and the worker pool. Deliveries claimed by
+// a server that stopped without finishing them are claimed again once
+// their lease has expired.
+func (o *Outbox) Start() error {
+	for i := 0; i < o.config.Workers; i++ {
+		o.wg.Add(1)
+		go o.worker()
+	}
+
+	o.wg.Add(1)
+	go o.dispatch()
+	return nil
+}
+
+// Stop waits for in-flight deliveries to finish
+func (o *Outbox) Stop() {
+	close(o.stopChan)
+	o.wg.Wait()
+}
+
+func (o *Outbox) dispatch() {
+	defer o.wg.Done()
+	defer close(o.jobs)
+
+	ticker := time.NewTicker(o.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		deliveries, err := o.claimDue()
+		if err != nil {
+			log.Printf("Error claiming notification deliveries: %v", err)
+		}
+		for i := range deliveries {
+			select {
+			case o.jobs <- &deliveries[i]:
+			case <-o.stopChan:
+				// Hand unsent claims back for the next start
+				o.release(deliveries[i:])
+				return
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-o.wake:
+		case <-o.stopChan:
+			return
+		}
+	}
+}
+
+// claimableQuery matches due deliveries and deliveries whose claim has
+// expired. Claims from before leases were recorded have no lease and are
+// taken over too.
+const claimableQuery = "(status = ? AND next_attempt_at <= ?) OR (status = ? AND (lease_until IS NULL OR lease_until < ?))"
+
+// claimDue marks claimable deliveries as SENDING by this server for the
+// lease so no other dispatcher picks them up, and returns the ones this
+// call managed to claim.
+func (o *Outbox) claimDue() ([]models.NotificationDelivery, error) {
+	now := time.Now()
+	args := []interface{}{models.DeliveryStatusPending, now, models.DeliveryStatusSending, now}
+
+	var due []models.NotificationDelivery
+	if err := o.db.Where(claimableQuery, args...).
+		Order("next_attempt_at").Limit(o.config.BatchSize).Find(&due).Error; err != nil {
+		return nil, err
+	}
+
+	leaseUntil := now.Add(o.config.Lease)
+	claimed := due[:0]
+	for _, d := range due {
+		res := o.db.Model(&models.NotificationDelivery{}).
+			Where("id = ?", d.ID).Where(claimableQuery, args...).
+			Updates(map[string]interface{}{
+				"status":      models.DeliveryStatusSending,
+				"claimed_by":  database.InstanceID,
+				"lease_until": leaseUntil,
+			})
+		if res.Error != nil {
+			return claimed, res.Error
+		}
+		if res.RowsAffected == 1 {
+			if d.Status == models.DeliveryStatusSending {
+				log.Printf("Taking over %s notification for alert %d from %s after its lease expired",
+					d.Channel, d.AlertID, d.ClaimedBy)
+			}
+			d.Status = models.DeliveryStatusSending
+			d.ClaimedBy = database.InstanceID
+			d.LeaseUntil = &leaseUntil
+			claimed = append(claimed, d)
+		}
+	}
+	return claimed, nil
+}
+
+// release hands claims this server has not sent back to the queue
+func (o *Outbox) release(deliveries []models.NotificationDelivery) {
+	for _, d := range deliveries {
+		o.db.Model(&models.NotificationDelivery{}).
+			Where("id = ? AND claimed_by = ?", d.ID, database.InstanceID).
+			Updates(map[string]interface{}{
+				"status":      models.DeliveryStatusPending,
+				"claimed_by":  "",
+				"lease_until": nil,
+			})
+	}
+}
+
+func (o *Outbox) worker() {
+	defer o.wg.Done()
+	for delivery := range o.jobs {
+		if err := o.deliver(delivery); err != nil {
+			log.Printf("Error recording delivery %d: %v", delivery.ID, err)
+		}
+	}
+}
+
+// deliver sends one delivery, records the attempt and schedules a retry or
+// dead-letters the delivery on failure.
+func (o *Outbox) deliver(delivery *models.NotificationDelivery) error {
+	started := time.Now()
+	sendErr := o.send(delivery)
+
+	delivery.AttemptCount++
+	attempt := models.DeliveryAttempt{
+		DeliveryID: delivery.ID,
+		Attempt:    delivery.AttemptCount,
+		Success:    sendErr == nil,
+		Duration:   time.Since(started),
+	}
+
+	now := time.Now()
+	if sendErr == nil {
+		delivery.Status = models.DeliveryStatusSent
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	} else {
+		attempt.Error = sendErr.Error()
+		delivery.LastError = sendErr.Error()
+		if !IsRetryable(sendErr) {
+			delivery.Status = models.DeliveryStatusDead
+			log.Printf("Giving up on %s notification for alert %d, the failure is permanent: %v",
+				delivery.Channel, delivery.AlertID, sendErr)
+		} else if retry := o.retryPolicy(delivery.Channel); delivery.AttemptCount >= retry.MaxAttempts {
+			delivery.Status = models.DeliveryStatusDead
+			log.Printf("Giving up on %s notification for alert %d after %d attempts: %v",
+				delivery.Channel, delivery.AlertID, delivery.AttemptCount, sendErr)
+		} else {
+			delivery.Status = models.DeliveryStatusPending
+			delivery.NextAttemptAt = now.Add(o.backoff(retry.BaseBackoff, delivery.AttemptCount))
+		}
+	}
+
+	delivery.ClaimedBy = ""
+	delivery.LeaseUntil = nil
+
+	var takenOver bool
+	err := o.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attempt).Error; err != nil {
+			return err
+		}
+		// A server that took the delivery over after the lease expired
+		// owns its state now
+		res := tx.Model(&models.NotificationDelivery{}).
+			Where("id = ? AND claimed_by = ?", delivery.ID, database.InstanceID).
+			Select("status", "attempt_count", "next_attempt_at", "last_error", "delivered_at", "claimed_by", "lease_until").
+			Updates(delivery)
+		takenOver = res.Error == nil && res.RowsAffected == 0
+		return res.Error
+	})
+	if err == nil && takenOver {
+		return fmt.Errorf("delivery was taken over by another server after its lease expired")
+	}
+	return err
+}
+
+func (o *Outbox) send(delivery *models.NotificationDelivery) error {
+	n, ok := o.notifiers.Get(delivery.Channel)
+	if !ok {
+		return fmt.Errorf("channel %q is not configured", delivery.Channel)
+	}
+
+	var alert models.Alert
+	if err := json.Unmarshal([]byte(delivery.AlertSnapshot), &alert); err != nil {
+		return Permanent(fmt.Errorf("failed to decode alert: %v", err))
+	}
+
+	if delivery.Recipients == "" {
+		return n.Notify(&alert)
+	}
+
+	rn, ok := n.(RecipientNotifier)
+	if !ok {
+		return Permanent(fmt.Errorf("channel %q cannot address individual recipients", delivery.Channel))
+	}
+	return rn.NotifyRecipients(&alert, strings.Split(delivery.Recipients, ","))
+}
+
+// retryPolicy returns the retry limits of a channel, the outbox defaults
+// unless the channel configures its own
+func (o *Outbox) retryPolicy(channel string) RetryPolicy {
+	policy := RetryPolicy{MaxAttempts: o.config.MaxAttempts, BaseBackoff: o.config.BaseBackoff}
+	n, ok := o.notifiers.Get(channel)
+	if !ok {
+		return policy
+	}
+	if rn, ok := n.(RetryNotifier); ok {
+		own := rn.RetryPolicy()
+		if own.MaxAttempts > 0 {
+			policy.MaxAttempts = own.MaxAttempts
+		}
+		if own.BaseBackoff > 0 {
+			policy.BaseBackoff = own.BaseBackoff
+		}
+	}
+	return policy
+}
+
+// backoff returns the delay before the next attempt after the given number
+// of failed attempts, starting from base.
+func (o *Outbox) backoff(base time.Duration, attempts int) time.Duration {
+	d := base
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= o.config.MaxBackoff {
+			return o.config.MaxBackoff
+		}
+	}
+	return d
+}
@@ -10,7 +10,11 @@ import (
 	"containereye/internal/models"
 )
 
+// webhookClient bounds how long a slow endpoint can hold up a delivery
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
 type SlackNotifier struct {
+	name       string
 	WebhookURL string
 	Channel    string
 	Username   string
@@ -38,24 +42,57 @@ type Field struct {
 	Short bool   `json:"short"`
 }
 
-func NewSlackNotifier(webhookURL, channel, username string) *SlackNotifier {
+func NewSlackNotifier(name, webhookURL, channel, username string) *SlackNotifier {
 	return &SlackNotifier{
+		name:       name,
 		WebhookURL: webhookURL,
 		Channel:    channel,
 		Username:   username,
 	}
 }
 
+// newSlackFromConfig builds a Slack notifier from a handler config. A
+// webhook_url selects an incoming webhook, a token selects the Web API.
+func newSlackFromConfig(name string, config map[string]interface{}) (Notifier, error) {
+	channel := configString(config, "channel")
+
+	if webhookURL := configString(config, "webhook_url"); webhookURL != "" {
+		return NewSlackNotifier(name, webhookURL, channel, configString(config, "username")), nil
+	}
+
+	if token := configString(config, "token"); token != "" {
+		if channel == "" {
+			return nil, fmt.Errorf("channel is required when using a slack token")
+		}
+		return NewSlackAPINotifier(name, token, channel), nil
+	}
+
+	return nil, fmt.Errorf("either webhook_url or token is required")
+}
+
+func (s *SlackNotifier) Name() string {
+	return s.name
+}
+
 func (s *SlackNotifier) Notify(alert *models.Alert) error {
+	title := fmt.Sprintf("ContainerEye Alert: %s", alert.RuleName)
+	color := getAlertColor(alert.Level)
+	emoji := getAlertEmoji(alert.Level)
+	if alert.Status == models.AlertStatusResolved {
+		title = fmt.Sprintf("ContainerEye Alert Resolved: %s", alert.RuleName)
+		color = resolvedColor
+		emoji = ":white_check_mark:"
+	}
+
 	// Create message
 	msg := &SlackMessage{
 		Channel:  s.Channel,
 		Username: s.Username,
-		IconEmoji: getAlertEmoji(alert.Level),
+		IconEmoji: emoji,
 		Attachments: []Attachment{
 			{
-				Color:  getAlertColor(alert.Level),
-				Title:  fmt.Sprintf("ContainerEye Alert: %s", alert.RuleName),
+				Color:  color,
+				Title:  title,
 				Text:   alert.Message,
 				Fields: []Field{
 					{
@@ -85,7 +122,7 @@ func (s *SlackNotifier) Notify(alert *models.Alert) error {
 					},
 					{
 						Title: "Duration",
-						Value: alert.EndTime.Sub(alert.StartTime).String(),
+						Value: alertDuration(alert).String(),
 						Short: true,
 					},
 				},
@@ -102,19 +139,34 @@ func (s *SlackNotifier) Notify(alert *models.Alert) error {
 	}
 
 	// Send request
-	resp, err := http.Post(s.WebhookURL, "application/json", bytes.NewBuffer(payload))
+	resp, err := webhookClient.Post(s.WebhookURL, "application/json", bytes.NewBuffer(payload))
 	if err != nil {
 		return fmt.Errorf("failed to send slack message: %v", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("slack API returned non-200 status code: %d", resp.StatusCode)
+		err := fmt.Errorf("slack API returned non-200 status code: %d", resp.StatusCode)
+		// A revoked webhook or a rejected message stays rejected
+		if isClientError(resp.StatusCode) {
+			return Permanent(err)
+		}
+		return err
 	}
 
 	return nil
 }
 
+// alertDuration is how long the alert has been (or was) firing
+func alertDuration(alert *models.Alert) time.Duration {
+	if alert.EndTime.IsZero() {
+		return time.Since(alert.StartTime).Round(time.Second)
+	}
+	return alert.EndTime.Sub(alert.StartTime).Round(time.Second)
+}
+
+const resolvedColor = "#2EB886"
+
 func getAlertColor(level models.AlertLevel) string {
 	switch level {
 	case models.AlertLevelCritical:
@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"containereye/internal/models"
+	"github.com/slack-go/slack"
+)
+
+// SlackAPINotifier posts alerts through the Slack Web API using a bot token
+type SlackAPINotifier struct {
+	name    string
+	client  *slack.Client
+	Channel string
+}
+
+func NewSlackAPINotifier(name, token, channel string) *SlackAPINotifier {
+	return &SlackAPINotifier{
+		name:    name,
+		client:  slack.New(token),
+		Channel: channel,
+	}
+}
+
+func (s *SlackAPINotifier) Name() string {
+	return s.name
+}
+
+func (s *SlackAPINotifier) Notify(alert *models.Alert) error {
+	color := getAlertColor(alert.Level)
+	pretext := ""
+	if alert.Status == models.AlertStatusResolved {
+		color = resolvedColor
+		pretext = fmt.Sprintf("Resolved: %s", alert.RuleName)
+	}
+
+	attachment := slack.Attachment{
+		Color:   color,
+		Pretext: pretext,
+		Fields: []slack.AttachmentField{
+			{
+				Title: "Container",
+				Value: alert.ContainerName,
+				Short: true,
+			},
+			{
+				Title: "Metric",
+				Value: alert.Metric,
+				Short: true,
+			},
+			{
+				Title: "Current Value",
+				Value: fmt.Sprintf("%.2f", alert.CurrentValue),
+				Short: true,
+			},
+			{
+				Title: "Threshold",
+				Value: fmt.Sprintf("%.2f", alert.Threshold),
+				Short: true,
+			},
+		},
+		Footer: "Container Monitor Alert",
+		Ts:     json.Number(strconv.FormatInt(time.Now().Unix(), 10)),
+	}
+
+	_, _, err := s.client.PostMessage(
+		s.Channel,
+		slack.MsgOptionAttachments(attachment),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to post slack message: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"containereye/internal/models"
+)
+
+const (
+	defaultWebhookTimeout     = 10 * time.Second
+	defaultSignatureHeader    = "X-ContainerEye-Signature"
+	defaultWebhookMethod      = http.MethodPost
+	defaultWebhookContentType = "application/json"
+)
+
+// defaultWebhookTemplate is used when no template is configured and sends
+// the alert itself as JSON.
+const defaultWebhookTemplate = `{{ json . }}`
+
+// WebhookNotifier posts alerts to an arbitrary HTTP endpoint. The request
+// body is rendered from a text/template executed against the alert and can
+// optionally be signed with HMAC-SHA256 so the receiver can verify it.
+type WebhookNotifier struct {
+	name            string
+	URL             string
+	Method          string
+	Headers         map[string]string
+	Secret          string
+	SignatureHeader string
+	Retry           RetryPolicy
+	template        *template.Template
+	client          *http.Client
+}
+
+// newWebhookFromConfig builds a webhook notifier from a handler config
+func newWebhookFromConfig(name string, config map[string]interface{}) (Notifier, error) {
+	url := configString(config, "url")
+	if url == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+
+	body := configString(config, "template")
+	if file := configString(config, "template_file"); file != "" {
+		if body != "" {
+			return nil, fmt.Errorf("template and template_file are mutually exclusive")
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template_file: %v", err)
+		}
+		body = string(data)
+	}
+	if body == "" {
+		body = defaultWebhookTemplate
+	}
+
+	tmpl, err := template.New(name).Funcs(webhookTemplateFuncs).Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %v", err)
+	}
+
+	timeout, err := configDuration(config, "timeout", defaultWebhookTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// Retries are made by the outbox, max_retries and retry_backoff only
+	// replace its limits for this handler
+	var retry RetryPolicy
+	if _, ok := config["max_retries"]; ok {
+		retries, err := configInt(config, "max_retries", 0)
+		if err != nil {
+			return nil, err
+		}
+		if retries < 0 {
+			return nil, fmt.Errorf("max_retries must not be negative")
+		}
+		retry.MaxAttempts = retries + 1
+	}
+	retry.BaseBackoff, err = configDuration(config, "retry_backoff", 0)
+	if err != nil {
+		return nil, err
+	}
+	if retry.BaseBackoff < 0 {
+		return nil, fmt.Errorf("retry_backoff must not be negative")
+	}
+
+	method := strings.ToUpper(configString(config, "method"))
+	if method == "" {
+		method = defaultWebhookMethod
+	}
+
+	headers := configStringMap(config, "headers")
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	if _, ok := headers["Content-Type"]; !ok {
+		headers["Content-Type"] = defaultWebhookContentType
+	}
+
+	signatureHeader := configString(config, "signature_header")
+	if signatureHeader == "" {
+		signatureHeader = defaultSignatureHeader
+	}
+
+	return &WebhookNotifier{
+		name:            name,
+		URL:             url,
+		Method:          method,
+		Headers:         headers,
+		Secret:          configString(config, "secret"),
+		SignatureHeader: signatureHeader,
+		Retry:           retry,
+		template:        tmpl,
+		client:          &http.Client{Timeout: timeout},
+	}, nil
+}
+
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"formatTime": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+}
+
+func (w *WebhookNotifier) Name() string {
+	return w.name
+}
+
+// RetryPolicy returns the retry limits configured for the handler
+func (w *WebhookNotifier) RetryPolicy() RetryPolicy {
+	return w.Retry
+}
+
+// Notify performs a single delivery attempt. Client errors other than 408
+// and 429 are permanent since the same payload would be rejected again.
+func (w *WebhookNotifier) Notify(alert *models.Alert) error {
+	var body bytes.Buffer
+	if err := w.template.Execute(&body, alert); err != nil {
+		return Permanent(fmt.Errorf("failed to render webhook payload: %v", err))
+	}
+	payload := body.Bytes()
+
+	req, err := http.NewRequest(w.Method, w.URL, bytes.NewReader(payload))
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to create request: %v", err))
+	}
+
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	if w.Secret != "" {
+		req.Header.Set(w.SignatureHeader, "sha256="+Sign(w.Secret, payload))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook delivery failed: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err = fmt.Errorf("webhook delivery failed: endpoint returned status %d", resp.StatusCode)
+	if isClientError(resp.StatusCode) {
+		return Permanent(err)
+	}
+	return err
+}
+
+// isClientError reports whether a response status rejects the request
+// itself, so that sending it again fails the same way
+func isClientError(status int) bool {
+	return status >= 400 && status < 500 &&
+		status != http.StatusRequestTimeout && status != http.StatusTooManyRequests
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of payload keyed with secret, as
+// sent in the signature header of webhook requests.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
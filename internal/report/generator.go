@@ -9,11 +9,13 @@ import (
 	
 	"github.com/jordan-wright/email"
 	"containereye/internal/models"
+	"containereye/internal/retention"
 	"gorm.io/gorm"
 )
 
 type ReportGenerator struct {
 	db        *gorm.DB
+	retention retention.Policy
 	templates map[string]*template.Template
 }
 
@@ -62,7 +64,7 @@ type TimeSeriesPoint struct {
 	Value     float64
 }
 
-func NewReportGenerator(db *gorm.DB) (*ReportGenerator, error) {
+func NewReportGenerator(db *gorm.DB, policy retention.Policy) (*ReportGenerator, error) {
 	templates := make(map[string]*template.Template)
 	
 	// Load HTML templates
@@ -80,6 +82,7 @@ func NewReportGenerator(db *gorm.DB) (*ReportGenerator, error) {
 	
 	return &ReportGenerator{
 		db:        db,
+		retention: policy,
 		templates: templates,
 	}, nil
 }
@@ -133,10 +136,10 @@ func (g *ReportGenerator) collectReportData(startTime, endTime time.Time) (*Repo
 	// Process alerts
 	data.AlertSummary = g.processAlerts(alerts)
 	
-	// Get container stats
-	var stats []models.ContainerStats
-	if err := g.db.Where("timestamp BETWEEN ? AND ?", startTime, endTime).
-		Find(&stats).Error; err != nil {
+	// Get container stats, rolled up for ranges beyond a few hours
+	resolution := g.retention.Resolution(startTime, endTime)
+	stats, err := retention.Stats(g.db, resolution, retention.StatsQuery{Start: startTime, End: endTime})
+	if err != nil {
 		return nil, err
 	}
 	
@@ -0,0 +1,340 @@
+package retention
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"containereye/internal/database"
+	"containereye/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// compactionDelay leaves the newest samples to the next run, so that a
+	// sample committed while a run is reading is not skipped
+	compactionDelay = time.Minute
+
+	// compactionBatch bounds how much new raw data is rolled up at once,
+	// e.g. on the first run over an existing database
+	compactionBatch = 6 * time.Hour
+)
+
+// rollupResolutions are the resolutions raw stats are rolled up into
+var rollupResolutions = []time.Duration{ResolutionMinute, ResolutionHour}
+
+// Compactor periodically rolls raw container stats up into 1-minute and
+// 1-hour buckets and removes stats that are older than the policy keeps
+// them. Buckets are recomputed from the raw samples whenever new samples
+// arrive for them, so stats pushed late by an agent are included too. When
+// several servers share the database only the one holding the compactor
+// lease runs.
+type Compactor struct {
+	db       *gorm.DB
+	policy   Policy
+	stopChan chan struct{}
+}
+
+// NewCompactor returns a compactor applying a policy that has been validated
+func NewCompactor(db *gorm.DB, policy Policy) *Compactor {
+	return &Compactor{
+		db:       db,
+		policy:   policy,
+		stopChan: make(chan struct{}),
+	}
+}
+
+const compactorLease = "compactor"
+
+func (c *Compactor) Start() {
+	go func() {
+		c.runLeased()
+
+		ticker := time.NewTicker(c.policy.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.runLeased()
+			case <-c.stopChan:
+				if err := database.ReleaseLease(c.db, compactorLease); err != nil {
+					log.Printf("Error releasing the compactor lease: %v", err)
+				}
+				return
+			}
+		}
+	}()
+}
+
+// runLeased runs a compaction if this server holds the compactor lease
+func (c *Compactor) runLeased() {
+	held, err := database.AcquireLease(c.db, compactorLease, 3*c.policy.Interval)
+	if err != nil {
+		log.Printf("Error acquiring the compactor lease: %v", err)
+		return
+	}
+	if !held {
+		return
+	}
+	if err := c.Run(); err != nil {
+		log.Printf("Error compacting stats: %v", err)
+	}
+}
+
+func (c *Compactor) Stop() {
+	close(c.stopChan)
+}
+
+// Run rolls up the raw stats stored since the last run and removes expired
+// stats at every resolution
+func (c *Compactor) Run() error {
+	until := time.Now().Add(-compactionDelay)
+	for _, resolution := range rollupResolutions {
+		if err := c.rollup(resolution, until); err != nil {
+			return fmt.Errorf("failed to roll up %s stats: %v", FormatResolution(resolution), err)
+		}
+	}
+	return c.prune()
+}
+
+// compactedThrough returns the creation time up to which raw stats have
+// been rolled up at a resolution
+func (c *Compactor) compactedThrough(resolution time.Duration) (time.Time, error) {
+	var through []time.Time
+	if err := c.db.Model(&models.StatsRollup{}).
+		Where("resolution = ?", int(resolution.Seconds())).
+		Order("compacted_through desc").
+		Limit(1).
+		Pluck("compacted_through", &through).Error; err != nil {
+		return time.Time{}, err
+	}
+	if len(through) == 0 {
+		return time.Time{}, nil
+	}
+	return through[0], nil
+}
+
+// rollup summarizes the buckets that received raw stats created after the
+// last run and up to until, in batches of compactionBatch
+func (c *Compactor) rollup(resolution time.Duration, until time.Time) error {
+	since, err := c.compactedThrough(resolution)
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Skip ahead to the next new sample, there may be long gaps
+		var next []time.Time
+		if err := c.db.Model(&models.ContainerStats{}).
+			Where("created_at > ? AND created_at <= ?", since, until).
+			Order("created_at").
+			Limit(1).
+			Pluck("created_at", &next).Error; err != nil {
+			return err
+		}
+		if len(next) == 0 {
+			return nil
+		}
+
+		batchEnd := next[0].Add(compactionBatch)
+		if batchEnd.After(until) {
+			batchEnd = until
+		}
+		if err := c.rollupBatch(resolution, since, batchEnd); err != nil {
+			return err
+		}
+		since = batchEnd
+	}
+}
+
+type bucketKey struct {
+	containerID string
+	start       time.Time
+}
+
+// rollupBatch recomputes the buckets of the raw stats created after since
+// and up to through
+func (c *Compactor) rollupBatch(resolution time.Duration, since, through time.Time) error {
+	var created []struct {
+		ContainerID string
+		Timestamp   time.Time
+	}
+	if err := c.db.Model(&models.ContainerStats{}).
+		Select("container_id, timestamp").
+		Where("created_at > ? AND created_at <= ?", since, through).
+		Scan(&created).Error; err != nil {
+		return err
+	}
+
+	// Once compacted, the samples older than the raw retention have been
+	// removed, so late samples can no longer complete their buckets. The
+	// first compaction rolls up all existing stats.
+	var cutoff time.Time
+	if !since.IsZero() {
+		cutoff = time.Now().Add(-c.policy.Retention(ResolutionRaw))
+	}
+
+	changed := make(map[bucketKey]bool)
+	ranges := make(map[string][2]time.Time) // First and last changed bucket per container
+	for _, row := range created {
+		if row.Timestamp.Before(cutoff) {
+			continue
+		}
+		key := bucketKey{row.ContainerID, row.Timestamp.Truncate(resolution)}
+		changed[key] = true
+
+		r, ok := ranges[key.containerID]
+		if !ok || key.start.Before(r[0]) {
+			r[0] = key.start
+		}
+		if !ok || key.start.After(r[1]) {
+			r[1] = key.start
+		}
+		ranges[key.containerID] = r
+	}
+
+	var rollups []models.StatsRollup
+	for containerID, r := range ranges {
+		var samples []models.ContainerStats
+		if err := c.db.Where("container_id = ? AND timestamp >= ? AND timestamp < ?",
+			containerID, r[0], r[1].Add(resolution)).
+			Order("timestamp").
+			Find(&samples).Error; err != nil {
+			return err
+		}
+
+		buckets := make(map[time.Time][]models.ContainerStats)
+		for _, sample := range samples {
+			start := sample.Timestamp.Truncate(resolution)
+			if changed[bucketKey{containerID, start}] {
+				buckets[start] = append(buckets[start], sample)
+			}
+		}
+		for start, bucket := range buckets {
+			rollup := summarize(bucket)
+			rollup.Resolution = int(resolution.Seconds())
+			rollup.Timestamp = start
+			rollup.CompactedThrough = through
+			rollups = append(rollups, rollup)
+		}
+	}
+
+	if len(rollups) == 0 {
+		return nil
+	}
+	return c.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "container_id"}, {Name: "resolution"}, {Name: "timestamp"}},
+		UpdateAll: true,
+	}).CreateInBatches(rollups, 100).Error
+}
+
+// prune removes the stats that are older than their resolution's
+// retention. Raw stats are only removed once both rollups include them.
+func (c *Compactor) prune() error {
+	now := time.Now()
+
+	through := now
+	for _, resolution := range rollupResolutions {
+		t, err := c.compactedThrough(resolution)
+		if err != nil {
+			return err
+		}
+		if t.Before(through) {
+			through = t
+		}
+	}
+
+	result := c.db.Unscoped().
+		Where("timestamp < ? AND created_at <= ?", now.Add(-c.policy.Retention(ResolutionRaw)), through).
+		Delete(&models.ContainerStats{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove expired stats: %v", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Removed %d expired raw stats", result.RowsAffected)
+	}
+
+	for _, resolution := range rollupResolutions {
+		result := c.db.
+			Where("resolution = ? AND timestamp < ?", int(resolution.Seconds()), now.Add(-c.policy.Retention(resolution))).
+			Delete(&models.StatsRollup{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to remove expired %s rollups: %v", FormatResolution(resolution), result.Error)
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("Removed %d expired %s rollups", result.RowsAffected, FormatResolution(resolution))
+		}
+	}
+	return nil
+}
+
+// summarize rolls the samples of one bucket, ordered by time, up into a
+// StatsRollup without its resolution and timestamp
+func summarize(samples []models.ContainerStats) models.StatsRollup {
+	last := samples[len(samples)-1]
+	rollup := models.StatsRollup{
+		HostID:        last.HostID,
+		ContainerID:   last.ContainerID,
+		ContainerName: last.ContainerName,
+		Image:         last.Image,
+		Samples:       len(samples),
+		MemoryLimit:   last.MemoryLimit,
+		NetworkRx:     last.NetworkRx,
+		NetworkTx:     last.NetworkTx,
+		NetworkTotal:  last.NetworkTotal,
+		BlockRead:     last.BlockRead,
+		BlockWrite:    last.BlockWrite,
+		DiskIOTotal:   last.DiskIOTotal,
+	}
+
+	metric := func(value func(*models.ContainerStats) float64) models.MetricSummary {
+		values := make([]float64, len(samples))
+		for i := range samples {
+			values[i] = value(&samples[i])
+		}
+		return summarizeValues(values)
+	}
+	rollup.CPUPercent = metric(func(s *models.ContainerStats) float64 { return s.CPUPercent })
+	rollup.MemoryUsage = metric(func(s *models.ContainerStats) float64 { return float64(s.MemoryUsage) })
+	rollup.MemoryPercent = metric(func(s *models.ContainerStats) float64 { return s.MemoryPercent })
+	rollup.NetworkRxRate = metric(func(s *models.ContainerStats) float64 { return s.NetworkRxRate })
+	rollup.NetworkTxRate = metric(func(s *models.ContainerStats) float64 { return s.NetworkTxRate })
+	rollup.NetworkRate = metric(func(s *models.ContainerStats) float64 { return s.NetworkRate })
+	rollup.BlockReadRate = metric(func(s *models.ContainerStats) float64 { return s.BlockReadRate })
+	rollup.BlockWriteRate = metric(func(s *models.ContainerStats) float64 { return s.BlockWriteRate })
+	rollup.DiskIORate = metric(func(s *models.ContainerStats) float64 { return s.DiskIORate })
+	rollup.PIDs = metric(func(s *models.ContainerStats) float64 { return float64(s.PIDs) })
+	return rollup
+}
+
+func summarizeValues(values []float64) models.MetricSummary {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	return models.MetricSummary{
+		Min: sorted[0],
+		Avg: sum / float64(len(sorted)),
+		Max: sorted[len(sorted)-1],
+		P95: Percentile(sorted, 95),
+	}
+}
+
+// Percentile returns the q-th percentile of values, interpolating linearly
+// between the closest ranks. Values must not be empty.
+func Percentile(values []float64, q float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := q / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
@@ -0,0 +1,145 @@
+// Package retention bounds the size of the stored container stats. Raw
+// samples are rolled up into 1-minute and 1-hour summaries by a background
+// compaction job and removed once they are older than the configured
+// retention, and queries read from the resolution that covers their range.
+package retention
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// ResolutionRaw reads the samples as collected
+	ResolutionRaw time.Duration = 0
+	// ResolutionMinute reads 1-minute rollups
+	ResolutionMinute = time.Minute
+	// ResolutionHour reads 1-hour rollups
+	ResolutionHour = time.Hour
+)
+
+const (
+	defaultRawDays    = 7
+	defaultMinuteDays = 30
+	defaultHourDays   = 365
+
+	defaultCompactionInterval = 10 * time.Minute
+
+	// Ranges up to these spans are read at the finer resolution, longer
+	// ranges would return too many points per container
+	maxRawSpan    = 6 * time.Hour
+	maxMinuteSpan = 7 * 24 * time.Hour
+)
+
+const day = 24 * time.Hour
+
+// Policy configures how long stats are kept at each resolution. Zero values
+// take the defaults: raw samples for 7 days, 1-minute rollups for 30 days
+// and 1-hour rollups for a year.
+type Policy struct {
+	RawDays    int           `mapstructure:"raw_days"`
+	MinuteDays int           `mapstructure:"minute_days"`
+	HourDays   int           `mapstructure:"hour_days"`
+	Interval   time.Duration `mapstructure:"interval"` // How often the compaction runs
+}
+
+// DefaultPolicy returns the retention used when none is configured
+func DefaultPolicy() Policy {
+	return Policy{
+		RawDays:    defaultRawDays,
+		MinuteDays: defaultMinuteDays,
+		HourDays:   defaultHourDays,
+		Interval:   defaultCompactionInterval,
+	}
+}
+
+// Validate fills in the defaults of unset fields and checks that every
+// resolution is kept at least as long as the finer ones.
+func (p *Policy) Validate() error {
+	defaults := DefaultPolicy()
+	if p.RawDays == 0 {
+		p.RawDays = defaults.RawDays
+	}
+	if p.MinuteDays == 0 {
+		p.MinuteDays = defaults.MinuteDays
+	}
+	if p.HourDays == 0 {
+		p.HourDays = defaults.HourDays
+	}
+	if p.Interval == 0 {
+		p.Interval = defaults.Interval
+	}
+
+	switch {
+	case p.RawDays < 1:
+		return fmt.Errorf("retention raw_days must be at least 1")
+	case p.MinuteDays < p.RawDays:
+		return fmt.Errorf("retention minute_days must be at least raw_days")
+	case p.HourDays < p.MinuteDays:
+		return fmt.Errorf("retention hour_days must be at least minute_days")
+	case p.Interval < 0:
+		return fmt.Errorf("retention interval must be positive")
+	}
+	return nil
+}
+
+// Retention returns how long stats are kept at a resolution
+func (p Policy) Retention(resolution time.Duration) time.Duration {
+	switch resolution {
+	case ResolutionRaw:
+		return time.Duration(p.RawDays) * day
+	case ResolutionMinute:
+		return time.Duration(p.MinuteDays) * day
+	default:
+		return time.Duration(p.HourDays) * day
+	}
+}
+
+// Resolution picks the finest resolution that still holds stats back to
+// start and keeps the number of points per container reasonable. A zero
+// start reads the latest raw samples, a zero end means now.
+func (p Policy) Resolution(start, end time.Time) time.Duration {
+	if start.IsZero() {
+		return ResolutionRaw
+	}
+	now := time.Now()
+	if end.IsZero() || end.After(now) {
+		end = now
+	}
+	age := now.Sub(start)
+	span := end.Sub(start)
+
+	switch {
+	case age <= p.Retention(ResolutionRaw) && span <= maxRawSpan:
+		return ResolutionRaw
+	case age <= p.Retention(ResolutionMinute) && span <= maxMinuteSpan:
+		return ResolutionMinute
+	default:
+		return ResolutionHour
+	}
+}
+
+// ParseResolution parses "raw", "1m" or "1h"
+func ParseResolution(s string) (time.Duration, error) {
+	switch s {
+	case "raw":
+		return ResolutionRaw, nil
+	case "1m":
+		return ResolutionMinute, nil
+	case "1h":
+		return ResolutionHour, nil
+	}
+	return 0, fmt.Errorf("invalid resolution %q, expected raw, 1m or 1h", s)
+}
+
+// FormatResolution is the inverse of ParseResolution
+func FormatResolution(resolution time.Duration) string {
+	switch resolution {
+	case ResolutionRaw:
+		return "raw"
+	case ResolutionMinute:
+		return "1m"
+	default:
+		return "1h"
+	}
+}
@@ -0,0 +1,67 @@
+package retention
+
+import (
+	"time"
+
+	"containereye/internal/models"
+	"gorm.io/gorm"
+)
+
+// StatsQuery selects stored container stats. Empty fields are not filtered on.
+type StatsQuery struct {
+	ContainerID string
+	Start       time.Time
+	End         time.Time
+	Limit       int
+}
+
+// Stats returns the stats selected by q, newest first, at the given
+// resolution. Rollups are returned as stats holding the average of each
+// metric over their bucket, timestamped with the start of the bucket.
+func Stats(db *gorm.DB, resolution time.Duration, q StatsQuery) ([]models.ContainerStats, error) {
+	if resolution == ResolutionRaw {
+		var stats []models.ContainerStats
+		if err := filter(db.Model(&models.ContainerStats{}), q, q.Start).
+			Order("timestamp desc").
+			Find(&stats).Error; err != nil {
+			return nil, err
+		}
+		return stats, nil
+	}
+
+	// Include the bucket the range starts in
+	var start time.Time
+	if !q.Start.IsZero() {
+		start = q.Start.Truncate(resolution)
+	}
+
+	var rollups []models.StatsRollup
+	if err := filter(db.Model(&models.StatsRollup{}), q, start).
+		Where("resolution = ?", int(resolution.Seconds())).
+		Order("timestamp desc").
+		Find(&rollups).Error; err != nil {
+		return nil, err
+	}
+
+	stats := make([]models.ContainerStats, len(rollups))
+	for i := range rollups {
+		stats[i] = rollups[i].Stats()
+	}
+	return stats, nil
+}
+
+func filter(query *gorm.DB, q StatsQuery, start time.Time) *gorm.DB {
+	if q.ContainerID != "" {
+		query = query.Where("container_id = ?", q.ContainerID)
+	}
+	if !start.IsZero() {
+		query = query.Where("timestamp >= ?", start)
+	}
+	if !q.End.IsZero() {
+		query = query.Where("timestamp <= ?", q.End)
+	}
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
+	}
+	return query
+}
@@ -0,0 +1,452 @@
+package retention
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"containereye/internal/models"
+	"gorm.io/gorm"
+)
+
+// Aggregations of a series query
+const (
+	AggAvg  = "avg"
+	AggMin  = "min"
+	AggMax  = "max"
+	AggSum  = "sum"
+	AggP95  = "p95"
+	AggRate = "rate"
+)
+
+// Groupings of a series query
+const (
+	GroupByContainer = "container"
+	GroupByImage     = "image"
+	GroupByHost      = "host"
+)
+
+const (
+	// maxSeriesPoints bounds the number of steps of a series query
+	maxSeriesPoints = 1000
+	// defaultSeriesPoints is the number of steps when no step is given
+	defaultSeriesPoints = 100
+	// minRawStep is the default step lower bound on raw stats, the default
+	// collection interval
+	minRawStep = 30 * time.Second
+	// containerBatch bounds the number of container IDs in one IN clause
+	containerBatch = 500
+)
+
+// seriesMetric reads a metric from raw stats and from rollups. Counters
+// are rolled up as their last value, they are the metrics a rate is taken of.
+type seriesMetric struct {
+	raw     func(*models.ContainerStats) float64
+	rollup  func(*models.StatsRollup) models.MetricSummary
+	counter bool
+}
+
+func counterSummary(v uint64) models.MetricSummary {
+	f := float64(v)
+	return models.MetricSummary{Min: f, Avg: f, Max: f, P95: f}
+}
+
+var seriesMetrics = map[string]seriesMetric{
+	"cpu_percent": {
+		raw:    func(s *models.ContainerStats) float64 { return s.CPUPercent },
+		rollup: func(r *models.StatsRollup) models.MetricSummary { return r.CPUPercent },
+	},
+	"memory_usage": {
+		raw:    func(s *models.ContainerStats) float64 { return float64(s.MemoryUsage) },
+		rollup: func(r *models.StatsRollup) models.MetricSummary { return r.MemoryUsage },
+	},
+	"memory_percent": {
+		raw:    func(s *models.ContainerStats) float64 { return s.MemoryPercent },
+		rollup: func(r *models.StatsRollup) models.MetricSummary { return r.MemoryPercent },
+	},
+	"network_rx_rate": {
+		raw:    func(s *models.ContainerStats) float64 { return s.NetworkRxRate },
+		rollup: func(r *models.StatsRollup) models.MetricSummary { return r.NetworkRxRate },
+	},
+	"network_tx_rate": {
+		raw:    func(s *models.ContainerStats) float64 { return s.NetworkTxRate },
+		rollup: func(r *models.StatsRollup) models.MetricSummary { return r.NetworkTxRate },
+	},
+	"network_rate": {
+		raw:    func(s *models.ContainerStats) float64 { return s.NetworkRate },
+		rollup: func(r *models.StatsRollup) models.MetricSummary { return r.NetworkRate },
+	},
+	"block_read_rate": {
+		raw:    func(s *models.ContainerStats) float64 { return s.BlockReadRate },
+		rollup: func(r *models.StatsRollup) models.MetricSummary { return r.BlockReadRate },
+	},
+	"block_write_rate": {
+		raw:    func(s *models.ContainerStats) float64 { return s.BlockWriteRate },
+		rollup: func(r *models.StatsRollup) models.MetricSummary { return r.BlockWriteRate },
+	},
+	"disk_io_rate": {
+		raw:    func(s *models.ContainerStats) float64 { return s.DiskIORate },
+		rollup: func(r *models.StatsRollup) models.MetricSummary { return r.DiskIORate },
+	},
+	"pids": {
+		raw:    func(s *models.ContainerStats) float64 { return float64(s.PIDs) },
+		rollup: func(r *models.StatsRollup) models.MetricSummary { return r.PIDs },
+	},
+	"memory_limit": {
+		raw:    func(s *models.ContainerStats) float64 { return float64(s.MemoryLimit) },
+		rollup: func(r *models.StatsRollup) models.MetricSummary { return counterSummary(r.MemoryLimit) },
+	},
+	"network_rx": {
+		raw:     func(s *models.ContainerStats) float64 { return float64(s.NetworkRx) },
+		rollup:  func(r *models.StatsRollup) models.MetricSummary { return counterSummary(r.NetworkRx) },
+		counter: true,
+	},
+	"network_tx": {
+		raw:     func(s *models.ContainerStats) float64 { return float64(s.NetworkTx) },
+		rollup:  func(r *models.StatsRollup) models.MetricSummary { return counterSummary(r.NetworkTx) },
+		counter: true,
+	},
+	"network_total": {
+		raw:     func(s *models.ContainerStats) float64 { return float64(s.NetworkTotal) },
+		rollup:  func(r *models.StatsRollup) models.MetricSummary { return counterSummary(r.NetworkTotal) },
+		counter: true,
+	},
+	"block_read": {
+		raw:     func(s *models.ContainerStats) float64 { return float64(s.BlockRead) },
+		rollup:  func(r *models.StatsRollup) models.MetricSummary { return counterSummary(r.BlockRead) },
+		counter: true,
+	},
+	"block_write": {
+		raw:     func(s *models.ContainerStats) float64 { return float64(s.BlockWrite) },
+		rollup:  func(r *models.StatsRollup) models.MetricSummary { return counterSummary(r.BlockWrite) },
+		counter: true,
+	},
+	"disk_io_total": {
+		raw:     func(s *models.ContainerStats) float64 { return float64(s.DiskIOTotal) },
+		rollup:  func(r *models.StatsRollup) models.MetricSummary { return counterSummary(r.DiskIOTotal) },
+		counter: true,
+	},
+}
+
+// SeriesMetrics returns the names of the metrics a series can be queried for
+func SeriesMetrics() []string {
+	names := make([]string, 0, len(seriesMetrics))
+	for name := range seriesMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SeriesQuery aggregates a metric of a set of containers into series of
+// points Step apart, one series per group.
+//
+// avg, min, max and p95 aggregate the samples of all containers of a group
+// within a step; on rolled up stats p95 is taken over the buckets' p95.
+// sum adds up the average of each container of a group, and rate adds up
+// the per-second increase of a counter metric of each container.
+type SeriesQuery struct {
+	Metric      string
+	Containers  []string // Full container IDs
+	Start       time.Time
+	End         time.Time
+	Step        time.Duration // Picked from the range when zero
+	Aggregation string        // avg when empty
+	GroupBy     string        // container when empty
+}
+
+// Validate fills in the defaults of a query and checks it. Resolution is
+// the resolution the stats will be read at.
+func (q *SeriesQuery) Validate(resolution time.Duration) error {
+	metric, ok := seriesMetrics[q.Metric]
+	if !ok {
+		return fmt.Errorf("unknown metric %q, expected one of %s", q.Metric, strings.Join(SeriesMetrics(), ", "))
+	}
+
+	if q.Aggregation == "" {
+		q.Aggregation = AggAvg
+	}
+	switch q.Aggregation {
+	case AggAvg, AggMin, AggMax, AggSum, AggP95:
+	case AggRate:
+		if !metric.counter {
+			return fmt.Errorf("rate needs a counter metric such as network_rx or block_read")
+		}
+	default:
+		return fmt.Errorf("invalid aggregation %q, expected avg, min, max, sum, p95 or rate", q.Aggregation)
+	}
+
+	if q.GroupBy == "" {
+		q.GroupBy = GroupByContainer
+	}
+	switch q.GroupBy {
+	case GroupByContainer, GroupByImage, GroupByHost:
+	default:
+		return fmt.Errorf("invalid grouping %q, expected container, image or host", q.GroupBy)
+	}
+
+	if !q.End.After(q.Start) {
+		return fmt.Errorf("end must be after start")
+	}
+	span := q.End.Sub(q.Start)
+	if q.Step == 0 {
+		minStep := resolution
+		if minStep == ResolutionRaw {
+			minStep = minRawStep
+		}
+		// Round up to whole buckets, so that every step covers the same
+		// number of buckets
+		q.Step = (span/defaultSeriesPoints + minStep - 1) / minStep * minStep
+	}
+	if q.Step < time.Second {
+		return fmt.Errorf("step must be at least 1s")
+	}
+	if span/q.Step > maxSeriesPoints {
+		return fmt.Errorf("step too small, the range would have more than %d points", maxSeriesPoints)
+	}
+	return nil
+}
+
+// point is a raw sample or a rollup bucket of one container
+type point struct {
+	containerID   string
+	containerName string
+	hostID        string
+	image         string
+	timestamp     time.Time
+	samples       int
+	value         models.MetricSummary
+}
+
+// QuerySeries runs a validated query on the stats stored at resolution
+func QuerySeries(db *gorm.DB, resolution time.Duration, q SeriesQuery) (*models.SeriesResult, error) {
+	start := q.Start.Truncate(q.Step)
+	steps := int((q.End.Sub(start) + q.Step - 1) / q.Step)
+
+	result := &models.SeriesResult{
+		Metric:      q.Metric,
+		Aggregation: q.Aggregation,
+		GroupBy:     q.GroupBy,
+		Resolution:  FormatResolution(resolution),
+		Step:        int64(q.Step.Seconds()),
+		Timestamps:  make([]time.Time, steps),
+		Series:      []models.Series{},
+	}
+	for i := range result.Timestamps {
+		result.Timestamps[i] = start.Add(time.Duration(i) * q.Step)
+	}
+
+	// Steps cover [start, End), a rate also needs the sample before the
+	// first step
+	from := start
+	if q.Aggregation == AggRate {
+		from = start.Add(-q.Step)
+	}
+	points, err := loadPoints(db, resolution, seriesMetrics[q.Metric], q.Containers, from, q.End)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*seriesGroup)
+	var keys []string
+	var previous map[string]point
+	if q.Aggregation == AggRate {
+		previous = make(map[string]point)
+	}
+	for _, p := range points {
+		var prev point
+		var hasPrev bool
+		if previous != nil {
+			prev, hasPrev = previous[p.containerID]
+			previous[p.containerID] = p
+		}
+		if p.timestamp.Before(start) {
+			continue
+		}
+
+		labels := seriesLabels(q.GroupBy, p)
+		key := labelKey(labels)
+		g, ok := groups[key]
+		if !ok {
+			g = &seriesGroup{labels: labels, steps: make([]stepAccumulator, steps)}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		acc := &g.steps[int(p.timestamp.Sub(start)/q.Step)]
+		acc.add(p)
+		if hasPrev {
+			acc.addIncrease(p.containerID, prev.value.Avg, p.value.Avg)
+		}
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		g := groups[key]
+		series := models.Series{Labels: g.labels, Values: make([]*float64, steps)}
+		for i := range g.steps {
+			series.Values[i] = g.steps[i].result(q.Aggregation, q.Step)
+		}
+		result.Series = append(result.Series, series)
+	}
+	return result, nil
+}
+
+// loadPoints reads the metric of the containers from from until before to,
+// in time order
+func loadPoints(db *gorm.DB, resolution time.Duration, metric seriesMetric, containers []string, from, to time.Time) ([]point, error) {
+	var points []point
+	for i := 0; i < len(containers); i += containerBatch {
+		batch := containers[i:min(i+containerBatch, len(containers))]
+
+		if resolution == ResolutionRaw {
+			var stats []models.ContainerStats
+			if err := db.Where("container_id IN ? AND timestamp >= ? AND timestamp < ?", batch, from, to).
+				Find(&stats).Error; err != nil {
+				return nil, err
+			}
+			for i := range stats {
+				s := &stats[i]
+				v := metric.raw(s)
+				points = append(points, point{
+					containerID:   s.ContainerID,
+					containerName: s.ContainerName,
+					hostID:        s.HostID,
+					image:         s.Image,
+					timestamp:     s.Timestamp,
+					samples:       1,
+					value:         models.MetricSummary{Min: v, Avg: v, Max: v, P95: v},
+				})
+			}
+			continue
+		}
+
+		var rollups []models.StatsRollup
+		if err := db.Where("resolution = ? AND container_id IN ? AND timestamp >= ? AND timestamp < ?",
+			int(resolution.Seconds()), batch, from.Truncate(resolution), to).
+			Find(&rollups).Error; err != nil {
+			return nil, err
+		}
+		for i := range rollups {
+			r := &rollups[i]
+			points = append(points, point{
+				containerID:   r.ContainerID,
+				containerName: r.ContainerName,
+				hostID:        r.HostID,
+				image:         r.Image,
+				timestamp:     r.Timestamp,
+				samples:       r.Samples,
+				value:         metric.rollup(r),
+			})
+		}
+	}
+
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].timestamp.Before(points[j].timestamp)
+	})
+	return points, nil
+}
+
+func seriesLabels(groupBy string, p point) map[string]string {
+	switch groupBy {
+	case GroupByImage:
+		return map[string]string{"image": p.image}
+	case GroupByHost:
+		return map[string]string{"host": p.hostID}
+	default:
+		return map[string]string{
+			"host":           p.hostID,
+			"container_id":   p.containerID,
+			"container_name": strings.TrimPrefix(p.containerName, "/"),
+		}
+	}
+}
+
+// labelKey identifies a group by its labels. Container groups are keyed by
+// ID alone, their name may change between samples.
+func labelKey(labels map[string]string) string {
+	if id, ok := labels["container_id"]; ok {
+		return labels["host"] + "\x00" + id
+	}
+	return labels["image"] + "\x00" + labels["host"]
+}
+
+type seriesGroup struct {
+	labels map[string]string
+	steps  []stepAccumulator
+}
+
+// stepAccumulator collects the points of one group within one step
+type stepAccumulator struct {
+	samples   int
+	weighted  float64 // Sum of averages weighted by their sample count
+	min       float64
+	max       float64
+	p95       []float64
+	perAvg    map[string][2]float64 // Weighted sum and samples per container
+	increases map[string]float64    // Counter increase per container
+}
+
+func (a *stepAccumulator) add(p point) {
+	if a.samples == 0 {
+		a.min, a.max = p.value.Min, p.value.Max
+		a.perAvg = make(map[string][2]float64)
+	}
+	a.samples += p.samples
+	a.weighted += p.value.Avg * float64(p.samples)
+	a.min = math.Min(a.min, p.value.Min)
+	a.max = math.Max(a.max, p.value.Max)
+	a.p95 = append(a.p95, p.value.P95)
+
+	c := a.perAvg[p.containerID]
+	a.perAvg[p.containerID] = [2]float64{c[0] + p.value.Avg*float64(p.samples), c[1] + float64(p.samples)}
+}
+
+// addIncrease records the increase of a counter since the container's
+// previous point. A counter that went down was reset, e.g. by a restart,
+// and has increased by its current value.
+func (a *stepAccumulator) addIncrease(containerID string, prev, cur float64) {
+	if a.increases == nil {
+		a.increases = make(map[string]float64)
+	}
+	increase := cur - prev
+	if increase < 0 {
+		increase = cur
+	}
+	a.increases[containerID] += increase
+}
+
+func (a *stepAccumulator) result(aggregation string, step time.Duration) *float64 {
+	var v float64
+	switch aggregation {
+	case AggRate:
+		if a.increases == nil {
+			return nil
+		}
+		for _, increase := range a.increases {
+			v += increase
+		}
+		v /= step.Seconds()
+		return &v
+	}
+
+	if a.samples == 0 {
+		return nil
+	}
+	switch aggregation {
+	case AggMin:
+		v = a.min
+	case AggMax:
+		v = a.max
+	case AggP95:
+		v = Percentile(a.p95, 95)
+	case AggSum:
+		for _, c := range a.perAvg {
+			v += c[0] / c[1]
+		}
+	default:
+		v = a.weighted / float64(a.samples)
+	}
+	return &v
+}
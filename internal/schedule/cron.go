@@ -0,0 +1,254 @@
+// Package schedule parses standard five field cron expressions.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearch bounds how far Next looks ahead for a matching minute. Every
+// valid expression fires at least once in five years (Feb 29 included).
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+type field struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: monthNames},
+	{name: "day of week", min: 0, max: 7, names: dayNames}, // 7 is Sunday too
+}
+
+// Schedule is a parsed cron expression. Times are matched in the location
+// of the time passed in.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// Cron matches a day if either day field matches when both are restricted
+	domStar, dowStar bool
+}
+
+// Parse parses a cron expression of the form "minute hour dom month dow".
+// Fields accept *, numbers, names (jan, mon), ranges, lists and steps, and
+// the @hourly, @daily, @weekly, @monthly and @yearly shorthands.
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if d, ok := descriptors[strings.ToLower(expr)]; ok {
+		expr = d
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("cron expression %q must have %d fields, got %d", expr, len(fields), len(parts))
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, part := range parts {
+		b, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in %q: %v", fields[i].name, expr, err)
+		}
+		bits[i] = b
+	}
+
+	// Fold day 7 onto Sunday
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+
+	return &Schedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: parts[2] == "*" || parts[2] == "?",
+		dowStar: parts[4] == "*" || parts[4] == "?",
+	}, nil
+}
+
+func parseField(s string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		b, err := parseItem(item, f)
+		if err != nil {
+			return 0, err
+		}
+		bits |= b
+	}
+	return bits, nil
+}
+
+func parseItem(item string, f field) (uint64, error) {
+	rangePart, step := item, 1
+	if i := strings.Index(item, "/"); i >= 0 {
+		rangePart = item[:i]
+		n, err := strconv.Atoi(item[i+1:])
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid step %q", item[i+1:])
+		}
+		step = n
+	}
+
+	var lo, hi int
+	switch {
+	case rangePart == "*" || rangePart == "?":
+		lo, hi = f.min, f.max
+	case strings.Contains(rangePart, "-"):
+		bounds := strings.SplitN(rangePart, "-", 2)
+		var err error
+		if lo, err = parseValue(bounds[0], f); err != nil {
+			return 0, err
+		}
+		if hi, err = parseValue(bounds[1], f); err != nil {
+			return 0, err
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("range %q is backwards", rangePart)
+		}
+	default:
+		v, err := parseValue(rangePart, f)
+		if err != nil {
+			return 0, err
+		}
+		lo, hi = v, v
+		// "5/15" means every 15 starting at 5
+		if step > 1 {
+			hi = f.max
+		}
+	}
+
+	var bits uint64
+	for v := lo; v <= hi; v += step {
+		bits |= 1 << uint(v)
+	}
+	return bits, nil
+}
+
+func parseValue(s string, f field) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Matches reports whether the schedule fires in the minute containing t
+func (s *Schedule) Matches(t time.Time) bool {
+	if s.minute&(1<<uint(t.Minute())) == 0 ||
+		s.hour&(1<<uint(t.Hour())) == 0 ||
+		s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	return s.dayMatches(t)
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next returns the first time after t the schedule fires, or the zero time
+// if it never does.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.Add(maxSearch)
+	for t.Before(end) {
+		// Skip whole days and hours that cannot match
+		if s.month&(1<<uint(t.Month())) == 0 || !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			// The next hour on the clock, Truncate would miss it in zones
+			// that are not a whole number of hours off UTC
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) != 0 {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
+
+// Prev returns the latest time at or before t the schedule fired, looking
+// back no further than limit. The zero time is returned if there is none.
+func (s *Schedule) Prev(t time.Time, limit time.Duration) time.Time {
+	earliest := t.Add(-limit)
+	t = t.Truncate(time.Minute)
+	for !t.Before(earliest) {
+		// Skip back whole days and hours that cannot match
+		if s.month&(1<<uint(t.Month())) == 0 || !s.dayMatches(t) {
+			t = lastMinuteOfPrevDay(t)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			// The last minute of the previous hour on the clock
+			t = t.Add(-time.Duration(t.Minute()+1) * time.Minute)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) != 0 {
+			return t
+		}
+		t = t.Add(-time.Minute)
+	}
+	return time.Time{}
+}
+
+// lastMinuteOfPrevDay returns the last minute of the day before t's
+func lastMinuteOfPrevDay(t time.Time) time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if midnight.After(t) {
+		// Midnight happened twice on a clock turned back, take the first
+		midnight = t.Add(-time.Duration(t.Hour()*60+t.Minute()) * time.Minute)
+	}
+	return midnight.Add(-time.Minute)
+}
+
+// ActiveAt reports whether t falls in a window of the given length that
+// opens each time the schedule fires.
+func (s *Schedule) ActiveAt(t time.Time, window time.Duration) bool {
+	if window <= 0 {
+		return false
+	}
+	prev := s.Prev(t, window)
+	return !prev.IsZero() && t.Sub(prev) < window
+}